@@ -0,0 +1,41 @@
+package message
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrNoParts is returned when an operation requires a multipart message to
+// have at least one part, but it has none.
+var ErrNoParts = errors.New("multipart message has no parts")
+
+// ChooseAlternative selects the preferred part of a multipart/alternative
+// message. According to RFC 2046, the parts of a multipart/alternative are
+// ordered from least preferred to most preferred, so the parts are checked in
+// reverse order. The first part found (i.e., the last in the message) whose
+// media type is in the accept list will be returned. The media types are
+// compared without regard to case.
+//
+// If none of the parts match any of the accepted media types, the last part is
+// returned. If the multipart has no parts, it returns nil and ErrNoParts.
+func ChooseAlternative(mp *Multipart, accept []string) (Generic, error) {
+	parts := mp.GetParts()
+	if len(parts) == 0 {
+		return nil, ErrNoParts
+	}
+
+	for i := len(parts) - 1; i >= 0; i-- {
+		mt, err := parts[i].GetHeader().GetMediaType()
+		if err != nil {
+			continue
+		}
+
+		for _, a := range accept {
+			if strings.EqualFold(mt, a) {
+				return parts[i], nil
+			}
+		}
+	}
+
+	return parts[len(parts)-1], nil
+}
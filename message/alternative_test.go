@@ -0,0 +1,58 @@
+package message_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/go-email/v2/message"
+)
+
+func TestChooseAlternative(t *testing.T) {
+	t.Parallel()
+
+	const src = `Content-type: multipart/alternative; boundary=alt
+
+--alt
+Content-type: text/plain
+
+Plain text.
+--alt
+Content-type: text/html
+
+<p>HTML text.</p>
+--alt
+Content-type: text/x-fancy
+
+Fancy text.
+--alt--
+`
+
+	m, err := message.Parse(strings.NewReader(src))
+	require.NoError(t, err)
+	mp, isMultipart := m.(*message.Multipart)
+	require.True(t, isMultipart)
+
+	mediaType := func(p message.Generic) string {
+		mt, err := p.GetHeader().GetMediaType()
+		require.NoError(t, err)
+		return mt
+	}
+
+	p, err := message.ChooseAlternative(mp, []string{"text/html", "text/plain"})
+	assert.NoError(t, err)
+	assert.Equal(t, "text/html", mediaType(p))
+
+	p, err = message.ChooseAlternative(mp, []string{"TEXT/PLAIN"})
+	assert.NoError(t, err)
+	assert.Equal(t, "text/plain", mediaType(p))
+
+	p, err = message.ChooseAlternative(mp, []string{"image/png"})
+	assert.NoError(t, err)
+	assert.Equal(t, "text/x-fancy", mediaType(p))
+
+	_, err = message.ChooseAlternative(message.MultipartAlternative(), nil)
+	assert.ErrorIs(t, err, message.ErrNoParts)
+}
@@ -3,6 +3,8 @@ package message
 import (
 	"math/rand"
 	"strings"
+
+	"github.com/zostay/go-email/v2/message/header"
 )
 
 var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
@@ -32,3 +34,10 @@ func GenerateSafeBoundary(contents string) string {
 		}
 	}
 }
+
+// ValidBoundary returns true if the string may be used as a multipart boundary.
+// This is the same check that SetBoundary performs on the header. See
+// header.ValidBoundary for the rules.
+func ValidBoundary(s string) bool {
+	return header.ValidBoundary(s)
+}
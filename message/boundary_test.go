@@ -3,6 +3,7 @@ package message_test
 import (
 	"math/rand"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -33,3 +34,19 @@ func TestGenerateSafeBoundary(t *testing.T) { //nolint:paralleltest // testing g
 	assert.False(t, nonAlphaNumericMatch.MatchString(nb))
 	assert.NotEqual(t, b, nb)
 }
+
+func TestValidBoundary(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, message.ValidBoundary(message.GenerateBoundary()))
+	assert.True(t, message.ValidBoundary("simple boundary"))
+	assert.True(t, message.ValidBoundary("'()+_,-./:=?"))
+	assert.True(t, message.ValidBoundary(strings.Repeat("x", 70)))
+
+	assert.False(t, message.ValidBoundary(""))
+	assert.False(t, message.ValidBoundary(strings.Repeat("x", 71)))
+	assert.False(t, message.ValidBoundary("trailing space "))
+	assert.False(t, message.ValidBoundary("semi;colon"))
+	assert.False(t, message.ValidBoundary("quote\"d"))
+	assert.False(t, message.ValidBoundary("café"))
+}
@@ -5,8 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/zostay/go-email/v2/message/header"
+	"github.com/zostay/go-email/v2/message/transfer"
 )
 
 const (
@@ -44,6 +46,11 @@ var (
 	// ErrParsesAsNotMultipart is returned by Multipart() when the Buffer is in
 	// ModeOpaque and the message is not at all a *Multipart message.
 	ErrParsesAsNotMultipart = errors.New("cannot parse non-multipart message as multipart")
+
+	// ErrBufferTooLarge is returned by Write(), Add(), Multipart(), and
+	// WriteTo() when the content of the Buffer would exceed the size set by
+	// SetMaxSize().
+	ErrBufferTooLarge = errors.New("message buffer exceeds maximum size")
 )
 
 // Buffer provides tools for constructing email messages. It can operate in
@@ -66,11 +73,25 @@ var (
 // Whatever the mode is, you may call either Opaque() or Multipart() to get the
 // constructed message at the end. However, there are some caveats, so be sure
 // to about them in the documentation of those methods.
+//
+// The line break of the Buffer, set with the SetBreak() method of the embedded
+// header.Header, is the line break of the Buffer's own header and, in
+// ModeMultipart, of the boundary lines written between the parts. The parts
+// themselves are not changed. Each part is still written with its own header
+// line break and its content is written as-is. For example, setting
+// header.CRLF on the Buffer makes the outer header and every boundary line
+// CRLF, as required for SMTP, without rebuilding or reparsing the parts.
 type Buffer struct {
 	header.Header
 	parts   []Part
 	buf     *bytes.Buffer
 	encoded bool
+
+	// maxSize is the limit set by SetMaxSize, size is the content counted so
+	// far toward that limit, and tooLarge is set when Add rejects parts
+	maxSize  int64
+	size     int64
+	tooLarge bool
 }
 
 // NewBuffer returns a buffer copied from the given message.Part. It will have a
@@ -89,7 +110,9 @@ func NewBuffer(part Part) (*Buffer, error) {
 			if err != nil {
 				return nil, err
 			}
-			buf.Add(pbuf)
+			if err := buf.Add(pbuf); err != nil {
+				return nil, err
+			}
 		}
 	} else {
 		buf.SetEncoded(part.IsEncoded())
@@ -165,6 +188,95 @@ func (b *Buffer) SetEncoded(e bool) {
 	b.encoded = e
 }
 
+// MakeAttachment sets the Content-disposition of the part to attachment with
+// the given filename. If the filename is empty, no filename parameter is set.
+//
+// Unless the Buffer is in ModeMultipart or a Content-transfer-encoding is
+// already set, this will also choose a transfer encoding for the part: text
+// parts will use quoted-printable and all other parts will use base64.
+func (b *Buffer) MakeAttachment(filename string) {
+	b.makeDisposition("attachment", filename)
+}
+
+// MakeInline sets the Content-disposition of the part to inline with the given
+// filename. If the filename is empty, no filename parameter is set. A transfer
+// encoding is chosen in the same way as MakeAttachment.
+func (b *Buffer) MakeInline(filename string) {
+	b.makeDisposition("inline", filename)
+}
+
+// makeDisposition implements MakeAttachment and MakeInline.
+func (b *Buffer) makeDisposition(presentation, filename string) {
+	b.SetPresentation(presentation)
+	if filename != "" {
+		_ = b.SetFilename(filename)
+	}
+
+	if b.Mode() == ModeMultipart {
+		return
+	}
+
+	if _, err := b.GetTransferEncoding(); err == nil {
+		return
+	}
+
+	mt, _ := b.GetMediaType()
+	if strings.HasPrefix(strings.ToLower(mt), "text/") {
+		b.SetTransferEncoding(transfer.QuotedPrintable)
+	} else {
+		b.SetTransferEncoding(transfer.Base64)
+	}
+}
+
+// SetMaxSize sets a limit on the size of the content of the Buffer, which is
+// useful when building a message from untrusted input. Setting n to 0 or less
+// removes the limit, which is the default.
+//
+// In ModeOpaque, every byte written counts toward the limit. A call to Write()
+// that would exceed the limit writes nothing and returns ErrBufferTooLarge.
+//
+// In ModeMultipart, the estimated serialized size of each part (including its
+// header, content, and boundary lines) counts toward the limit. A call to Add()
+// that would exceed the limit adds none of the given parts and returns
+// ErrBufferTooLarge. As the parts are missing, every way of getting the message
+// out of the Buffer fails afterward, too: Multipart() and WriteTo() return
+// ErrBufferTooLarge and writing the *Opaque returned by Opaque() fails with an
+// error wrapping ErrBufferTooLarge. The size of a part whose content is held in
+// an io.Reader of unknown length is estimated from its header alone.
+//
+// The header of the Buffer itself does not count toward the limit.
+func (b *Buffer) SetMaxSize(n int64) {
+	b.maxSize = n
+}
+
+// exceedsMaxSize returns true if adding n more bytes of content would exceed
+// the limit set by SetMaxSize.
+func (b *Buffer) exceedsMaxSize(n int64) bool {
+	return b.maxSize > 0 && b.size+n > b.maxSize
+}
+
+// estimatePartSize returns an estimate of the number of bytes the given part
+// will take when serialized.
+func estimatePartSize(part Part) int64 {
+	n, _ := part.GetHeader().WriteTo(io.Discard)
+
+	if part.IsMultipart() {
+		boundary, _ := part.GetHeader().GetBoundary()
+		for _, p := range part.GetParts() {
+			// --boundary plus line breaks
+			n += estimatePartSize(p) + int64(len(boundary)) + 6
+		}
+		// --boundary--
+		return n + int64(len(boundary)) + 4
+	}
+
+	if lr, hasLen := part.GetReader().(interface{ Len() int }); hasLen {
+		n += int64(lr.Len())
+	}
+
+	return n
+}
+
 func (b *Buffer) initBuffer() error {
 	if b.parts != nil {
 		return ErrPartsBuffer
@@ -190,24 +302,149 @@ func (b *Buffer) initParts(capacity int) error {
 
 // Add will add one or more parts to the message. It will panic if you attempt
 // to call this function after already calling Write() or using this object as
-// an io.Writer.
-func (b *Buffer) Add(msgs ...Part) {
+// an io.Writer. If the parts would exceed the limit set by SetMaxSize(), none
+// of them are added and it returns ErrBufferTooLarge. The Buffer remembers this,
+// so the output methods fail with ErrBufferTooLarge as well (see SetMaxSize).
+func (b *Buffer) Add(msgs ...Part) error {
 	if err := b.initParts(len(msgs)); err != nil {
 		panic(err)
 	}
+
+	if b.maxSize > 0 {
+		var n int64
+		for _, msg := range msgs {
+			n += estimatePartSize(msg)
+		}
+
+		if b.exceedsMaxSize(n) {
+			b.tooLarge = true
+			return ErrBufferTooLarge
+		}
+		b.size += n
+	}
+
 	b.parts = append(b.parts, msgs...)
+	return nil
+}
+
+// AddMessage adds the given message to this buffer as an embedded message. The
+// message is serialized with WriteTo() and wrapped in a part with a
+// Content-type of message/rfc822, which is then added with Add(). The bytes of
+// the embedded message are kept exactly as written and will not be encoded
+// again when the part is written. This is useful for forwarding a message as
+// an attachment or for including the original in a delivery status
+// notification.
+//
+// This returns ErrOpaqueBuffer if the Buffer is already in ModeOpaque. It
+// returns an error if serializing the message fails.
+func (b *Buffer) AddMessage(msg Generic) error {
+	if err := b.initParts(1); err != nil {
+		return err
+	}
+
+	buf := &bytes.Buffer{}
+	if _, err := msg.WriteTo(buf); err != nil {
+		return err
+	}
+
+	part := &Opaque{
+		Reader:  bytes.NewReader(buf.Bytes()),
+		encoded: true,
+	}
+	part.SetMediaType("message/rfc822")
+
+	return b.Add(part)
 }
 
 // Write implements io.Writer so you can write the message to this buffer. This
 // will panic if you attempt to call this method or use this object as an
-// io.Writer after calling Add.
+// io.Writer after calling Add. If the write would exceed the limit set by
+// SetMaxSize(), nothing is written and ErrBufferTooLarge is returned.
 func (b *Buffer) Write(p []byte) (int, error) {
 	if err := b.initBuffer(); err != nil {
 		panic(err)
 	}
+
+	if b.exceedsMaxSize(int64(len(p))) {
+		return 0, ErrBufferTooLarge
+	}
+	b.size += int64(len(p))
+
 	return b.buf.Write(p)
 }
 
+// WriteString works just like Write(), but writes the contents of a string,
+// which avoids converting it to a []byte first. This makes Buffer an
+// io.StringWriter. Like Write(), this will panic if called after Add and
+// returns ErrBufferTooLarge if the limit set by SetMaxSize() would be exceeded.
+func (b *Buffer) WriteString(s string) (int, error) {
+	if err := b.initBuffer(); err != nil {
+		panic(err)
+	}
+
+	if b.exceedsMaxSize(int64(len(s))) {
+		return 0, ErrBufferTooLarge
+	}
+	b.size += int64(len(s))
+
+	return b.buf.WriteString(s)
+}
+
+// WriteByte works just like Write(), but writes a single byte. This makes
+// Buffer an io.ByteWriter. Like Write(), this will panic if called after Add and
+// returns ErrBufferTooLarge if the limit set by SetMaxSize() would be exceeded.
+func (b *Buffer) WriteByte(c byte) error {
+	if err := b.initBuffer(); err != nil {
+		panic(err)
+	}
+
+	if b.exceedsMaxSize(1) {
+		return ErrBufferTooLarge
+	}
+	b.size++
+
+	return b.buf.WriteByte(c)
+}
+
+// SetTextCharset transcodes the UTF-8 string s into the given charset (see
+// EncodeCharset) and writes the result to the Buffer. It sets the charset
+// parameter of the Content-type header to match, setting the media type to
+// text/plain first if no Content-type is set, and sets the
+// Content-transfer-encoding to quoted-printable. The Buffer must not be
+// encoded (see SetEncoded) so that the transfer encoding is applied on output.
+//
+// Like Write(), this will panic if the Buffer is in ModeMultipart. If s cannot
+// be transcoded, nothing is changed and the error from EncodeCharset is
+// returned. It returns ErrBufferTooLarge if the limit set by SetMaxSize() would
+// be exceeded.
+func (b *Buffer) SetTextCharset(s, charset string) error {
+	if err := b.initBuffer(); err != nil {
+		panic(err)
+	}
+
+	p, err := EncodeCharset(s, charset)
+	if err != nil {
+		return err
+	}
+
+	if b.exceedsMaxSize(int64(len(p))) {
+		return ErrBufferTooLarge
+	}
+
+	if _, err := b.GetMediaType(); err != nil {
+		b.SetMediaType("text/plain")
+	}
+
+	if err := b.SetCharset(charset); err != nil {
+		return err
+	}
+
+	b.SetTransferEncoding(transfer.QuotedPrintable)
+
+	_, err = b.Write(p)
+	return err
+}
+
 func (b *Buffer) prepareForMultipartOutput() {
 	if _, err := b.GetMediaType(); errors.Is(err, header.ErrNoSuchField) {
 		b.SetMediaType(DefaultMultipartContentType)
@@ -242,6 +479,10 @@ func (b *Buffer) prepareForMultipartOutput() {
 // something random using mime.GenerateBound() automatically. This boundary
 // will then be used when joining the parts together during serialization.
 //
+// If a part was rejected by Add() for exceeding the limit set by SetMaxSize(),
+// the body of the returned *Opaque will fail with ErrBufferTooLarge when it is
+// read, so the incomplete message cannot be written by mistake.
+//
 // After this method is called, the Buffer should be disposed of and no longer
 // used.
 func (b *Buffer) Opaque() *Opaque {
@@ -255,6 +496,13 @@ func (b *Buffer) Opaque() *Opaque {
 		}
 	case ModeMultipart:
 		b.prepareForMultipartOutput()
+		if b.tooLarge {
+			return &Opaque{
+				Header: b.Header,
+				Reader: &errReader{ErrBufferTooLarge},
+			}
+		}
+
 		boundary, _ := b.GetBoundary()
 
 		buf := &bytes.Buffer{}
@@ -343,10 +591,10 @@ func (b *Buffer) Multipart() (*Multipart, error) {
 	switch b.Mode() {
 	case ModeOpaque:
 		r := bytes.NewReader(b.buf.Bytes())
-		msg := &Opaque{b.Header, r, false}
+		msg := &Opaque{Header: b.Header, Reader: r}
 		pr := defaultParser.clone()
 		WithoutRecursion()(pr)
-		gmsg, err := pr.parse(msg, 0)
+		gmsg, err := pr.parse(msg, 0, partLocation{})
 		switch vmsg := gmsg.(type) {
 		case *Opaque:
 			if err != nil {
@@ -358,6 +606,10 @@ func (b *Buffer) Multipart() (*Multipart, error) {
 		}
 		return nil, errors.New("generic message came back as something other than Opaque or Multipart")
 	case ModeMultipart:
+		if b.tooLarge {
+			return nil, ErrBufferTooLarge
+		}
+
 		return &Multipart{
 			Header: b.Header,
 			prefix: []byte{},
@@ -427,10 +679,24 @@ func (b *Buffer) GetParts() []Part {
 }
 
 // WriteTo writes the buffer to the given writer. This will panic if Mode() is
-// BufferUnset.
+// BufferUnset. It returns ErrBufferTooLarge without writing anything if a part
+// was rejected by Add() for exceeding the limit set by SetMaxSize().
 func (b *Buffer) WriteTo(w io.Writer) (int64, error) {
 	if b.Mode() == ModeUnset {
 		panic("mode is unset")
 	}
+	if b.tooLarge {
+		return 0, ErrBufferTooLarge
+	}
 	return b.Opaque().WriteTo(w)
 }
+
+// errReader is an io.Reader that always fails with the given error.
+type errReader struct {
+	err error
+}
+
+// Read returns the error.
+func (r *errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
@@ -3,11 +3,16 @@ package message_test
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/zostay/go-email/v2/message"
+	"github.com/zostay/go-email/v2/message/header"
+	"github.com/zostay/go-email/v2/message/transfer"
 )
 
 func makePart() *message.Buffer {
@@ -446,3 +451,260 @@ func TestBuffer_UnsetAsPart(t *testing.T) {
 	assert.Panics(t, func() { _ = s.GetReader() })
 	assert.Panics(t, func() { _ = s.GetParts() })
 }
+
+func TestBuffer_MakeAttachment(t *testing.T) {
+	t.Parallel()
+
+	buf := &message.Buffer{}
+	buf.SetMediaType("application/pdf")
+	buf.MakeAttachment("report.pdf")
+
+	pres, err := buf.GetPresentation()
+	assert.NoError(t, err)
+	assert.Equal(t, "attachment", pres)
+
+	fn, err := buf.GetFilename()
+	assert.NoError(t, err)
+	assert.Equal(t, "report.pdf", fn)
+
+	cte, err := buf.GetTransferEncoding()
+	assert.NoError(t, err)
+	assert.Equal(t, transfer.Base64, cte)
+}
+
+func TestBuffer_MakeInline(t *testing.T) {
+	t.Parallel()
+
+	buf := &message.Buffer{}
+	buf.SetMediaType("text/plain")
+	buf.MakeInline("")
+
+	pres, err := buf.GetPresentation()
+	assert.NoError(t, err)
+	assert.Equal(t, "inline", pres)
+
+	_, err = buf.GetFilename()
+	assert.ErrorIs(t, err, header.ErrNoSuchFieldParameter)
+
+	cte, err := buf.GetTransferEncoding()
+	assert.NoError(t, err)
+	assert.Equal(t, transfer.QuotedPrintable, cte)
+
+	// an existing transfer encoding is kept
+	buf = &message.Buffer{}
+	buf.SetMediaType("image/png")
+	buf.SetTransferEncoding(transfer.Binary)
+	buf.MakeInline("logo.png")
+
+	cte, err = buf.GetTransferEncoding()
+	assert.NoError(t, err)
+	assert.Equal(t, transfer.Binary, cte)
+}
+
+func TestBuffer_SetMaxSize_Write(t *testing.T) {
+	t.Parallel()
+
+	buf := &message.Buffer{}
+	buf.SetMaxSize(10)
+
+	n, err := buf.Write([]byte("0123456"))
+	assert.NoError(t, err)
+	assert.Equal(t, 7, n)
+
+	n, err = buf.Write([]byte("7890"))
+	assert.ErrorIs(t, err, message.ErrBufferTooLarge)
+	assert.Equal(t, 0, n)
+
+	n, err = buf.Write([]byte("789"))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+}
+
+func TestBuffer_SetMaxSize_Add(t *testing.T) {
+	t.Parallel()
+
+	// Content-type: text/html\n\nTest message.
+	const partSize = 26 + 13
+
+	buf := &message.Buffer{}
+	buf.SetMaxSize(partSize)
+	buf.Add(makePart())
+
+	m, err := buf.Multipart()
+	assert.NoError(t, err)
+	assert.Len(t, m.GetParts(), 1)
+
+	buf = &message.Buffer{}
+	buf.SetMaxSize(partSize)
+	assert.NoError(t, buf.Add(makePart()))
+	assert.ErrorIs(t, buf.Add(makePart()), message.ErrBufferTooLarge)
+
+	assert.Len(t, buf.GetParts(), 1)
+
+	_, err = buf.Multipart()
+	assert.ErrorIs(t, err, message.ErrBufferTooLarge)
+
+	// the incomplete message must not be written by any other path either
+	out := &bytes.Buffer{}
+	n, err := buf.WriteTo(out)
+	assert.ErrorIs(t, err, message.ErrBufferTooLarge)
+	assert.Equal(t, int64(0), n)
+	assert.Empty(t, out.String())
+
+	_, err = buf.Opaque().WriteTo(out)
+	assert.ErrorIs(t, err, message.ErrBufferTooLarge)
+}
+
+func TestBuffer_SetMaxSize_AddMessage(t *testing.T) {
+	t.Parallel()
+
+	big := &message.Buffer{}
+	big.SetMediaType("text/plain")
+	_, _ = big.WriteString(strings.Repeat("x", 500))
+
+	buf := &message.Buffer{}
+	buf.SetMaxSize(100)
+	assert.ErrorIs(t, buf.AddMessage(big), message.ErrBufferTooLarge)
+
+	_, err := buf.WriteTo(io.Discard)
+	assert.ErrorIs(t, err, message.ErrBufferTooLarge)
+}
+
+func TestBuffer_AddMessage(t *testing.T) {
+	t.Parallel()
+
+	const original = "Subject: original\n" +
+		"Content-type: text/plain\n" +
+		"Content-transfer-encoding: quoted-printable\n" +
+		"\n" +
+		"Caf=C3=A9 menu.\n"
+
+	orig, err := message.Parse(strings.NewReader(original))
+	require.NoError(t, err)
+
+	buf := &message.Buffer{}
+	buf.SetSubject("Fwd: original")
+	buf.SetMediaType("multipart/mixed")
+	_ = buf.SetBoundary("fwd")
+	buf.Add(makePart())
+	require.NoError(t, buf.AddMessage(orig))
+
+	expect := "Subject: Fwd: original\n" +
+		"Content-type: multipart/mixed; boundary=fwd\n" +
+		"\n" +
+		"--fwd\n" +
+		"Content-type: text/html\n" +
+		"\n" +
+		"Test message.\n" +
+		"--fwd\n" +
+		"Content-type: message/rfc822\n" +
+		"\n" +
+		original + "\n" +
+		"--fwd--"
+
+	out := &bytes.Buffer{}
+	_, err = buf.WriteTo(out)
+	assert.NoError(t, err)
+	assert.Equal(t, expect, out.String())
+
+	opaque := &message.Buffer{}
+	_, _ = opaque.Write([]byte("opaque"))
+	assert.ErrorIs(t, opaque.AddMessage(orig), message.ErrOpaqueBuffer)
+}
+
+func TestBuffer_SetTextCharset(t *testing.T) {
+	t.Parallel()
+
+	buf := &message.Buffer{}
+	buf.SetSubject("latin")
+	err := buf.SetTextCharset("Café\n", "iso-8859-1")
+	require.NoError(t, err)
+
+	mt, err := buf.GetMediaType()
+	assert.NoError(t, err)
+	assert.Equal(t, "text/plain", mt)
+
+	cs, err := buf.GetCharset()
+	assert.NoError(t, err)
+	assert.Equal(t, "iso-8859-1", cs)
+
+	cte, err := buf.GetTransferEncoding()
+	assert.NoError(t, err)
+	assert.Equal(t, transfer.QuotedPrintable, cte)
+
+	out := &bytes.Buffer{}
+	_, err = buf.Opaque().WriteTo(out)
+	assert.NoError(t, err)
+	assert.Contains(t, out.String(), "\n\nCaf=E9\r\n")
+}
+
+func TestBuffer_SetTextCharset_Unencodable(t *testing.T) {
+	t.Parallel()
+
+	buf := &message.Buffer{}
+	err := buf.SetTextCharset("5€", "iso-8859-1")
+	assert.ErrorIs(t, err, message.ErrUnencodableRune)
+
+	_, err = buf.GetHeader().GetMediaType()
+	assert.ErrorIs(t, err, header.ErrNoSuchField)
+}
+
+func TestBuffer_WriteString(t *testing.T) {
+	t.Parallel()
+
+	buf := &message.Buffer{}
+	buf.SetSubject("strings")
+	buf.SetMaxSize(12)
+
+	var sw io.StringWriter = buf
+	n, err := sw.WriteString("Hello")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+	assert.Equal(t, message.ModeOpaque, buf.Mode())
+
+	var bw io.ByteWriter = buf
+	assert.NoError(t, bw.WriteByte(' '))
+
+	_, err = fmt.Fprint(buf, "World")
+	assert.NoError(t, err)
+
+	n, err = buf.WriteString("!!")
+	assert.ErrorIs(t, err, message.ErrBufferTooLarge)
+	assert.Equal(t, 0, n)
+	assert.NoError(t, buf.WriteByte('!'))
+	assert.ErrorIs(t, buf.WriteByte('!'), message.ErrBufferTooLarge)
+
+	out := &bytes.Buffer{}
+	_, err = buf.WriteTo(out)
+	assert.NoError(t, err)
+	assert.Equal(t, "Subject: strings\n\nHello World!", out.String())
+
+	mbuf := &message.Buffer{}
+	mbuf.Add(&message.Buffer{})
+	assert.Panics(t, func() { _, _ = mbuf.WriteString("nope") })
+	assert.Panics(t, func() { _ = mbuf.WriteByte('x') })
+}
+
+func TestBuffer_SetBreak(t *testing.T) {
+	t.Parallel()
+
+	part := &message.Buffer{}
+	part.SetMediaType("text/plain")
+	_, _ = fmt.Fprint(part, "line one\nline two")
+
+	buf := &message.Buffer{}
+	buf.SetBreak(header.CRLF)
+	buf.SetMediaType("multipart/mixed")
+	require.NoError(t, buf.SetBoundary("b"))
+	buf.Add(part)
+
+	out := &bytes.Buffer{}
+	_, err := buf.Opaque().WriteTo(out)
+	require.NoError(t, err)
+	assert.Equal(t,
+		"Content-type: multipart/mixed; boundary=b\r\n\r\n"+
+			"--b\r\n"+
+			"Content-type: text/plain\n\nline one\nline two\r\n"+
+			"--b--",
+		out.String())
+}
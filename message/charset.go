@@ -0,0 +1,117 @@
+package message
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/ianaindex"
+)
+
+// ErrUnencodableRune is returned by EncodeCharset when the string contains a
+// character that has no representation in the target charset.
+var ErrUnencodableRune = errors.New("character cannot be encoded in charset")
+
+// EncodeCharset transcodes the UTF-8 string s into the named charset, such as
+// "iso-8859-1" or "windows-1252". Any charset known to the IANA index in
+// golang.org/x/text may be used. If the charset is utf-8, the bytes are
+// checked to be valid UTF-8, but otherwise returned as is.
+//
+// No character is silently dropped or substituted. If s contains a character
+// that cannot be represented in the charset, or a byte that is not valid
+// UTF-8, it returns an error wrapping ErrUnencodableRune that identifies the
+// character and its byte offset in s.
+// It returns an error if the charset is unknown or unsupported.
+func EncodeCharset(s, charset string) ([]byte, error) {
+	switch strings.ToLower(charset) {
+	case "utf-8", "utf8":
+		if err := findInvalidUTF8(s, charset); err != nil {
+			return nil, err
+		}
+		return []byte(s), nil
+	}
+
+	e, err := ianaindex.MIME.Encoding(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset %q: %w", charset, err)
+	}
+
+	if e == nil {
+		return nil, fmt.Errorf("no encoding found for charset %q", charset)
+	}
+
+	out, err := e.NewEncoder().Bytes([]byte(s))
+	if err == nil {
+		return out, nil
+	}
+
+	return nil, findUnencodableRune(e, s, charset, err)
+}
+
+// findUnencodableRune locates the first character of s that the encoding
+// cannot represent and returns an error describing it. If no single character
+// is at fault, the original error is returned with the charset named.
+func findUnencodableRune(e encoding.Encoding, s, charset string, err error) error {
+	enc := e.NewEncoder()
+	for i, c := range s {
+		if _, rerr := enc.String(string(c)); rerr != nil {
+			return fmt.Errorf("%w %q: %q (%U) at byte %d",
+				ErrUnencodableRune, charset, c, c, i)
+		}
+	}
+
+	return fmt.Errorf("unable to encode text in charset %q: %w", charset, err)
+}
+
+// findInvalidUTF8 returns an error describing the first byte of s that is not
+// valid UTF-8 or nil if s is valid.
+func findInvalidUTF8(s, charset string) error {
+	if utf8.ValidString(s) {
+		return nil
+	}
+
+	for i, c := range s {
+		if c == utf8.RuneError {
+			if _, size := utf8.DecodeRuneInString(s[i:]); size == 1 {
+				return fmt.Errorf("%w %q: invalid UTF-8 byte %#02x at byte %d",
+					ErrUnencodableRune, charset, s[i], i)
+			}
+		}
+	}
+
+	return nil
+}
+
+// htmlSniffLength is how much of the start of an HTML document is searched by
+// HTMLCharset, which is the same as the prescan of the HTML standard.
+const htmlSniffLength = 1024
+
+// htmlMetaCharsetPattern matches the charset declared by either
+// <meta charset="..."> or <meta http-equiv="Content-Type" content="...;
+// charset=...">.
+var htmlMetaCharsetPattern = regexp.MustCompile(
+	`(?i)<meta\b[^>]*?\bcharset\s*=\s*["']?\s*([a-z0-9_.:\-]+)`)
+
+// HTMLCharset returns the charset declared by a <meta> tag found in the first
+// 1024 bytes of an HTML document, such as "windows-1252" from either of these:
+//
+//	<meta charset="windows-1252">
+//	<meta http-equiv="Content-Type" content="text/html; charset=windows-1252">
+//
+// It returns an empty string if no charset is declared. The charset should
+// only be used when the Content-type header of the part does not name one.
+func HTMLCharset(body []byte) string {
+	if len(body) > htmlSniffLength {
+		body = body[:htmlSniffLength]
+	}
+
+	m := htmlMetaCharsetPattern.FindSubmatch(body)
+	if m == nil {
+		return ""
+	}
+
+	return string(m[1])
+}
@@ -0,0 +1,121 @@
+package message_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/go-email/v2/message"
+)
+
+func TestEncodeCharset(t *testing.T) {
+	t.Parallel()
+
+	b, err := message.EncodeCharset("Café", "iso-8859-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("Caf\xe9"), b)
+
+	b, err = message.EncodeCharset("Café", "utf-8")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("Café"), b)
+
+	b, err = message.EncodeCharset("plain", "us-ascii")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("plain"), b)
+}
+
+func TestEncodeCharset_Unencodable(t *testing.T) {
+	t.Parallel()
+
+	b, err := message.EncodeCharset("Price: 5€", "iso-8859-1")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, message.ErrUnencodableRune)
+	assert.Contains(t, err.Error(), "U+20AC")
+	assert.Contains(t, err.Error(), "at byte 8")
+	assert.Nil(t, b)
+}
+
+func TestEncodeCharset_InvalidUTF8(t *testing.T) {
+	t.Parallel()
+
+	b, err := message.EncodeCharset("h\xffé", "utf-8")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, message.ErrUnencodableRune)
+	assert.Contains(t, err.Error(), "0xff")
+	assert.Contains(t, err.Error(), "at byte 1")
+	assert.Nil(t, b)
+}
+
+func TestEncodeCharset_Unknown(t *testing.T) {
+	t.Parallel()
+
+	b, err := message.EncodeCharset("text", "x-no-such-charset")
+	assert.Error(t, err)
+	assert.Nil(t, b)
+}
+
+func TestHTMLCharset(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		html   string
+		expect string
+	}{
+		{
+			name:   "meta charset",
+			html:   `<html><head><meta charset="windows-1252"></head>`,
+			expect: "windows-1252",
+		},
+		{
+			name:   "meta charset unquoted",
+			html:   `<META CHARSET=iso-8859-15>`,
+			expect: "iso-8859-15",
+		},
+		{
+			name:   "http-equiv",
+			html:   `<meta http-equiv="Content-Type" content="text/html; charset=Shift_JIS">`,
+			expect: "Shift_JIS",
+		},
+		{
+			name:   "none",
+			html:   `<html><head><title>charset=nope</title></head>`,
+			expect: "",
+		},
+		{
+			name:   "too late",
+			html:   "<html>" + strings.Repeat(" ", 1024) + `<meta charset="koi8-r">`,
+			expect: "",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expect, message.HTMLCharset([]byte(test.html)))
+		})
+	}
+}
+
+func TestHTMLCharset_ToJSON(t *testing.T) {
+	t.Parallel()
+
+	const src = "Content-type: text/html\n" +
+		"\n" +
+		"<meta charset=\"windows-1252\"><p>Caf\xe9 \x80</p>\n"
+
+	m, err := message.Parse(strings.NewReader(src))
+	require.NoError(t, err)
+
+	js, err := message.ToJSON(m)
+	require.NoError(t, err)
+
+	var got struct{ Text string }
+	require.NoError(t, json.Unmarshal(js, &got))
+	assert.Equal(t, "<meta charset=\"windows-1252\"><p>Café €</p>\n", got.Text)
+}
@@ -0,0 +1,19 @@
+package message
+
+import (
+	"time"
+
+	"github.com/zostay/go-email/v2/message/header"
+)
+
+// SetClock sets the function consulted for the current time whenever a message
+// is generated, such as by header.Header.SetDateNow and by MboxWriter.Append
+// when no time is given. Passing nil restores the default, time.Now. This is
+// the same clock set by header.SetClock.
+//
+// This is global state shared by the whole program. It is intended for tests
+// and for other environments where generated messages must be reproducible.
+// It is safe to call from multiple goroutines.
+func SetClock(fn func() time.Time) {
+	header.SetClock(fn)
+}
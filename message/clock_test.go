@@ -0,0 +1,41 @@
+package message_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/go-email/v2/message"
+	"github.com/zostay/go-email/v2/message/header"
+)
+
+func TestSetClock(t *testing.T) { //nolint:paralleltest // testing globals
+	// Do not test in parallel. The clock is global, so changing it would
+	// affect any other test running at the same time.
+
+	defer message.SetClock(nil)
+
+	fixed := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	message.SetClock(func() time.Time { return fixed })
+	assert.Equal(t, fixed, header.Now())
+
+	buf := &message.Buffer{}
+	buf.SetSubject("clocked")
+	buf.SetDateNow()
+	d, err := buf.GetDate()
+	assert.NoError(t, err)
+	assert.Equal(t, fixed, d)
+
+	m, err := message.Parse(strings.NewReader("Subject: mbox\n\nhello\n"))
+	require.NoError(t, err)
+
+	out := &bytes.Buffer{}
+	err = message.NewMboxWriter(out, "").Append(m, "", time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, "From MAILER-DAEMON Mon Jan  2 03:04:05 2023\n"+
+		"Subject: mbox\n\nhello\n\n", out.String())
+}
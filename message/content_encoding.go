@@ -0,0 +1,63 @@
+package message
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strings"
+
+	"github.com/zostay/go-email/v2/message/header"
+)
+
+// contentDecoders maps each supported Content-encoding to a function that
+// decompresses it.
+var contentDecoders = map[string]func(io.Reader) (io.Reader, error){
+	"gzip":    func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+	"x-gzip":  func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+	"deflate": func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) },
+}
+
+// applyContentDecoding wraps the body with a decompressing reader if the header
+// has a supported Content-encoding. In that case, the Content-encoding field is
+// removed from the header. Otherwise, the body is returned as-is.
+func applyContentDecoding(h *header.Header, body io.Reader) io.Reader {
+	if body == nil {
+		return nil
+	}
+
+	ce, err := h.Get(header.ContentEncoding)
+	if err != nil {
+		return body
+	}
+
+	decoder, supported := contentDecoders[strings.ToLower(strings.TrimSpace(ce))]
+	if !supported {
+		return body
+	}
+
+	h.Unset(header.ContentEncoding)
+	return &lazyDecoder{r: body, decoder: decoder}
+}
+
+// lazyDecoder defers construction of the decompressing reader until the first
+// read, since the decompressors read and check a header from the input as soon
+// as they are constructed.
+type lazyDecoder struct {
+	r       io.Reader
+	decoder func(io.Reader) (io.Reader, error)
+	err     error
+}
+
+// Read constructs the decompressor on the first call and then reads from it.
+func (l *lazyDecoder) Read(p []byte) (int, error) {
+	if l.decoder != nil {
+		l.r, l.err = l.decoder(l.r)
+		l.decoder = nil
+	}
+
+	if l.err != nil {
+		return 0, l.err
+	}
+
+	return l.r.Read(p)
+}
@@ -0,0 +1,64 @@
+package message
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/zostay/go-email/v2/message/header"
+)
+
+// ErrUnsupportedPart is returned when a Generic message is of a type the
+// operation does not know how to handle.
+var ErrUnsupportedPart = errors.New("unsupported message type")
+
+// EditHeader returns a copy of the message with a copy of its top-level header
+// changed by the given edit function. Nothing else is copied: the copy shares
+// the body or the parts of the original, so none of the content is read. This
+// makes it much cheaper than using NewBuffer for changing a header before
+// sending a message on:
+//
+//	fwd, err := message.EditHeader(msg, func(h *header.Header) {
+//		h.SetSubject("Fwd: " + h.GetOr(header.Subject, ""))
+//	})
+//
+// Because the body is shared, writing either message consumes the io.Reader
+// used by both, as if the same message had been written twice. If the body is
+// replayable (see Opaque.IsReplayable()), either may still be written any
+// number of times. To allow this, the original *Opaque is changed so that it
+// too rewinds the body before each write, just as the copy does. A change made
+// to one of the shared parts will be seen by both messages.
+//
+// The message must be an *Opaque, a *Multipart, or an *MboxMessage or
+// *LazyPart wrapping one of them. Otherwise, it returns an error wrapping
+// ErrUnsupportedPart.
+func EditHeader(msg Generic, edit func(*header.Header)) (Generic, error) {
+	switch m := msg.(type) {
+	case *Opaque:
+		om := *m
+		om.Header = *m.Header.Clone()
+		edit(&om.Header)
+
+		// the body is shared, so each must rewind it before writing it
+		if m.IsReplayable() {
+			m.written = true
+			om.written = true
+		}
+
+		return &om, nil
+	case *Multipart:
+		mm := *m
+		mm.Header = *m.Header.Clone()
+		edit(&mm.Header)
+		return &mm, nil
+	case *MboxMessage:
+		em, err := EditHeader(m.Generic, edit)
+		if err != nil {
+			return nil, err
+		}
+		return &MboxMessage{Generic: em, envelopeFrom: m.envelopeFrom}, nil
+	case *LazyPart:
+		return EditHeader(m.resolve(), edit)
+	}
+
+	return nil, fmt.Errorf("%w: %T", ErrUnsupportedPart, msg)
+}
@@ -0,0 +1,135 @@
+package message_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/go-email/v2/message"
+	"github.com/zostay/go-email/v2/message/header"
+)
+
+// countingReader counts the calls to Read.
+type countingReader struct {
+	r     *strings.Reader
+	reads int
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	cr.reads++
+	return cr.r.Read(p)
+}
+
+func TestEditHeader_Opaque(t *testing.T) {
+	t.Parallel()
+
+	cr := &countingReader{r: strings.NewReader("body\n")}
+	h := &header.Header{}
+	h.SetSubject("original")
+	m := message.NewOpaque(h, cr, true)
+
+	em, err := message.EditHeader(m, func(h *header.Header) {
+		h.SetSubject("Fwd: " + h.GetOr(header.Subject, ""))
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 0, cr.reads)
+
+	s, err := m.GetSubject()
+	assert.NoError(t, err)
+	assert.Equal(t, "original", s)
+
+	buf := &bytes.Buffer{}
+	_, err = em.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "Subject: Fwd: original\n\nbody\n", buf.String())
+}
+
+func TestEditHeader_OpaqueReplay(t *testing.T) {
+	t.Parallel()
+
+	h := &header.Header{}
+	h.SetSubject("original")
+	m := message.NewOpaque(h, strings.NewReader("body\n"), true)
+
+	em, err := message.EditHeader(m, func(h *header.Header) {
+		h.SetSubject("edited")
+	})
+	require.NoError(t, err)
+
+	// writing the copy first does not leave the original without a body
+	buf := &bytes.Buffer{}
+	_, err = em.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "Subject: edited\n\nbody\n", buf.String())
+
+	buf.Reset()
+	_, err = m.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "Subject: original\n\nbody\n", buf.String())
+}
+
+func TestEditHeader_Multipart(t *testing.T) {
+	t.Parallel()
+
+	m, err := message.Parse(strings.NewReader(transformSrc))
+	require.NoError(t, err)
+
+	em, err := message.EditHeader(m, func(h *header.Header) {
+		h.SetSubject("edited")
+	})
+	require.NoError(t, err)
+	require.True(t, em.IsMultipart())
+
+	for i, p := range m.GetParts() {
+		assert.Same(t, p, em.GetParts()[i])
+	}
+
+	buf := &bytes.Buffer{}
+	_, err = em.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t,
+		strings.Replace(transformSrc, "Subject: transform me", "Subject: edited", 1),
+		buf.String())
+
+	// the original is untouched and its replayable parts may still be written
+	buf.Reset()
+	_, err = m.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, transformSrc, buf.String())
+}
+
+func TestEditHeader_Mbox(t *testing.T) {
+	t.Parallel()
+
+	mr := message.NewMboxReader(strings.NewReader(mboxSrc))
+	m, err := mr.Next()
+	require.NoError(t, err)
+
+	em, err := message.EditHeader(m, func(h *header.Header) {
+		h.SetSubject("edited")
+	})
+	require.NoError(t, err)
+
+	from, ok := message.EnvelopeFrom(em)
+	assert.True(t, ok)
+	assert.Equal(t, "alice@example.com", from)
+
+	s, err := em.GetHeader().GetSubject()
+	assert.NoError(t, err)
+	assert.Equal(t, "edited", s)
+}
+
+func TestEditHeader_Unsupported(t *testing.T) {
+	t.Parallel()
+
+	type otherPart struct {
+		message.Generic
+	}
+
+	em, err := message.EditHeader(&otherPart{&message.Opaque{}}, func(*header.Header) {})
+	assert.ErrorIs(t, err, message.ErrUnsupportedPart)
+	assert.Nil(t, em)
+}
@@ -0,0 +1,123 @@
+package message
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/zostay/go-addr/pkg/addr"
+	"golang.org/x/net/idna"
+
+	"github.com/zostay/go-email/v2/message/header"
+)
+
+// Errors returned by Envelope.
+var (
+	// ErrNoEnvelopeSender is returned by Envelope when the header has no
+	// Return-path, Sender, or From address to use as the envelope sender.
+	ErrNoEnvelopeSender = errors.New("no envelope sender found")
+
+	// ErrNoEnvelopeRecipients is returned by Envelope when the header has no
+	// To, Cc, or Bcc addresses to use as the envelope recipients.
+	ErrNoEnvelopeRecipients = errors.New("no envelope recipients found")
+)
+
+// returnPath is the name of the Return-path header field.
+const returnPath = "Return-path"
+
+// Envelope derives the SMTP (or LMTP) envelope sender and recipients from the
+// header of the message. The envelope sender is taken from the first of these
+// fields found:
+//
+// * Return-path, which may be "<>" to request a null envelope sender, in which
+// case the sender returned is the empty string.
+//
+// * Sender
+//
+// * From
+//
+// The envelope recipients are all the unique addresses found in the To, Cc,
+// and Bcc fields as returned by header.Header.AllRecipients.
+//
+// Every address is returned as a bare addr-spec without display name or
+// comments. Each domain is converted to its ASCII (punycode) form.
+//
+// It returns ErrNoEnvelopeSender if there is no sender and
+// ErrNoEnvelopeRecipients if there are no recipients. It returns an error if
+// an address field is set more than once or a domain cannot be converted.
+func Envelope(msg Generic) (string, []string, error) {
+	h := msg.GetHeader()
+
+	from, err := envelopeSender(h)
+	if err != nil {
+		return "", nil, err
+	}
+
+	al, err := h.AllRecipients()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(al) == 0 {
+		return "", nil, ErrNoEnvelopeRecipients
+	}
+
+	rcpts := make([]string, len(al))
+	for i, a := range al {
+		rcpts[i], err = envelopeAddress(a)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	return from, rcpts, nil
+}
+
+// envelopeSender finds the envelope sender for Envelope.
+func envelopeSender(h *header.Header) (string, error) {
+	rp, err := h.Get(returnPath)
+	if err == nil && strings.ReplaceAll(rp, " ", "") == "<>" {
+		return "", nil
+	} else if err != nil && !errors.Is(err, header.ErrNoSuchField) {
+		return "", fmt.Errorf("unable to read envelope sender from %s: %w", returnPath, err)
+	}
+
+	for _, name := range []string{returnPath, header.Sender, header.From} {
+		al, err := h.GetAddressList(name)
+		if errors.Is(err, header.ErrNoSuchField) {
+			continue
+		} else if err != nil {
+			return "", fmt.Errorf("unable to read envelope sender from %s: %w", name, err)
+		}
+
+		for _, a := range al {
+			switch a.(type) {
+			case *addr.Mailbox, *addr.AddrSpec:
+				return envelopeAddress(a)
+			}
+		}
+	}
+
+	return "", ErrNoEnvelopeSender
+}
+
+// envelopeAddress returns the bare addr-spec of the address with the domain
+// converted to its ASCII form.
+func envelopeAddress(a addr.Address) (string, error) {
+	var as *addr.AddrSpec
+	switch a := a.(type) {
+	case *addr.Mailbox:
+		as = a.AddrSpec()
+	case *addr.AddrSpec:
+		as = a
+	default:
+		return "", fmt.Errorf("unable to use %q as an envelope address", a.Address())
+	}
+
+	domain, err := idna.ToASCII(as.Domain())
+	if err != nil {
+		return "", fmt.Errorf("unable to convert domain of %q to ASCII: %w", as.Address(), err)
+	}
+
+	return addr.NewAddrSpec(as.LocalPart(), domain).CleanString(), nil
+}
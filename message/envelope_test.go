@@ -0,0 +1,84 @@
+package message_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/go-email/v2/message"
+)
+
+func TestEnvelope(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		src   string
+		from  string
+		rcpts []string
+	}{
+		{
+			"return-path",
+			"Return-path: <bounce@example.com>\nSender: sender@example.com\nFrom: Alice <alice@example.com>\nTo: Bob <bob@example.com>\n\n",
+			"bounce@example.com",
+			[]string{"bob@example.com"},
+		},
+		{
+			"null return-path",
+			"Return-path: <>\nFrom: alice@example.com\nTo: bob@example.com\n\n",
+			"",
+			[]string{"bob@example.com"},
+		},
+		{
+			"sender",
+			"Sender: Secretary <sender@example.com>\nFrom: alice@example.com\nTo: bob@example.com\n\n",
+			"sender@example.com",
+			[]string{"bob@example.com"},
+		},
+		{
+			"from",
+			"From: Alice <alice@example.com>\nTo: Bob <bob@example.com>, carol@example.com\nCc: Team: bob@EXAMPLE.com, dave@example.com;\nBcc: eve@example.com\n\n",
+			"alice@example.com",
+			[]string{"bob@example.com", "carol@example.com", "dave@example.com", "eve@example.com"},
+		},
+		{
+			"punycode",
+			"From: alice@bücher.example\nTo: José <jose@münchen.example>\n\n",
+			"alice@xn--bcher-kva.example",
+			[]string{"jose@xn--mnchen-3ya.example"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			m, err := message.Parse(strings.NewReader(test.src))
+			require.NoError(t, err)
+
+			from, rcpts, err := message.Envelope(m)
+			assert.NoError(t, err)
+			assert.Equal(t, test.from, from)
+			assert.Equal(t, test.rcpts, rcpts)
+		})
+	}
+}
+
+func TestEnvelope_Errors(t *testing.T) {
+	t.Parallel()
+
+	m, err := message.Parse(strings.NewReader("To: bob@example.com\n\n"))
+	require.NoError(t, err)
+
+	_, _, err = message.Envelope(m)
+	assert.ErrorIs(t, err, message.ErrNoEnvelopeSender)
+
+	m, err = message.Parse(strings.NewReader("From: alice@example.com\n\n"))
+	require.NoError(t, err)
+
+	_, _, err = message.Envelope(m)
+	assert.ErrorIs(t, err, message.ErrNoEnvelopeRecipients)
+}
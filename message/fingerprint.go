@@ -0,0 +1,155 @@
+package message
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/zostay/go-email/v2/message/header"
+	"github.com/zostay/go-email/v2/message/transfer"
+)
+
+// DefaultFingerprintExcludes lists the header fields that are commonly modified
+// by relays in transit. These are a reasonable choice for
+// FingerprintOptions.ExcludeHeaders when trying to find duplicate messages
+// delivered via different routes.
+var DefaultFingerprintExcludes = []string{
+	"Received",
+	"Return-path",
+	"Delivered-to",
+	"DKIM-Signature",
+	"ARC-Seal",
+	"ARC-Message-Signature",
+	"ARC-Authentication-Results",
+	"Authentication-Results",
+}
+
+// FingerprintOptions controls the canonical form used by Fingerprint().
+type FingerprintOptions struct {
+	// ExcludeHeaders names the header fields to leave out of the fingerprint.
+	// Names are matched without regard to case. These are excluded from the
+	// headers of every part, not just the top-level header.
+	ExcludeHeaders []string
+}
+
+// excluded returns true if the named field should be left out.
+func (o *FingerprintOptions) excluded(name string) bool {
+	for _, ex := range o.ExcludeHeaders {
+		if strings.EqualFold(name, ex) {
+			return true
+		}
+	}
+	return false
+}
+
+// Fingerprint returns a SHA-256 hash of a canonical form of the message. The
+// canonical form ignores cosmetic differences that are often introduced when a
+// message is relayed, so two copies of the same message ought to have the same
+// fingerprint. The canonical form is built as follows:
+//
+// * Each header field not named in ExcludeHeaders is included in order with
+// the name in lowercase and the body with any folding whitespace collapsed to
+// a single space.
+//
+// * The Content-transfer-encoding of each leaf part is decoded.
+//
+// * The line breaks of a text/* part, or of a part with a
+// Content-transfer-encoding of 7bit, 8bit, or quoted-printable, are normalized
+// to LF. Any other decoded content, such as a base64 image, is included as-is
+// because it is not made of lines.
+//
+// * Each part of a multipart message is included in order.
+//
+// In order to fingerprint an *Opaque part, its io.Reader must be read
+// completely. The Reader will be replaced with an in-memory copy of the same
+// bytes so the part may still be used afterwards. It returns an error if there
+// is a problem reading any part.
+func Fingerprint(msg Generic, opts FingerprintOptions) ([]byte, error) {
+	hash := sha256.New()
+	if err := fingerprint(hash, msg, &opts); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// fingerprint implements the recursive part of Fingerprint.
+func fingerprint(w io.Writer, part Part, opts *FingerprintOptions) error {
+	h := part.GetHeader()
+	for _, f := range h.ListFields() {
+		if opts.excluded(f.Name()) {
+			continue
+		}
+
+		_, _ = fmt.Fprintf(w, "%s:%s\n",
+			strings.ToLower(strings.TrimSpace(f.Name())),
+			strings.Join(strings.Fields(f.Body()), " "))
+	}
+	_, _ = fmt.Fprint(w, "\n")
+
+	if part.IsMultipart() {
+		for i, p := range part.GetParts() {
+			_, _ = fmt.Fprintf(w, "\n--part %d--\n", i)
+			if err := fingerprint(w, p, opts); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	content, err := readDecodedContent(part)
+	if err != nil {
+		return err
+	}
+
+	if !isLineContent(h) {
+		_, _ = w.Write(content)
+		return nil
+	}
+
+	_, _ = w.Write(normalizeBreaks(content, header.LF.Bytes()))
+
+	return nil
+}
+
+// isLineContent returns true if the decoded content of the part is made of
+// lines, which is the case for a text/* part or for a part with a
+// Content-transfer-encoding of 7bit, 8bit, or quoted-printable. A part with no
+// Content-transfer-encoding is 7bit.
+func isLineContent(h *header.Header) bool {
+	if mt, err := h.GetMediaType(); err == nil &&
+		strings.HasPrefix(strings.ToLower(mt), "text/") {
+		return true
+	}
+
+	cte, err := h.GetTransferEncoding()
+	if errors.Is(err, header.ErrNoSuchField) {
+		return true
+	}
+
+	switch strings.ToLower(strings.TrimSpace(cte)) {
+	case transfer.Bit7, transfer.Bit8, transfer.QuotedPrintable:
+		return true
+	}
+	return false
+}
+
+// normalizeBreaks replaces every CRLF, LFCR, CR, and LF in the content with the
+// given line break.
+func normalizeBreaks(content, br []byte) []byte {
+	out := make([]byte, 0, len(content))
+	for i := 0; i < len(content); i++ {
+		switch c := content[i]; c {
+		case '\r', '\n':
+			if i+1 < len(content) && content[i+1] != c &&
+				(content[i+1] == '\r' || content[i+1] == '\n') {
+				i++
+			}
+			out = append(out, br...)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
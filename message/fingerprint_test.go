@@ -0,0 +1,115 @@
+package message_test
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/go-email/v2/message"
+)
+
+func TestFingerprint(t *testing.T) {
+	t.Parallel()
+
+	const (
+		srcA = "Received: from a.example.com\n" +
+			"Subject: hello\n" +
+			"Content-type: text/plain\n" +
+			"Content-transfer-encoding: quoted-printable\n" +
+			"\n" +
+			"I =E2=9D=A4 email!\n"
+
+		srcB = "Received: from b.example.com\r\n" +
+			"Received: from c.example.com\r\n" +
+			"Subject:   hello\r\n" +
+			"Content-type: text/plain\r\n" +
+			"Content-transfer-encoding: quoted-printable\r\n" +
+			"\r\n" +
+			"I =E2=9D=A4 email!\r\n"
+
+		srcC = "Subject: goodbye\n" +
+			"Content-type: text/plain\n" +
+			"Content-transfer-encoding: quoted-printable\n" +
+			"\n" +
+			"I =E2=9D=A4 email!\n"
+	)
+
+	fingerprint := func(src string, opts message.FingerprintOptions) []byte {
+		m, err := message.Parse(strings.NewReader(src))
+		require.NoError(t, err)
+
+		fp, err := message.Fingerprint(m, opts)
+		require.NoError(t, err)
+		assert.Len(t, fp, 32)
+
+		return fp
+	}
+
+	opts := message.FingerprintOptions{
+		ExcludeHeaders: message.DefaultFingerprintExcludes,
+	}
+
+	assert.Equal(t, fingerprint(srcA, opts), fingerprint(srcB, opts))
+	assert.NotEqual(t, fingerprint(srcA, opts), fingerprint(srcC, opts))
+	assert.NotEqual(t,
+		fingerprint(srcA, message.FingerprintOptions{}),
+		fingerprint(srcB, message.FingerprintOptions{}))
+}
+
+func TestFingerprint_Binary(t *testing.T) {
+	t.Parallel()
+
+	const (
+		srcA = "Content-type: application/octet-stream\n" +
+			"Content-transfer-encoding: binary\n" +
+			"\n" +
+			"\x00\r\n\x01"
+
+		srcB = "Content-type: application/octet-stream\n" +
+			"Content-transfer-encoding: binary\n" +
+			"\n" +
+			"\x00\n\x01"
+	)
+
+	fingerprint := func(src string) []byte {
+		m, err := message.Parse(strings.NewReader(src))
+		require.NoError(t, err)
+
+		fp, err := message.Fingerprint(m, message.FingerprintOptions{})
+		require.NoError(t, err)
+
+		return fp
+	}
+
+	assert.NotEqual(t, fingerprint(srcA), fingerprint(srcB))
+}
+
+func TestFingerprint_NonTextBase64(t *testing.T) {
+	t.Parallel()
+
+	fingerprint := func(mt, body string) []byte {
+		src := "Content-type: " + mt + "\n" +
+			"Content-transfer-encoding: base64\n" +
+			"\n" +
+			base64.StdEncoding.EncodeToString([]byte(body)) + "\n"
+
+		m, err := message.Parse(strings.NewReader(src))
+		require.NoError(t, err)
+
+		fp, err := message.Fingerprint(m, message.FingerprintOptions{})
+		require.NoError(t, err)
+
+		return fp
+	}
+
+	assert.NotEqual(t,
+		fingerprint("image/png", "\x89PNG\r\n\x1a\n"),
+		fingerprint("image/png", "\x89PNG\n\x1a\n"))
+
+	assert.Equal(t,
+		fingerprint("text/plain", "one\r\ntwo\r\n"),
+		fingerprint("text/plain", "one\ntwo\n"))
+}
@@ -0,0 +1,189 @@
+package message
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/zostay/go-email/v2/message/header"
+	"github.com/zostay/go-email/v2/message/header/field"
+)
+
+// Flatten will collapse redundant multipart parts in the given message. Any
+// multipart part that contains exactly one sub-part and has no meaningful
+// preamble or epilogue (i.e., nothing but whitespace before the first boundary
+// and after the last) will be replaced by that sub-part. This is applied
+// recursively from the deepest parts up.
+//
+// When a part is collapsed, the header of the multipart part is kept, but all
+// of its Content-* fields are replaced by the Content-* fields of the sub-part.
+// This way the top-level fields like Subject and From are preserved while the
+// Content-type (and Content-transfer-encoding, etc.) reflect the content that
+// remains.
+//
+// This is never performed automatically by this library as some senders
+// deliberately send single-part multipart messages. This is intended for use
+// after a transformation, such as removing attachments, leaves such a part
+// behind.
+//
+// The returned message is either a *Opaque or a *Multipart. If no change is
+// needed, the message returned may be the same as the one passed in.
+//
+// The parts of the returned message share their bodies with the parts of the
+// original. When an *Opaque sub-part with a replayable body (see
+// Opaque.IsReplayable()) is copied into a collapsed part, the original sub-part
+// is changed to rewind the body before each write, as in EditHeader, so that
+// both may still be written.
+func Flatten(msg Generic) (Generic, error) {
+	if !msg.IsMultipart() {
+		return msg, nil
+	}
+
+	parts := msg.GetParts()
+	flatParts := make([]Part, len(parts))
+	for i, part := range parts {
+		flatPart, err := Flatten(part)
+		if err != nil {
+			return nil, err
+		}
+		flatParts[i] = flatPart
+	}
+
+	mm := rebuildMultipart(msg, flatParts)
+	if len(flatParts) != 1 ||
+		mm.rest != nil ||
+		len(bytes.TrimSpace(mm.prefix)) > 0 ||
+		len(bytes.TrimSpace(mm.suffix)) > 0 {
+		return mm, nil
+	}
+
+	child := flatParts[0]
+	h := mergeContentFields(msg.GetHeader(), child.GetHeader())
+
+	if child.IsMultipart() {
+		var (
+			prefix, suffix []byte
+			rest           io.Reader
+			raw            [][]byte
+		)
+		if cm, isMultipart := unwrapLazy(child).(*Multipart); isMultipart {
+			prefix, suffix, rest, raw = cm.prefix, cm.suffix, cm.rest, cm.raw
+		} else {
+			prefix, suffix = []byte{}, []byte{}
+		}
+
+		return &Multipart{
+			Header: *h,
+			prefix: prefix,
+			suffix: suffix,
+			parts:  child.GetParts(),
+			rest:   rest,
+			raw:    raw,
+		}, nil
+	}
+
+	if co, isOpaque := unwrapLazy(child).(*Opaque); isOpaque {
+		// clone the child, so the way the body is written is kept, but not the
+		// original bytes, which no longer match the merged header
+		om := *co
+		om.Header = *h
+		om.raw = nil
+
+		// the body is shared, so each must rewind it before writing it
+		if co.IsReplayable() {
+			co.written = true
+			om.written = true
+		}
+
+		return &om, nil
+	}
+
+	return &Opaque{
+		Header:  *h,
+		Reader:  child.GetReader(),
+		encoded: child.IsEncoded(),
+	}, nil
+}
+
+// rebuildMultipart returns a new *Multipart with the header, preamble,
+// epilogue, and any trailing content of msg, but with the given parts. If msg
+// has no boundary set, a new one is generated.
+//
+// The original bytes kept for the parts of a parsed multipart/signed message
+// are carried over for each part that is unchanged, so SignedContent() still
+// returns the bytes that were signed. A part that was replaced has no original
+// bytes and will be serialized instead.
+func rebuildMultipart(msg Part, parts []Part) *Multipart {
+	mm := &Multipart{
+		Header: *msg.GetHeader(),
+		prefix: []byte{},
+		suffix: []byte{},
+		parts:  parts,
+	}
+
+	if om, isMultipart := unwrapLazy(msg).(*Multipart); isMultipart {
+		mm.prefix, mm.suffix, mm.rest = om.prefix, om.suffix, om.rest
+		mm.raw = keepRawParts(om, parts)
+	}
+
+	if _, err := mm.GetBoundary(); err != nil {
+		_ = mm.SetBoundary(GenerateBoundary())
+	}
+
+	return mm
+}
+
+// keepRawParts returns the original bytes of each of the given parts that is
+// also one of the parts of om, in the same order as parts. It returns nil if om
+// has no original bytes.
+func keepRawParts(om *Multipart, parts []Part) [][]byte {
+	if len(om.raw) == 0 {
+		return nil
+	}
+
+	rawByPart := make(map[Part][]byte, len(om.raw))
+	for i, p := range om.parts {
+		if i < len(om.raw) {
+			rawByPart[p] = om.raw[i]
+		}
+	}
+
+	raw := make([][]byte, len(parts))
+	for i, p := range parts {
+		raw[i] = rawByPart[p]
+	}
+
+	return raw
+}
+
+// isContentField returns true if the named field is one of the Content-*
+// fields describing the content of a part.
+func isContentField(name string) bool {
+	return strings.HasPrefix(strings.ToLower(name), "content-")
+}
+
+// mergeContentFields returns a new header made from the fields of the outer
+// header, except that all of its Content-* fields are replaced by the Content-*
+// fields of the inner header. The original field formatting is preserved.
+func mergeContentFields(outer, inner *header.Header) *header.Header {
+	h := &header.Header{}
+	h.SetBreak(outer.Break())
+	h.SetFoldEncoding(outer.FoldEncoding())
+
+	fields := make([]*field.Field, 0, outer.Len()+inner.Len())
+	for _, f := range outer.ListFields() {
+		if !isContentField(f.Name()) {
+			fields = append(fields, f.Clone())
+		}
+	}
+
+	for _, f := range inner.ListFields() {
+		if isContentField(f.Name()) {
+			fields = append(fields, f.Clone())
+		}
+	}
+
+	h.InsertFields(0, fields...)
+
+	return h
+}
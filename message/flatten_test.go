@@ -0,0 +1,224 @@
+package message_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/go-email/v2/message"
+)
+
+func TestFlatten(t *testing.T) {
+	t.Parallel()
+
+	const src = `Subject: flatten me
+Content-type: multipart/mixed; boundary=outer
+
+--outer
+Content-type: multipart/alternative; boundary=inner
+
+--inner
+Content-type: text/plain
+
+Plain text.
+--inner
+Content-type: text/html
+
+<p>HTML text.</p>
+--inner--
+--outer--
+`
+
+	m, err := message.Parse(strings.NewReader(src))
+	require.NoError(t, err)
+
+	fm, err := message.Flatten(m)
+	require.NoError(t, err)
+	require.True(t, fm.IsMultipart())
+
+	subj, err := fm.GetHeader().GetSubject()
+	assert.NoError(t, err)
+	assert.Equal(t, "flatten me", subj)
+
+	mt, err := fm.GetHeader().GetMediaType()
+	assert.NoError(t, err)
+	assert.Equal(t, "multipart/alternative", mt)
+
+	assert.Len(t, fm.GetParts(), 2)
+
+	const expect = `Subject: flatten me
+Content-type: multipart/alternative; boundary=inner
+
+--inner
+Content-type: text/plain
+
+Plain text.
+--inner
+Content-type: text/html
+
+<p>HTML text.</p>
+--inner--`
+
+	buf := &bytes.Buffer{}
+	_, err = fm.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, expect, buf.String())
+}
+
+func TestFlatten_Leaf(t *testing.T) {
+	t.Parallel()
+
+	buf := &message.Buffer{}
+	buf.SetSubject("one part")
+	buf.SetMediaType("multipart/mixed")
+	buf.Add(makePart())
+
+	fm, err := message.Flatten(buf)
+	require.NoError(t, err)
+	assert.False(t, fm.IsMultipart())
+
+	mt, err := fm.GetHeader().GetMediaType()
+	assert.NoError(t, err)
+	assert.Equal(t, "text/html", mt)
+
+	out := &bytes.Buffer{}
+	_, err = fm.WriteTo(out)
+	assert.NoError(t, err)
+	assert.Equal(t, "Subject: one part\nContent-type: text/html\n\nTest message.", out.String())
+}
+
+func TestFlatten_KeepsPreamble(t *testing.T) {
+	t.Parallel()
+
+	const src = `Content-type: multipart/mixed; boundary=outer
+
+This is a preamble.
+--outer
+Content-type: text/plain
+
+Plain text.
+--outer--
+`
+
+	m, err := message.Parse(strings.NewReader(src))
+	require.NoError(t, err)
+
+	fm, err := message.Flatten(m)
+	require.NoError(t, err)
+	assert.True(t, fm.IsMultipart())
+
+	buf := &bytes.Buffer{}
+	_, err = fm.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, src, buf.String())
+}
+
+func TestFlatten_LazyParts(t *testing.T) {
+	t.Parallel()
+
+	const src = `Subject: flatten me
+Content-type: multipart/mixed; boundary=outer
+
+--outer
+Content-type: multipart/alternative; boundary=inner
+
+Inner preamble.
+--inner
+Content-type: text/plain
+
+Plain text.
+--inner
+Content-type: text/html
+
+<p>HTML text.</p>
+--inner--
+Inner epilogue.
+--outer--
+`
+
+	m, err := message.Parse(strings.NewReader(src), message.WithLazyParts())
+	require.NoError(t, err)
+
+	fm, err := message.Flatten(m)
+	require.NoError(t, err)
+	require.True(t, fm.IsMultipart())
+
+	const expect = `Subject: flatten me
+Content-type: multipart/alternative; boundary=inner
+
+Inner preamble.
+--inner
+Content-type: text/plain
+
+Plain text.
+--inner
+Content-type: text/html
+
+<p>HTML text.</p>
+--inner--
+Inner epilogue.`
+
+	buf := &bytes.Buffer{}
+	_, err = fm.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, expect, buf.String())
+}
+
+func TestFlatten_KeepsOpaqueState(t *testing.T) {
+	t.Parallel()
+
+	const src = `Subject: one part
+Content-type: multipart/mixed; boundary=outer
+
+--outer
+Content-type: application/octet-stream
+Content-transfer-encoding: base64
+
+AAECAwQFBgcICQoL
+DA0ODxAREhMUFRYX
+--outer--
+`
+
+	m, err := message.Parse(strings.NewReader(src), message.DecodeTransferEncoding())
+	require.NoError(t, err)
+
+	fm, err := message.Flatten(m)
+	require.NoError(t, err)
+	require.IsType(t, &message.Opaque{}, fm)
+	assert.False(t, fm.IsEncoded())
+
+	// the base64 is wrapped at the same line length as the original
+	buf := &bytes.Buffer{}
+	_, err = fm.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "Subject: one part\n"+
+		"Content-type: application/octet-stream\n"+
+		"Content-transfer-encoding: base64\n"+
+		"\n"+
+		"AAECAwQFBgcICQoL\nDA0ODxAREhMUFRYX", strings.TrimRight(buf.String(), "\n"))
+}
+
+func TestFlatten_SanitizedInvalidFieldName(t *testing.T) {
+	t.Parallel()
+
+	const src = "X Bad: a\x01b\n" +
+		"Content-type: multipart/mixed; boundary=outer\n" +
+		"\n" +
+		"--outer\n" +
+		"Content-type: text/plain\n" +
+		"\n" +
+		"Hello.\n" +
+		"--outer--\n"
+
+	m, err := message.Parse(strings.NewReader(src))
+	require.NoError(t, err)
+	assert.Equal(t, 1, m.GetHeader().SanitizeControlChars())
+
+	var fm message.Part
+	assert.NotPanics(t, func() { fm, err = message.Flatten(m) })
+	require.NoError(t, err)
+	assert.Equal(t, "a b", fm.GetHeader().GetOr("X Bad", ""))
+}
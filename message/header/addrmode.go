@@ -0,0 +1,105 @@
+package header
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/zostay/go-addr/pkg/addr"
+
+	"github.com/zostay/go-email/v2/message/header/field"
+)
+
+// AddressParseMode selects what the address getters of Header do with a field
+// that is not a strictly valid address list. It is set for the whole program
+// with SetAddressParseMode.
+type AddressParseMode int32
+
+// These are the address parse modes. AddressParseLenient is the default.
+const (
+	// AddressParseLenient falls back on the lenient parser (see
+	// ParseAddressList) whenever the strict parse fails. The getters never
+	// fail because of a bad address.
+	AddressParseLenient AddressParseMode = iota
+
+	// AddressParseStrict makes the getters return the error from the strict
+	// parse instead of falling back on the lenient parser.
+	AddressParseStrict
+
+	// AddressParseLenientWithWarning falls back on the lenient parser just like
+	// AddressParseLenient, but records an *AddressParseWarning on the header
+	// each time, which can be retrieved with AddressWarnings.
+	AddressParseLenientWithWarning
+)
+
+// addressParseMode holds the current AddressParseMode.
+var addressParseMode int32
+
+// SetAddressParseMode sets the AddressParseMode used by GetAddressList,
+// GetAllAddressLists, and the getters built on them (GetFrom, GetTo, etc.) of
+// every Header. This allows an application that expects valid addresses to
+// have bad ones reported rather than silently patched over. It is safe to call
+// from multiple goroutines, but it is intended to be set once during program
+// startup. Values already cached by a header are not parsed again.
+//
+// This does not affect ParseAddressList or GetAddressListStrict, which always
+// behave the same way.
+func SetAddressParseMode(mode AddressParseMode) {
+	atomic.StoreInt32(&addressParseMode, int32(mode))
+}
+
+// GetAddressParseMode returns the AddressParseMode set with
+// SetAddressParseMode.
+func GetAddressParseMode() AddressParseMode {
+	return AddressParseMode(atomic.LoadInt32(&addressParseMode))
+}
+
+// AddressParseWarning is recorded on the header when a field is not a strictly
+// valid address list and AddressParseLenientWithWarning is in effect.
+type AddressParseWarning struct {
+	// Field is the name of the field that failed the strict parse.
+	Field string
+
+	// Err is the error returned by the strict parse.
+	Err error
+}
+
+// Error returns the warning message.
+func (w *AddressParseWarning) Error() string {
+	return fmt.Sprintf("lenient parse used for %s field: %v", w.Field, w.Err)
+}
+
+// Unwrap returns the error returned by the strict parse.
+func (w *AddressParseWarning) Unwrap() error {
+	return w.Err
+}
+
+// AddressWarnings returns the warnings recorded while parsing address fields
+// using AddressParseLenientWithWarning, in the order they were recorded. It
+// returns nil if there are none.
+func (h *Header) AddressWarnings() []*AddressParseWarning {
+	return h.addressWarnings
+}
+
+// parseAddressField parses the address list in the named field according to
+// the current AddressParseMode. The encoded and decoded bodies are used as for
+// parseEncodedAddressList.
+func (h *Header) parseAddressField(name, encoded, decoded string) (addr.AddressList, error) {
+	al, err := parseAddressListStrict(field.RepairEncodedWords(encoded))
+	if err == nil {
+		return h.decodeDisplayNames(al), nil
+	}
+
+	switch GetAddressParseMode() {
+	case AddressParseStrict:
+		return nil, err
+	case AddressParseLenientWithWarning:
+		if !h.frozen {
+			h.addressWarnings = append(h.addressWarnings, &AddressParseWarning{
+				Field: name,
+				Err:   err,
+			})
+		}
+	}
+
+	return ParseAddressList(decoded), nil
+}
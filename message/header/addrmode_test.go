@@ -0,0 +1,72 @@
+package header_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/go-email/v2/message/header"
+)
+
+func TestSetAddressParseMode(t *testing.T) { //nolint:paralleltest // testing globals
+	// Do not test in parallel. The mode is global, so changing it would affect
+	// any other test running at the same time.
+
+	defer header.SetAddressParseMode(header.GetAddressParseMode())
+
+	const src = "From: Good <good@example.com>\n" +
+		"To: bad address, worse@\n"
+
+	parse := func() *header.Header {
+		h, err := header.Parse([]byte(src), header.LF)
+		require.NoError(t, err)
+		return h
+	}
+
+	assert.Equal(t, header.AddressParseLenient, header.GetAddressParseMode())
+
+	h := parse()
+	to, err := h.GetTo()
+	assert.NoError(t, err)
+	assert.Len(t, to, 2)
+	assert.Nil(t, h.AddressWarnings())
+
+	header.SetAddressParseMode(header.AddressParseStrict)
+	assert.Equal(t, header.AddressParseStrict, header.GetAddressParseMode())
+
+	h = parse()
+	from, err := h.GetFrom()
+	assert.NoError(t, err)
+	assert.Equal(t, "good@example.com", from[0].Address())
+
+	to, err = h.GetTo()
+	assert.Error(t, err)
+	assert.Nil(t, to)
+
+	_, err = h.GetAllAddressLists(header.To)
+	assert.Error(t, err)
+
+	header.SetAddressParseMode(header.AddressParseLenientWithWarning)
+
+	h = parse()
+	_, err = h.GetFrom()
+	assert.NoError(t, err)
+	assert.Nil(t, h.AddressWarnings())
+
+	to, err = h.GetTo()
+	assert.NoError(t, err)
+	assert.Len(t, to, 2)
+
+	ws := h.AddressWarnings()
+	require.Len(t, ws, 1)
+	assert.Equal(t, header.To, ws[0].Field)
+	assert.Error(t, ws[0].Err)
+	assert.ErrorIs(t, ws[0], ws[0].Err)
+
+	// cached, so no new warning
+	_, err = h.GetTo()
+	assert.NoError(t, err)
+	assert.Len(t, h.AddressWarnings(), 1)
+	assert.Len(t, h.Clone().AddressWarnings(), 1)
+}
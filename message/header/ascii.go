@@ -0,0 +1,122 @@
+package header
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/zostay/go-email/v2/message/header/field"
+	"github.com/zostay/go-email/v2/message/header/param"
+)
+
+// addressFields names the fields (in lowercase) that hold an address list, so
+// EncodeNonASCII only encodes the display names in them.
+var addressFields = map[string]bool{
+	"from":          true,
+	"sender":        true,
+	"reply-to":      true,
+	"to":            true,
+	"cc":            true,
+	"bcc":           true,
+	"resent-from":   true,
+	"resent-sender": true,
+	"resent-to":     true,
+	"resent-cc":     true,
+	"resent-bcc":    true,
+}
+
+// EncodeNonASCII prepares the header for a transport that only permits 7-bit
+// data. This is the header counterpart to downgrading the transfer encoding of
+// the body. Each field that would be written with bytes outside of US-ASCII
+// is given a new Raw value with those characters MIME word encoded per RFC
+// 2047 in UTF-8. Text that is mostly ASCII is Q encoded and other text is B
+// encoded. The decoded bodies are not changed, so the getters return the same
+// values as before and only the output of WriteTo is affected. It returns the
+// number of fields changed.
+//
+// A field with a Raw value that is already ASCII is skipped, as is a field with
+// no Raw value and an ASCII body. Structured fields are encoded according to
+// their structure:
+//
+// * Only the display names of address fields (From, To, Cc, etc.) are encoded.
+// An addr-spec with non-ASCII characters cannot be word encoded and is left
+// as-is.
+//
+// * The parameters of Content-type and Content-disposition are encoded using
+// the RFC 2231 parameter encoding. Only the parameters with non-ASCII values
+// are changed. The rest keep their position and original text.
+//
+// * The body of any other field is encoded as a whole.
+//
+// The new Raw values are folded with the FoldEncoding of the header.
+func (h *Header) EncodeNonASCII() int {
+	changed := 0
+	for _, f := range h.ListFields() {
+		if f.Raw != nil && isASCII(f.Raw.Bytes()) {
+			continue
+		} else if f.Raw == nil && isASCII([]byte(f.Body())) {
+			continue
+		}
+
+		h.setEncodedRaw(f, h.encodeBody(f))
+		changed++
+	}
+	return changed
+}
+
+// encodeBody returns the body of the field with the characters outside of
+// US-ASCII encoded according to the structure of the field.
+func (h *Header) encodeBody(f *field.Field) string {
+	switch name := strings.ToLower(f.Name()); {
+	case addressFields[name]:
+		al := h.parseEncodedAddressList(encodedBody(f), f.Body())
+		return encodeDisplayNames(al).String()
+
+	case name == strings.ToLower(ContentType),
+		name == strings.ToLower(ContentDisposition):
+		pv, err := param.Parse(f.Body())
+		if err != nil {
+			break
+		}
+
+		return encodeParams(pv).String()
+	}
+
+	return encodeWords(f.Body())
+}
+
+// encodeParams returns a copy of the parameterized value with each parameter
+// holding characters outside of US-ASCII RFC 2231 encoded. Every other
+// parameter keeps its position and its original text.
+func encodeParams(pv *param.Value) *param.Value {
+	var changes []param.Modifier
+	for k, v := range pv.Parameters() {
+		if isASCII([]byte(v)) {
+			continue
+		}
+
+		// clearing the parameter first discards its original text, so it is
+		// encoded anew when it is set again, keeping its position
+		changes = append(changes, param.Set(k, ""), param.Set(k, v))
+	}
+
+	return param.Modify(pv, changes...)
+}
+
+// setEncodedRaw replaces the Raw value of the field with the field name and
+// the given encoded body, folded using the FoldEncoding of the header.
+func (h *Header) setEncodedRaw(f *field.Field, body string) {
+	buf := &bytes.Buffer{}
+	_, _ = h.FoldEncoding().Fold(buf, []byte(f.Name()+": "+body), field.Break(h.lbr))
+	f.SetRaw(bytes.TrimSuffix(buf.Bytes(), h.lbr.Bytes()))
+}
+
+// isASCII returns true if every byte is in the US-ASCII range.
+func isASCII(b []byte) bool {
+	for _, c := range b {
+		if c >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package header_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/go-email/v2/message/header"
+)
+
+func TestHeader_EncodeNonASCII(t *testing.T) {
+	t.Parallel()
+
+	const src = "Subject: Grüße aus München\n" +
+		"From: José Núñez <jose@example.com>\n" +
+		"To: 山田太郎 <taro@example.jp>, plain@example.com\n" +
+		"Comments: 会議の議事録\n" +
+		"Content-disposition: attachment; size=10; filename=\"résumé.pdf\"; Creation-Date=\"Mon, 1 Jan 2001 00:00:00 +0000\"\n" +
+		"X-Plain: nothing to see\n"
+
+	h, err := header.Parse([]byte(src), header.LF)
+	require.NoError(t, err)
+
+	h.SetContentDescription("Ελληνικά")
+
+	assert.Equal(t, 6, h.EncodeNonASCII())
+
+	buf := &bytes.Buffer{}
+	_, err = h.WriteTo(buf)
+	require.NoError(t, err)
+
+	const expect = "Subject: =?utf-8?q?Gr=C3=BC=C3=9Fe_aus_M=C3=BCnchen?=\n" +
+		"From: =?utf-8?q?Jos=C3=A9_N=C3=BA=C3=B1ez?= <jose@example.com>\n" +
+		"To: =?utf-8?b?5bGx55Sw5aSq6YOO?= <taro@example.jp>, plain@example.com\n" +
+		"Comments: =?utf-8?b?5Lya6K2w44Gu6K2w5LqL6Yyy?=\n" +
+		"Content-disposition: attachment; size=10; filename*=utf-8''r%C3%A9sum%C3%A9.pdf; Creation-Date=\"Mon, 1 Jan 2001 00:00:00 +0000\"\n" +
+		"X-Plain: nothing to see\n" +
+		"Content-description: =?utf-8?b?zpXOu867zrfOvc65zrrOrA==?=\n" +
+		"\n"
+	assert.Equal(t, expect, buf.String())
+
+	// the getters still return the decoded values
+	s, err := h.GetSubject()
+	assert.NoError(t, err)
+	assert.Equal(t, "Grüße aus München", s)
+
+	fn, err := h.GetFilename()
+	assert.NoError(t, err)
+	assert.Equal(t, "résumé.pdf", fn)
+
+	// the other parameters are read just as before
+	cd, err := h.GetContentDisposition()
+	assert.NoError(t, err)
+	assert.Equal(t, "Mon, 1 Jan 2001 00:00:00 +0000", cd.Parameter("creation-date"))
+
+	// and so does the header parsed from the output
+	rh, err := header.Parse(bytes.TrimSuffix(buf.Bytes(), []byte("\n\n")), header.LF)
+	require.NoError(t, err)
+
+	for _, name := range []string{header.Subject, header.Comments, header.ContentDescription} {
+		b, err := rh.Get(name)
+		assert.NoError(t, err)
+		assert.Equal(t, h.GetOr(name, ""), b)
+	}
+
+	from, err := rh.GetFrom()
+	assert.NoError(t, err)
+	assert.Equal(t, "José Núñez", from[0].DisplayName())
+
+	to, err := rh.GetTo()
+	assert.NoError(t, err)
+	assert.Equal(t, "山田太郎", to[0].DisplayName())
+
+	// nothing left to encode
+	assert.Equal(t, 0, h.EncodeNonASCII())
+}
@@ -1,6 +1,7 @@
 package header
 
 import (
+	"bytes"
 	"errors"
 	"io"
 	"strings"
@@ -19,6 +20,8 @@ var (
 type Base struct {
 	lbr    Break
 	vf     *field.FoldEncoding
+	wt     func(name, body string) string
+	utf8   bool
 	fields []*field.Field
 }
 
@@ -32,6 +35,8 @@ func (h *Base) Clone() *Base {
 	return &Base{
 		lbr:    h.lbr,
 		vf:     h.vf,
+		wt:     h.wt,
+		utf8:   h.utf8,
 		fields: fs,
 	}
 }
@@ -59,6 +64,31 @@ func (h *Base) SetFoldEncoding(vf *field.FoldEncoding) {
 	h.vf = vf
 }
 
+// SetWriteTransform sets a function that will be applied to the body of each
+// field as the header is written by WriteTo(). The function is given the name
+// and body of the field and returns the body to write in its place. The fields
+// stored in the header are not modified. Fields with a Raw value are written
+// as-is and are not passed through the transform. Set to nil to disable.
+func (h *Base) SetWriteTransform(fn func(name, body string) string) {
+	h.wt = fn
+}
+
+// SetUTF8Allowed controls whether the bodies of fields may be written as raw
+// UTF-8, as permitted by RFC 6532 for messages sent via SMTPUTF8. Normally,
+// WriteTo() will MIME word encode any field body containing non-ASCII
+// characters. When set to true, those bodies are written as-is instead. Fields
+// with a Raw value are always written as-is either way. Only enable this when
+// the message is destined for a path known to support internationalized email.
+func (h *Base) SetUTF8Allowed(allowed bool) {
+	h.utf8 = allowed
+}
+
+// UTF8Allowed returns true if field bodies will be written as raw UTF-8. See
+// SetUTF8Allowed.
+func (h *Base) UTF8Allowed() bool {
+	return h.utf8
+}
+
 // Break returns the line break used to separate header fields and terminate the
 // header.
 func (h *Base) Break() Break {
@@ -88,7 +118,26 @@ func (h *Base) Len() int {
 
 // GetFieldNamed returns the nth (0-indexed) with the given name or nil if no such
 // header field is set.
+//
+// A negative n counts back from the last field with the given name, so -1
+// returns the last such field, -2 the one before it, and so on. This is handy
+// for getting the most recent of a repeated field, such as Received. If there
+// are fewer than -n fields with that name, it returns nil.
 func (h *Base) GetFieldNamed(name string, n int) *field.Field {
+	if n < 0 {
+		n = -n - 1
+		for i := len(h.fields) - 1; i >= 0; i-- {
+			f := h.fields[i]
+			if strings.EqualFold(f.Name(), name) {
+				if n == 0 {
+					return f
+				}
+				n--
+			}
+		}
+		return nil
+	}
+
 	for _, f := range h.fields {
 		if strings.EqualFold(f.Name(), name) {
 			if n == 0 {
@@ -130,9 +179,17 @@ func (h *Base) ListFields() []*field.Field {
 	return fs
 }
 
-// WriteTo will write the contents of the header to the given io.Writer.
+// WriteTo will write the contents of the header to the given io.Writer. The
+// fields are written to the io.Writer one at a time as they are folded, so no
+// more than a single field is held in memory at once, no matter how large the
+// header is.
 func (h *Base) WriteTo(w io.Writer) (int64, error) {
-	total := int64(0)
+	var (
+		total int64
+		lbr   = h.lbr.Bytes()
+		fb    []byte // reused to hold each field before folding
+	)
+
 	for _, f := range h.fields {
 		if f.Raw != nil {
 			// when Raw is present, write it as-is
@@ -142,32 +199,60 @@ func (h *Base) WriteTo(w io.Writer) (int64, error) {
 				return total, err
 			}
 
-			n, err = w.Write(h.lbr.Bytes())
+			n, err = w.Write(lbr)
 			total += int64(n)
 			if err != nil {
 				return total, err
 			}
 		} else {
+			// apply the write transform, if any, to a copy of the field
+			if h.wt != nil {
+				f = field.New(f.Name(), h.wt(f.Name(), f.Body()))
+			}
+
+			// this is the same as f.Bytes(), but reuses fb
+			body := f.Body()
+			if !h.utf8 {
+				body = field.Encode(body)
+			}
+
+			fb = append(fb[:0], f.Name()...)
+			fb = append(fb, ": "...)
+			fb = append(fb, body...)
+
 			// otherwise, apply folding and other such output magic
-			n, err := h.FoldEncoding().Fold(w, f.Bytes(), field.Break(h.lbr))
+			n, err := h.FoldEncoding().Fold(w, fb, lbr)
 			total += n
 			if err != nil {
 				return total, err
 			}
 		}
 	}
-	n, err := w.Write(h.lbr.Bytes())
+	n, err := w.Write(lbr)
 	total += int64(n)
 	return total, err
 }
 
+// RawBytes returns the complete header block exactly as WriteTo would write it,
+// including the blank line that ends the header. When every field still has
+// its Raw value, as with a header that was parsed and not modified since, this
+// is identical to the original bytes of the header. This allows a modified
+// header to be put back together with an untouched body.
+func (h *Base) RawBytes() []byte {
+	buf := &bytes.Buffer{}
+	_, _ = h.WriteTo(buf)
+	return buf.Bytes()
+}
+
 // InsertBeforeField will insert the given name and body values into the header
-// at the given index.
+// at the given index. This method panics if the name is not valid according to
+// ValidFieldName.
 func (h *Base) InsertBeforeField(
 	n int,
 	name,
 	body string,
 ) {
+	mustBeValidFieldName(name)
 	h.initBase()
 
 	// cap the range of n to 0..len(h.fields)
@@ -191,6 +276,40 @@ func (h *Base) InsertBeforeField(
 	h.fields[n] = f
 }
 
+// InsertFields inserts all the given fields into the header at the given
+// index at once, keeping them in the order given. This is more efficient than
+// calling InsertBeforeField once per field when building a large header. The
+// index is capped to the range of the fields already in the header.
+//
+// The fields are inserted as given, not copied. Use Clone on each field first
+// if the fields belong to another header that may be modified.
+//
+// Unlike InsertBeforeField, the names of the fields are not checked, so fields
+// parsed from a message may always be copied from one header to another, even
+// if their names are not valid according to ValidFieldName.
+func (h *Base) InsertFields(n int, fields ...*field.Field) {
+	h.initBase()
+
+	if len(fields) == 0 {
+		return
+	}
+
+	// cap the range of n to 0..len(h.fields)
+	if n < 0 {
+		n = 0
+	}
+	if n > len(h.fields) {
+		n = len(h.fields)
+	}
+
+	// make room for the new fields in one allocation
+	h.fields = append(h.fields, fields...)
+
+	// move existing fields out of the way and insert
+	copy(h.fields[n+len(fields):], h.fields[n:len(h.fields)-len(fields)])
+	copy(h.fields[n:], fields)
+}
+
 // ClearFields removes all fields from the header.
 func (h *Base) ClearFields() {
 	h.initBase()
@@ -215,3 +334,20 @@ func (h *Base) DeleteField(n int) error {
 
 	return nil
 }
+
+// DeleteAll removes every field with the given name from the header. The name
+// is matched without regard to case. It returns the number of fields removed.
+func (h *Base) DeleteAll(name string) int {
+	h.initBase()
+
+	kept := h.fields[:0]
+	for _, f := range h.fields {
+		if !strings.EqualFold(f.Name(), name) {
+			kept = append(kept, f)
+		}
+	}
+
+	n := len(h.fields) - len(kept)
+	h.fields = kept
+	return n
+}
@@ -2,11 +2,13 @@ package header_test
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/zostay/go-email/v2/message"
 	"github.com/zostay/go-email/v2/message/header"
 	"github.com/zostay/go-email/v2/message/header/field"
 )
@@ -46,6 +48,9 @@ func TestBase(t *testing.T) {
 		func(b *header.Base) {
 			b.InsertBeforeField(0, "Subject", "testing")
 		},
+		func(b *header.Base) {
+			b.InsertFields(0, field.New("Subject", "testing"))
+		},
 		func(b *header.Base) {
 			b.ClearFields()
 		},
@@ -124,6 +129,21 @@ func TestBase_GetFieldNamed(t *testing.T) {
 	f = b.GetFieldNamed("H", 0)
 	assert.Nil(t, f)
 
+	f = b.GetFieldNamed("E", -1)
+	assert.Equal(t, field.New("E", "g"), f)
+
+	f = b.GetFieldNamed("E", -2)
+	assert.Equal(t, field.New("E", "f"), f)
+
+	f = b.GetFieldNamed("E", -3)
+	assert.Nil(t, f)
+
+	f = b.GetFieldNamed("A", -1)
+	assert.Equal(t, field.New("A", "b"), f)
+
+	f = b.GetFieldNamed("H", -1)
+	assert.Nil(t, f)
+
 	assert.Equal(t, 4, b.Len())
 }
 
@@ -232,6 +252,64 @@ E: g
 	assert.Equal(t, expect, buf.String())
 }
 
+func TestBase_RawBytes(t *testing.T) {
+	t.Parallel()
+
+	const src = "Received: from a\r\n" +
+		"  by b;  Mon, 2 Jan 2006 15:04:05 -0700\r\n" +
+		"subject:no space\r\n" +
+		"To:   =?utf-8?Q?Jos=C3=A9?=   <jose@example.com>\r\n" +
+		"\r\n"
+
+	const body = "body\r\n"
+
+	m, err := message.Parse(strings.NewReader(src + body))
+	require.NoError(t, err)
+
+	h := m.GetHeader()
+	assert.Equal(t, src, string(h.RawBytes()))
+
+	// reassemble the message from a modified header and the original body
+	h.SetSubject("new")
+	assert.Equal(t, "Received: from a\r\n"+
+		"  by b;  Mon, 2 Jan 2006 15:04:05 -0700\r\n"+
+		"Subject: new\r\n"+
+		"To:   =?utf-8?Q?Jos=C3=A9?=   <jose@example.com>\r\n"+
+		"\r\n", string(h.RawBytes()))
+}
+
+func TestBase_SetWriteTransform(t *testing.T) {
+	t.Parallel()
+
+	b := &header.Base{}
+	b.InsertBeforeField(0, "A", "b")
+	b.InsertBeforeField(1, "X-Secret", "hunter2")
+	b.SetWriteTransform(func(name, body string) string {
+		if strings.EqualFold(name, "X-Secret") {
+			return "REDACTED"
+		}
+		return body
+	})
+
+	const expect = `A: b
+X-Secret: REDACTED
+
+`
+
+	buf := &bytes.Buffer{}
+	_, err := b.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, expect, buf.String())
+	assert.Equal(t, "hunter2", b.GetField(1).Body())
+
+	// raw fields are not transformed
+	b.GetField(1).SetRaw([]byte("X-Secret: hunter2"))
+	buf.Reset()
+	_, err = b.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "A: b\nX-Secret: hunter2\n\n", buf.String())
+}
+
 func TestBase_InsertBeforeField(t *testing.T) {
 	t.Parallel()
 
@@ -268,6 +346,35 @@ L: m
 	assert.Equal(t, expect, buf.String())
 }
 
+func TestBase_InsertFields(t *testing.T) {
+	t.Parallel()
+
+	b := &header.Base{}
+	b.InsertBeforeField(0, "A", "b")
+	b.InsertBeforeField(1, "C", "d")
+
+	b.InsertFields(1, field.New("E", "f"), field.New("G", "h"))
+	b.InsertFields(-5, field.New("I", "j"))
+	b.InsertFields(20, field.New("K", "l"), field.New("M", "n"))
+	b.InsertFields(2)
+
+	const expect = `I: j
+A: b
+E: f
+G: h
+C: d
+K: l
+M: n
+
+`
+
+	buf := &bytes.Buffer{}
+	_, err := b.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, expect, buf.String())
+	assert.Equal(t, []int{3}, b.GetIndexesNamed("G"))
+}
+
 func TestBase_ClearFields(t *testing.T) {
 	t.Parallel()
 
@@ -327,3 +434,43 @@ func TestBase_DeleteField(t *testing.T) {
 
 	assert.Nil(t, b.GetField(0))
 }
+
+func TestBase_DeleteAll(t *testing.T) {
+	t.Parallel()
+
+	b := &header.Base{}
+	b.InsertBeforeField(0, "A", "b")
+	b.InsertBeforeField(1, "E", "f")
+	b.InsertBeforeField(2, "C", "d")
+	b.InsertBeforeField(3, "e", "g")
+
+	assert.Equal(t, 2, b.DeleteAll("E"))
+	assert.Equal(t, 2, b.Len())
+	assert.Equal(t, field.New("A", "b"), b.GetField(0))
+	assert.Equal(t, field.New("C", "d"), b.GetField(1))
+
+	assert.Equal(t, 0, b.DeleteAll("E"))
+	assert.Equal(t, 2, b.Len())
+}
+
+func TestBase_SetUTF8Allowed(t *testing.T) {
+	t.Parallel()
+
+	b := &header.Base{}
+	b.InsertBeforeField(0, "Subject", "Café menu")
+	assert.False(t, b.UTF8Allowed())
+
+	buf := &bytes.Buffer{}
+	_, err := b.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "Subject: =?utf-8?b?Q2Fmw6kgbWVudQ==?=\n\n", buf.String())
+
+	b.SetUTF8Allowed(true)
+	assert.True(t, b.UTF8Allowed())
+	assert.True(t, b.Clone().UTF8Allowed())
+
+	buf.Reset()
+	_, err = b.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "Subject: Café menu\n\n", buf.String())
+}
@@ -0,0 +1,88 @@
+package header
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding/ianaindex"
+
+	"github.com/zostay/go-email/v2/message/header/field"
+)
+
+// DefaultCharsetReader is the charset reader used to decode MIME encoded words
+// in a header unless another is set with SetCharsetReader. It is backed by the
+// IANA index in golang.org/x/text, so it handles nearly any charset found in
+// the wild, such as ISO-8859-15 or Shift_JIS. Any charset missing from that
+// index is handed to field.CharsetDecoder instead.
+func DefaultCharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	e, err := ianaindex.MIME.Encoding(charset)
+	if err == nil && e != nil {
+		return e.NewDecoder().Reader(input), nil
+	}
+
+	r, ferr := field.CharsetDecoderToCharsetReader(field.CharsetDecoder)(charset, input)
+	if ferr != nil {
+		return nil, fmt.Errorf("unsupported charset %q: %w", charset, ferr)
+	}
+
+	return r, nil
+}
+
+// SetCharsetReader sets the function used to transform the text of MIME
+// encoded words in charsets other than UTF-8, ISO-8859-1, and US-ASCII into
+// native unicode. It works just like the CharsetReader of mime.WordDecoder.
+// Setting it to nil restores DefaultCharsetReader.
+//
+// The body of every field that was parsed is decoded again using the new
+// charset reader and any cached values are discarded. The original bytes of
+// each field are kept, so this does not change how the header is written.
+func (h *Header) SetCharsetReader(fn func(charset string, input io.Reader) (io.Reader, error)) {
+	h.charsetReader = fn
+	for _, f := range h.fields {
+		h.decodeField(f)
+	}
+	h.valueCache = nil
+}
+
+// getCharsetReader returns the charset reader set with SetCharsetReader or
+// DefaultCharsetReader if none has been set.
+func (h *Header) getCharsetReader() func(string, io.Reader) (io.Reader, error) {
+	if h.charsetReader == nil {
+		return DefaultCharsetReader
+	}
+	return h.charsetReader
+}
+
+// decodeField replaces the body of a parsed field with its original body
+// decoded using the charset reader of the header. Fields that were not parsed
+// or that have no MIME encoded words are left alone. If the original body
+// cannot be decoded, it is used as-is.
+func (h *Header) decodeField(f *field.Field) {
+	if f.Raw == nil {
+		return
+	}
+
+	body := encodedBody(f)
+	if !strings.Contains(body, "=?") {
+		return
+	}
+
+	if d, err := field.DecodeWithLenient(body, h.getCharsetReader()); err == nil {
+		body = d
+	}
+
+	// set the body on Base to keep Raw
+	f.Base.SetBody(body)
+}
+
+// decodeWords decodes the MIME encoded words in a display name using the
+// charset reader of the header. The display name is returned as-is if it cannot
+// be decoded.
+func (h *Header) decodeWords(dn string) string {
+	d, err := field.DecodeWithLenient(dn, h.getCharsetReader())
+	if err != nil {
+		return dn
+	}
+	return d
+}
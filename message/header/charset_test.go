@@ -0,0 +1,79 @@
+package header_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/go-email/v2/message/header"
+)
+
+func TestParse_CharsetReader(t *testing.T) {
+	t.Parallel()
+
+	const src = "Subject: =?iso-8859-15?Q?=A4uro?=\n" +
+		"Comments: =?shift_jis?B?k/qWew==?=\n" +
+		"From: =?iso-8859-15?Q?Ren=E9e_=A4?= <renee@example.com>\n"
+
+	h, err := header.Parse([]byte(src), header.LF)
+	require.NoError(t, err)
+
+	s, err := h.GetSubject()
+	assert.NoError(t, err)
+	assert.Equal(t, "€uro", s)
+
+	c, err := h.Get(header.Comments)
+	assert.NoError(t, err)
+	assert.Equal(t, "日本", c)
+
+	from, err := h.GetFrom()
+	require.NoError(t, err)
+	require.Len(t, from, 1)
+	assert.Equal(t, "Renée €", from[0].DisplayName())
+}
+
+func TestHeader_SetCharsetReader(t *testing.T) {
+	t.Parallel()
+
+	const src = "Subject: =?x-upper?Q?hello?=\n"
+
+	h, err := header.Parse([]byte(src), header.LF)
+	require.NoError(t, err)
+
+	// the default charset reader does not know this charset
+	s, err := h.GetSubject()
+	assert.NoError(t, err)
+	assert.Equal(t, "=?x-upper?Q?hello?=", s)
+
+	h.SetCharsetReader(func(charset string, input io.Reader) (io.Reader, error) {
+		b, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		return strings.NewReader(strings.ToUpper(string(b))), nil
+	})
+
+	s, err = h.GetSubject()
+	assert.NoError(t, err)
+	assert.Equal(t, "HELLO", s)
+
+	c := h.Clone()
+	s, err = c.GetSubject()
+	assert.NoError(t, err)
+	assert.Equal(t, "HELLO", s)
+
+	buf := &bytes.Buffer{}
+	_, err = h.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, src+"\n", buf.String())
+
+	h.SetCharsetReader(nil)
+
+	s, err = h.GetSubject()
+	assert.NoError(t, err)
+	assert.Equal(t, "=?x-upper?Q?hello?=", s)
+}
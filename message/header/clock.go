@@ -0,0 +1,38 @@
+package header
+
+import (
+	"sync"
+	"time"
+)
+
+var (
+	// clockMu guards clock
+	clockMu sync.RWMutex
+
+	// clock is the function set with SetClock
+	clock = time.Now
+)
+
+// SetClock sets the function used to get the current time by SetDateNow (and
+// anything else that needs the current time, see Now). Passing nil restores
+// the default, time.Now.
+//
+// This is global state shared by every Header. It is intended for tests and
+// other situations where generated messages must be reproducible. It is safe
+// to call from multiple goroutines.
+func SetClock(fn func() time.Time) {
+	if fn == nil {
+		fn = time.Now
+	}
+
+	clockMu.Lock()
+	defer clockMu.Unlock()
+	clock = fn
+}
+
+// Now returns the current time according to the function set with SetClock.
+func Now() time.Time {
+	clockMu.RLock()
+	defer clockMu.RUnlock()
+	return clock()
+}
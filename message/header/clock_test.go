@@ -0,0 +1,47 @@
+package header_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zostay/go-email/v2/message/header"
+)
+
+func TestHeader_SetDateNow(t *testing.T) { //nolint:paralleltest // testing globals
+	// Do not test in parallel. The clock is global, so changing it would
+	// affect any other test running at the same time.
+
+	defer header.SetClock(nil)
+
+	fixed := time.Date(2022, time.December, 5, 16, 46, 38, 0, time.UTC)
+	header.SetClock(func() time.Time { return fixed })
+	assert.Equal(t, fixed, header.Now())
+
+	h := &header.Header{}
+	h.SetDateNow()
+	d, err := h.Get(header.Date)
+	assert.NoError(t, err)
+	assert.Equal(t, "Mon, 05 Dec 2022 16:46:38 +0000", d)
+
+	header.SetClock(nil)
+	assert.WithinDuration(t, time.Now(), header.Now(), time.Minute)
+}
+
+func TestHeader_SetDateIn(t *testing.T) {
+	t.Parallel()
+
+	d := time.Date(2022, time.December, 5, 16, 46, 38, 0, time.UTC)
+
+	h := &header.Header{}
+	h.SetDateIn(d, time.FixedZone("EST", -5*60*60))
+	s, err := h.Get(header.Date)
+	assert.NoError(t, err)
+	assert.Equal(t, "Mon, 05 Dec 2022 11:46:38 -0500", s)
+
+	h.SetDateIn(d, nil)
+	s, err = h.Get(header.Date)
+	assert.NoError(t, err)
+	assert.Equal(t, "Mon, 05 Dec 2022 16:46:38 +0000", s)
+}
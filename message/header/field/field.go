@@ -2,6 +2,8 @@ package field
 
 import (
 	"bytes"
+	"net/textproto"
+	"strings"
 )
 
 // Field provides a low-level interface to manage a single email header field.
@@ -73,6 +75,15 @@ func (f *Field) Name() string {
 	return f.Base.Name()
 }
 
+// CanonicalName returns the name of the field in canonical form, as done by
+// textproto.CanonicalMIMEHeaderKey() (e.g., "MESSAGE-ID" and "message-id"
+// both become "Message-Id"). This is useful for displaying or comparing field
+// names consistently. The field itself is not changed, so the name is still
+// written exactly as it was.
+func (f *Field) CanonicalName() string {
+	return textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(f.Name()))
+}
+
 // Body returns the Base.Body().
 func (f *Field) Body() string {
 	return f.Base.Body()
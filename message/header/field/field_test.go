@@ -48,3 +48,15 @@ func TestNew(t *testing.T) {
 	assert.Equal(t, "Subject", f.Name())
 	assert.Equal(t, "foo bar baz", f.Body())
 }
+
+func TestField_CanonicalName(t *testing.T) {
+	t.Parallel()
+
+	f := field.Parse([]byte("MESSAGE-ID: <a@example.com>"), []byte("\n"))
+
+	assert.Equal(t, "Message-Id", f.CanonicalName())
+	assert.Equal(t, "MESSAGE-ID", f.Name())
+	assert.Equal(t, "MESSAGE-ID: <a@example.com>", f.String())
+
+	assert.Equal(t, "Content-Type", field.New("content-TYPE", "text/plain").CanonicalName())
+}
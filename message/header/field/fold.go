@@ -147,10 +147,11 @@ func isNonSpace(c rune) bool { return c != ' ' && c != '\t' }
 func (vf *FoldEncoding) Fold(out io.Writer, f []byte, lb Break) (int64, error) {
 	total := int64(0)
 	continuingLine := false
+	indent := []byte(vf.foldIndent)
 	writeFold := func(f []byte, end int) ([]byte, error) {
 		// only indent if there's no space already present at the break
 		if continuingLine && !isSpace(rune(f[0])) {
-			n, err := out.Write([]byte(vf.foldIndent))
+			n, err := out.Write(indent)
 			total += int64(n)
 			if err != nil {
 				return nil, err
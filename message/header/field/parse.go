@@ -44,6 +44,13 @@ type Lines []Line
 // documentation, we consider a space character or tab character to be a space,
 // but all other characters are treated as non-spaces. This is in keeping with
 // RFC 5322.)
+//
+// Lines are ended by the given line break. However, some mailers fold or end
+// lines with a bare CR or bare LF even when the rest of the header uses CRLF (or
+// end with a CRLF when the rest uses a bare LF), so any of CRLF, LF, or CR also
+// ends a line. A line after one of these that starts with a space is a
+// continuation as usual, so the field unfolds to the correct value. The bytes
+// of each line are kept exactly as given.
 func ParseLines(m, lb []byte) (Lines, error) {
 	if len(m) <= 2 {
 		// too short to be a header, just return empty
@@ -52,10 +59,7 @@ func ParseLines(m, lb []byte) (Lines, error) {
 
 	h := make(Lines, 0, len(m)/80)
 	var err *BadStartError
-	for _, line := range bytes.SplitAfter(m, lb) {
-		if len(line) == 0 {
-			break
-		}
+	for _, line := range splitLines(m, lb) {
 		if line[0] == '\t' || line[0] == ' ' || !bytes.Contains(line, []byte(":")) {
 			// Start with a continuation? Weird, uh...
 			if len(h) == 0 {
@@ -80,6 +84,31 @@ func ParseLines(m, lb []byte) (Lines, error) {
 	}
 }
 
+// splitLines splits the input after each line break. The given line break is
+// preferred, but CRLF, LF, and CR are also treated as line breaks.
+func splitLines(m, lb []byte) [][]byte {
+	lines := make([][]byte, 0, len(m)/40)
+	for len(m) > 0 {
+		ix := bytes.IndexAny(m, "\r\n")
+		if ix < 0 {
+			lines = append(lines, m)
+			break
+		}
+
+		end := ix + 1
+		switch {
+		case len(lb) > 0 && bytes.HasPrefix(m[ix:], lb):
+			end = ix + len(lb)
+		case bytes.HasPrefix(m[ix:], []byte("\r\n")):
+			end = ix + 2
+		}
+
+		lines = append(lines, m[:end])
+		m = m[end:]
+	}
+	return lines
+}
+
 // Parse will take a single header field line, including any folded continuation
 // lines. This will then construct a header field object.
 func Parse(f Line, lb []byte) *Field {
@@ -96,7 +125,7 @@ func Parse(f Line, lb []byte) *Field {
 	// by choices made when folding
 	name := string(DefaultFoldEncoding.Unfold(rawField[:ix]))
 	body := string(bytes.TrimSpace(DefaultFoldEncoding.Unfold(rawField[ix+off:])))
-	decBody, err := Decode(body)
+	decBody, err := DecodeLenient(body)
 	if err == nil {
 		body = decBody
 	}
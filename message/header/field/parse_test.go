@@ -50,6 +50,67 @@ func TestParseLines(t *testing.T) {
 	}, lines)
 }
 
+func TestParseLines_BareBreaks(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		input  string
+		lb     string
+		expect []string
+		bodies []string
+	}{
+		{
+			name:   "bare CR fold in CRLF",
+			input:  "a: one\r two\r\n\tthree\r\nb: four\r\n",
+			lb:     "\r\n",
+			expect: []string{"a: one\r two\r\n\tthree\r\n", "b: four\r\n"},
+			bodies: []string{"one two\tthree", "four"},
+		},
+		{
+			name:   "bare LF fold in CRLF",
+			input:  "a: one\n two\r\nb: four\nc: five\r\n",
+			lb:     "\r\n",
+			expect: []string{"a: one\n two\r\n", "b: four\n", "c: five\r\n"},
+			bodies: []string{"one two", "four", "five"},
+		},
+		{
+			name:   "bare CR fold in LF",
+			input:  "a: one\r\ttwo\r three\rb: four\n",
+			lb:     "\n",
+			expect: []string{"a: one\r\ttwo\r three\r", "b: four\n"},
+			bodies: []string{"one\ttwo three", "four"},
+		},
+		{
+			name:   "LFCR",
+			input:  "a: one\n\r two\n\rb: four\n\r",
+			lb:     "\n\r",
+			expect: []string{"a: one\n\r two\n\r", "b: four\n\r"},
+			bodies: []string{"one two", "four"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			lines, err := field.ParseLines([]byte(test.input), []byte(test.lb))
+			assert.NoError(t, err)
+
+			got := make([]string, len(lines))
+			bodies := make([]string, len(lines))
+			for i, line := range lines {
+				got[i] = string(line)
+				bodies[i] = field.Parse(line, []byte(test.lb)).Body()
+			}
+
+			assert.Equal(t, test.expect, got)
+			assert.Equal(t, test.bodies, bodies)
+		})
+	}
+}
+
 func TestParse(t *testing.T) {
 	t.Parallel()
 
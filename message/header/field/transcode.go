@@ -1,10 +1,16 @@
 package field
 
 import (
+	"io"
 	"mime"
+	"regexp"
 	"strings"
 )
 
+// spacedWordPattern matches a MIME encoded word with whitespace in the encoded
+// text, which is not permitted by RFC 2047, but is sent by some mailers.
+var spacedWordPattern = regexp.MustCompile(`=\?[^?\s]+\?([bBqQ])\?([^?]*[ \t][^?]*)\?=`)
+
 // Encode transforms a single header field body by looking for any characters
 // allowed for header encoding and turning them into encode body values using
 // word encoder. It will always output b-type (Base-64) encoding using UTF-8 as
@@ -16,8 +22,19 @@ func Encode(body string) string {
 // Decode transforms a single header field body and looks for MIME word encoded field
 // values. When they are found, these are decoded into native unicode.
 func Decode(body string) (string, error) {
+	return DecodeWith(body, CharsetDecoderToCharsetReader(CharsetDecoder))
+}
+
+// DecodeWith works just like Decode, but uses the given charset reader to
+// transform the text of each MIME encoded word in a charset other than UTF-8,
+// ISO-8859-1, or US-ASCII into native unicode. The charset reader works just
+// like the CharsetReader of mime.WordDecoder.
+func DecodeWith(
+	body string,
+	charsetReader func(charset string, input io.Reader) (io.Reader, error),
+) (string, error) {
 	dec := &mime.WordDecoder{
-		CharsetReader: CharsetDecoderToCharsetReader(CharsetDecoder),
+		CharsetReader: charsetReader,
 	}
 
 	if strings.Contains(body, "=?") {
@@ -26,3 +43,51 @@ func Decode(body string) (string, error) {
 
 	return body, nil
 }
+
+// DecodeLenient works just like Decode, but also decodes MIME encoded words
+// that illegally contain whitespace in the encoded text, as some mailers send
+// (e.g., "=?utf-8?Q?hello world?="). See DecodeWithLenient.
+func DecodeLenient(body string) (string, error) {
+	return DecodeWithLenient(body, CharsetDecoderToCharsetReader(CharsetDecoder))
+}
+
+// DecodeWithLenient works just like DecodeWith, but first repairs any MIME
+// encoded words that contain whitespace in the encoded text using
+// RepairEncodedWords. Otherwise, such words may be left as-is by DecodeWith.
+// Use DecodeWith to decode strictly, such as for validation.
+func DecodeWithLenient(
+	body string,
+	charsetReader func(charset string, input io.Reader) (io.Reader, error),
+) (string, error) {
+	return DecodeWith(RepairEncodedWords(body), charsetReader)
+}
+
+// RepairEncodedWords returns the body with the whitespace removed from the
+// encoded text of every MIME encoded word, which RFC 2047 does not permit, but
+// some mailers send anyway (e.g., "=?utf-8?Q?hello world?="). In a Q encoded
+// word, each space is replaced with "_" and each tab with "=09", so the
+// whitespace is kept in the decoded text. In a B encoded word, the whitespace
+// is dropped. Everything else is returned as-is.
+func RepairEncodedWords(body string) string {
+	if !strings.Contains(body, "=?") {
+		return body
+	}
+
+	return spacedWordPattern.ReplaceAllStringFunc(body, repairSpacedWord)
+}
+
+// repairSpacedWord fixes the whitespace in a MIME encoded word matched by
+// spacedWordPattern.
+func repairSpacedWord(word string) string {
+	m := spacedWordPattern.FindStringSubmatchIndex(word)
+	enc, text := word[m[2]:m[3]], word[m[4]:m[5]]
+
+	var fixed string
+	if strings.EqualFold(enc, "q") {
+		fixed = strings.NewReplacer(" ", "_", "\t", "=09").Replace(text)
+	} else {
+		fixed = strings.Join(strings.Fields(text), "")
+	}
+
+	return word[:m[4]] + fixed + word[m[5]:]
+}
@@ -22,3 +22,39 @@ func TestDecode(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "⚀⚁⚂⚃⚄⚅", s)
 }
+
+func TestDecodeLenient(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		in     string
+		repair string
+		expect string
+	}{
+		{"Q with spaces", "=?utf-8?Q?hello world?=", "=?utf-8?Q?hello_world?=", "hello world"},
+		{"Q with tab", "=?utf-8?q?a\tb?=", "=?utf-8?q?a=09b?=", "a\tb"},
+		{"B with spaces", "=?utf-8?B?aGVsbG8g d29ybGQ=?=", "=?utf-8?B?aGVsbG8gd29ybGQ=?=", "hello world"},
+		{"surrounding text", "x =?utf-8?Q?caf=C3=A9 au lait?= y", "x =?utf-8?Q?caf=C3=A9_au_lait?= y", "x café au lait y"},
+		{"valid words", "=?utf-8?q?a?= =?utf-8?q?b?=", "=?utf-8?q?a?= =?utf-8?q?b?=", "ab"},
+		{"no words", "plain text", "plain text", "plain text"},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.repair, field.RepairEncodedWords(test.in))
+
+			s, err := field.DecodeLenient(test.in)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expect, s)
+		})
+	}
+
+	// the strict decoder leaves the broken B word alone
+	s, err := field.Decode("=?utf-8?B?aGVsbG8g d29ybGQ=?=")
+	assert.NoError(t, err)
+	assert.Equal(t, "=?utf-8?B?aGVsbG8g d29ybGQ=?=", s)
+}
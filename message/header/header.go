@@ -3,13 +3,19 @@ package header
 import (
 	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/mail"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/araddon/dateparse"
 	"github.com/zostay/go-addr/pkg/addr"
 
+	"github.com/zostay/go-email/v2/message/header/field"
 	"github.com/zostay/go-email/v2/message/header/param"
 )
 
@@ -33,6 +39,19 @@ var (
 	// either a string or an addr.AddressList when something other than those
 	// types is provided.
 	ErrWrongAddressType = errors.New("incorrect address type during write")
+
+	// ErrInvalidBoundary is returned by SetBoundary when the boundary is not
+	// a valid RFC 2046 multipart boundary.
+	ErrInvalidBoundary = errors.New("invalid multipart boundary")
+
+	// ErrIllegalFieldName is returned by SetChecked when the field name is not
+	// valid according to ValidFieldName. The other methods that set a field
+	// panic with an error wrapping this one instead.
+	ErrIllegalFieldName = errors.New("illegal header field name")
+
+	// ErrWeekdayMismatch is returned by ValidateDate when the day-of-week
+	// given in a date does not match the day of the date.
+	ErrWeekdayMismatch = errors.New("day-of-week does not match the date")
 )
 
 // These are standard headers defined in RFC 5322.
@@ -40,7 +59,10 @@ const (
 	Bcc                     = "Bcc"
 	Cc                      = "Cc"
 	Comments                = "Comments"
+	ContentDescription      = "Content-description"
 	ContentDisposition      = "Content-disposition"
+	ContentEncoding         = "Content-encoding"
+	ContentID               = "Content-id"
 	ContentTransferEncoding = "Content-transfer-encoding"
 	ContentType             = "Content-type"
 	Date                    = "Date"
@@ -48,6 +70,7 @@ const (
 	InReplyTo               = "In-reply-to"
 	Keywords                = "Keywords"
 	MessageID               = "Message-id"
+	Received                = "Received"
 	References              = "References"
 	ReplyTo                 = "Reply-to"
 	Sender                  = "Sender"
@@ -55,6 +78,14 @@ const (
 	To                      = "To"
 )
 
+// These are other headers commonly found in email messages.
+const (
+	AutoSubmitted = "Auto-submitted"
+	Precedence    = "Precedence"
+	UserAgent     = "User-agent"
+	XMailer       = "X-mailer"
+)
+
 // Even more custom date formats, built from those seen in the wild that the
 // usual parsers have trouble with.
 const (
@@ -86,6 +117,17 @@ type Header struct {
 	// be modified outside, we can have inconsistencies between what is stored
 	// in valueCache and what is set in simple.Header
 	valueCache map[string]any
+
+	// frozen is set by Freeze to prevent the getters from writing to
+	// valueCache, which makes concurrent reads safe
+	frozen bool
+
+	// charsetReader is set by SetCharsetReader to decode MIME encoded words
+	charsetReader func(string, io.Reader) (io.Reader, error)
+
+	// addressWarnings holds the warnings recorded under
+	// AddressParseLenientWithWarning
+	addressWarnings []*AddressParseWarning
 }
 
 // Clone returns a deep copy of the header object.
@@ -96,10 +138,44 @@ func (h *Header) Clone() *Header {
 		vc[k] = v
 	}
 
+	// the warnings go with the cached values they were recorded for
+	var aw []*AddressParseWarning
+	if h.addressWarnings != nil {
+		aw = make([]*AddressParseWarning, len(h.addressWarnings))
+		copy(aw, h.addressWarnings)
+	}
+
 	return &Header{
-		Base:       *h.Base.Clone(),
-		valueCache: vc,
+		Base:            *h.Base.Clone(),
+		valueCache:      vc,
+		charsetReader:   h.charsetReader,
+		addressWarnings: aw,
+	}
+}
+
+// Freeze returns a copy of the header that is safe to share between goroutines
+// so long as they only read from it. Normally, the getters of a header cache the
+// values they parse, so even a read-only getter modifies the header and
+// concurrent calls are a data race. Freeze precomputes the cached values for
+// the Date, address, Content-type, Content-disposition, and Keywords fields
+// and then stops the getters from caching anything else. Other values are
+// parsed again on every call.
+//
+// The frozen header must not be modified. Use Clone to get a copy that may be
+// modified (the copy will not be frozen).
+func (h *Header) Freeze() *Header {
+	fh := h.Clone()
+
+	_, _ = fh.GetTime(Date)
+	for _, name := range []string{From, Sender, ReplyTo, To, Cc, Bcc} {
+		_, _ = fh.GetAddressList(name)
 	}
+	_, _ = fh.GetParamValue(ContentType)
+	_, _ = fh.GetParamValue(ContentDisposition)
+	_, _ = fh.GetKeywordsList(Keywords)
+
+	fh.frozen = true
+	return fh
 }
 
 // getValue retrieves the cached value. The first value is the cached value
@@ -111,8 +187,12 @@ func (h *Header) getValue(name string) (any, bool) {
 	return v, found
 }
 
-// setValue replaces the cached value for the given name.
+// setValue replaces the cached value for the given name. This does nothing if
+// the header is frozen.
 func (h *Header) setValue(name string, value any) {
+	if h.frozen {
+		return
+	}
 	if h.valueCache == nil {
 		h.valueCache = make(map[string]any, h.Len())
 	}
@@ -139,6 +219,51 @@ func (h *Header) Get(name string) (string, error) {
 	return b, nil
 }
 
+// maxDecodeWordIterations is the maximum number of rounds of decoding
+// performed by DecodeWordRecursive.
+const maxDecodeWordIterations = 5
+
+// DecodeWordRecursive decodes the MIME encoded words in the given string
+// repeatedly until no encoded words remain. This is used to repair field bodies
+// that have been MIME word encoded more than once by buggy software in
+// transit, e.g., "=?utf-8?Q?=3D=3Futf-8=3FQ=3F...?=". A correctly encoded
+// value is decoded exactly as it would have been by a single pass.
+//
+// To avoid looping forever on pathological input, decoding stops after a few
+// rounds even if encoded words remain. If decoding fails, the value decoded so
+// far is returned along with the error.
+func DecodeWordRecursive(s string) (string, error) {
+	for i := 0; i < maxDecodeWordIterations; i++ {
+		if !strings.Contains(s, "=?") {
+			break
+		}
+
+		d, err := field.DecodeLenient(s)
+		if err != nil {
+			return s, err
+		}
+
+		if d == s {
+			break
+		}
+
+		s = d
+	}
+
+	return s, nil
+}
+
+// GetOr retrieves the string value of the named field, just like Get, but
+// returns def if the field is not set on the header. If there are multiple
+// fields with the given name, the value of the first is returned.
+func (h *Header) GetOr(name, def string) string {
+	b, err := h.Get(name)
+	if errors.Is(err, ErrNoSuchField) {
+		return def
+	}
+	return b
+}
+
 // ParseTime is a function that provides the time parsing used by GetTime() and
 // GetDate() to parse dates to be used on any field body. This will attempt to
 // parse the date using the format specified by RFC 5322 first and fallback to
@@ -164,6 +289,54 @@ func ParseTime(body string) (time.Time, error) {
 	return t, fmt.Errorf("time string %q cannot be parsed", body)
 }
 
+// ValidateDate strictly checks a date, such as the body of the Date field. The
+// date must be in the format given by RFC 5322 (as parsed by
+// net/mail.ParseDate) and if it has a day-of-week, the day must match the date.
+// For example, "Tue, 05 Dec 2022 10:00:00 +0000" fails because the 5th was a
+// Monday. The day-of-week is checked against the date in its own time zone.
+//
+// It returns nil if the date is valid. It returns an error wrapping
+// ErrWeekdayMismatch if the day-of-week is wrong, or an error describing why
+// the date cannot be parsed. Unlike ParseTime and GetDate, which accept such
+// dates, this is intended for validation, e.g., to catch malformed or forged
+// dates.
+func ValidateDate(body string) error {
+	t, err := mail.ParseDate(body)
+	if err != nil {
+		return fmt.Errorf("date %q is not valid: %w", body, err)
+	}
+
+	// only a leading word before the first comma is a day-of-week, as a comma
+	// may also appear in a comment after the date
+	dow, _, hasDow := strings.Cut(strings.TrimSpace(body), ",")
+	dow = strings.TrimSpace(dow)
+	if !hasDow || !isAlpha(dow) {
+		return nil
+	}
+
+	if !strings.EqualFold(dow, t.Weekday().String()[:3]) {
+		return fmt.Errorf("%w: date %q is a %s, not %s",
+			ErrWeekdayMismatch, body, t.Weekday(), dow)
+	}
+
+	return nil
+}
+
+// isAlpha returns true if s is made up of one or more US-ASCII letters.
+func isAlpha(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, c := range []byte(s) {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
+			return false
+		}
+	}
+
+	return true
+}
+
 // getTime parses the header body as a date and caches the result.
 func (h *Header) getTime(name string) (time.Time, error) {
 	body, err := h.Get(name)
@@ -203,6 +376,56 @@ func (h *Header) GetTime(name string) (time.Time, error) {
 	return t, nil
 }
 
+// GetTimeOr retrieves the named field as a time.Time, just like GetTime, but
+// returns def if the field is not set, is set more than once, or cannot be
+// parsed as a time.
+func (h *Header) GetTimeOr(name string, def time.Time) time.Time {
+	t, err := h.GetTime(name)
+	if err != nil {
+		return def
+	}
+	return t
+}
+
+// getFirst returns the body of the first field with the given name. It returns
+// ErrNoSuchField if there is no such field.
+func (h *Header) getFirst(name string) (string, error) {
+	f := h.GetFieldNamed(name, 0)
+	if f == nil {
+		return "", ErrNoSuchField
+	}
+	return f.Body(), nil
+}
+
+// GetFloat parses the body of the first field with the given name as a
+// floating point number, ignoring any surrounding whitespace. This is handy
+// for fields such as X-Spam-Score.
+//
+// It will return 0 and ErrNoSuchField if the field is not set on the header.
+// It will return an error if the body cannot be parsed as a number.
+func (h *Header) GetFloat(name string) (float64, error) {
+	body, err := h.getFirst(name)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseFloat(strings.TrimSpace(body), 64)
+}
+
+// GetInt parses the body of the first field with the given name as a decimal
+// integer, ignoring any surrounding whitespace.
+//
+// It will return 0 and ErrNoSuchField if the field is not set on the header.
+// It will return an error if the body cannot be parsed as an integer.
+func (h *Header) GetInt(name string) (int, error) {
+	body, err := h.getFirst(name)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(strings.TrimSpace(body))
+}
+
 // ParseAddressList provides the same address parsing functionality build into
 // the GetAddressList() and GetAllAddressLists() and can be used to parse any
 // field body. It will attempt a strict parse of the email address list.
@@ -211,9 +434,14 @@ func (h *Header) GetTime(name string) (time.Time, error) {
 // to provide some kind of result. It is so forgiving, it will return some kind
 // of value for any input.
 //
+// Both the strict and lenient parse handle groups, including a group followed
+// by more addresses, such as "A Group: a@x, b@y;, c@z", which is still found in
+// headers written by older mailing list software. The group is returned as an
+// *addr.Group along with the addresses that follow it.
+//
 // It will either return an addr.AddressList or an error describing the parse error.
 func ParseAddressList(body string) addr.AddressList {
-	al, err := addr.ParseEmailAddressList(body)
+	al, err := parseAddressListStrict(body)
 	if err != nil {
 		al = parseEmailAddressList(body)
 	}
@@ -223,14 +451,18 @@ func ParseAddressList(body string) addr.AddressList {
 
 // getAddressList will parse an addr.AddressList out of the field or return an
 // error. This falls back onto parseEmailAddressList() if
-// addr.ParseEmailAddrList() lets us down.
+// addr.ParseEmailAddrList() lets us down, unless AddressParseStrict is in
+// effect.
 func (h *Header) getAddressList(name string) (addr.AddressList, error) {
 	body, err := h.Get(name)
 	if err != nil {
 		return nil, err
 	}
 
-	al := ParseAddressList(body)
+	al, err := h.parseAddressField(name, h.getEncoded(name), body)
+	if err != nil {
+		return nil, err
+	}
 	h.setValue(name, al)
 
 	return al, nil
@@ -240,9 +472,13 @@ func (h *Header) getAddressList(name string) (addr.AddressList, error) {
 // method works hard to avoid parse errors and tries to accept anything. As such
 // a badly formatted address field might return a weird address value.
 //
+// Any RFC 2047 encoded words in the display names are decoded. The addr-spec of
+// each address is left as-is.
+//
 // It will return nil and ErrNoSuchField if the field is not set on the header.
 // It will return ErrManyFields if the field is set more than once on the
-// header.
+// header. When SetAddressParseMode has selected AddressParseStrict, it will
+// return nil and the parse error if the field is not strictly valid.
 func (h *Header) GetAddressList(name string) (addr.AddressList, error) {
 	v, found := h.getValue(name)
 	if !found {
@@ -257,6 +493,26 @@ func (h *Header) GetAddressList(name string) (addr.AddressList, error) {
 	return al, nil
 }
 
+// GetAddressListStrict will return an addr.AddressList for the named field.
+// Unlike GetAddressList(), this method will not fall back to a lenient parse if
+// the field body is not a strictly correct address list. Instead, it returns
+// the parse error. This is useful for validation or when preparing outbound
+// messages.
+//
+// It will return nil and ErrNoSuchField if the field is not set on the header.
+// It will return nil and ErrManyFields if the field is set more than once on
+// the header. It will return the parse error (along with whatever addresses
+// could be parsed, if any) if the field cannot be strictly parsed.
+func (h *Header) GetAddressListStrict(name string) (addr.AddressList, error) {
+	_, err := h.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	al, err := parseAddressListStrict(h.getEncoded(name))
+	return h.decodeDisplayNames(al), err
+}
+
 // getAllAddressLists will return a slice of addr.AddressList for all headers
 // with the given name or return an error.
 func (h *Header) getAllAddressLists(name string) ([]addr.AddressList, error) {
@@ -266,8 +522,11 @@ func (h *Header) getAllAddressLists(name string) ([]addr.AddressList, error) {
 	}
 
 	allAl := make([]addr.AddressList, 0, 10)
-	for _, b := range bs {
-		al := ParseAddressList(b)
+	for i, ix := range h.GetIndexesNamed(name) {
+		al, err := h.parseAddressField(name, encodedBody(h.GetField(ix)), bs[i])
+		if err != nil {
+			return nil, err
+		}
 		allAl = append(allAl, al)
 	}
 
@@ -284,7 +543,9 @@ func (h *Header) getAllAddressLists(name string) ([]addr.AddressList, error) {
 // weird results from this.
 //
 // If the named field does not exist in the header, this will return nil with
-// ErrNoSuchField.
+// ErrNoSuchField. When SetAddressParseMode has selected AddressParseStrict, it
+// will return nil and the parse error if any of the fields is not strictly
+// valid.
 func (h *Header) GetAllAddressLists(name string) ([]addr.AddressList, error) {
 	v, found := h.getValue(name)
 	if !found {
@@ -345,16 +606,16 @@ func (h *Header) GetParamValue(name string) (*param.Value, error) {
 // getKeywordsList will return keywords for all header fields with the given
 // name or return an error.
 func (h *Header) getKeywordsList(name string) ([]string, error) {
-	bs, err := h.GetAll(name)
-	if err != nil {
-		return nil, err
+	fs := h.GetAllFieldsNamed(name)
+	if len(fs) == 0 {
+		return nil, ErrNoSuchField
 	}
 
-	allKs := make([]string, 0, len(bs)*2)
-	for _, b := range bs {
-		ks := strings.Split(b, ",")
+	allKs := make([]string, 0, len(fs)*2)
+	for _, f := range fs {
+		ks := splitKeywords(encodedBody(f))
 		for _, k := range ks {
-			nextK := strings.TrimSpace(k)
+			nextK := strings.TrimSpace(h.decodeWords(k))
 			if nextK != "" {
 				allKs = append(allKs, nextK)
 			}
@@ -371,7 +632,9 @@ func (h *Header) getKeywordsList(name string) ([]string, error) {
 // this is the generic method that allows for treating other headers as
 // Keywords. There can be zero or more Keywords headers. Each header is, then,
 // a comma-separated list of Keywords. This will collect those values from all
-// the headers with the given name and return them.
+// the headers with the given name and return them. Any MIME encoded words in
+// each keyword are decoded. A comma inside of an encoded word does not split
+// the keyword.
 //
 // This method will return nil with ErrNoSuchField if the named field does not
 // exist.
@@ -426,13 +689,48 @@ func (h *Header) GetAll(name string) ([]string, error) {
 	return ss, nil
 }
 
+// Occurrence is a single occurrence of a named field in a header, as returned
+// by Occurrences.
+type Occurrence struct {
+	// Index is the index of the field in the header, which may be passed to
+	// GetField or DeleteField.
+	Index int
+
+	// Body is the body of the field.
+	Body string
+}
+
+// Occurrences returns every field with the given name along with its index in
+// the header, in the order the fields appear. This is handy for letting a user
+// pick a specific occurrence of a repeated field to edit or remove. If no field
+// with the given name is set, an empty slice is returned.
+//
+// The indexes are only valid until the header is modified. In particular,
+// deleting a field shifts the index of every field after it, so delete
+// multiple occurrences from the last to the first.
+func (h *Header) Occurrences(name string) []Occurrence {
+	ixs := h.GetIndexesNamed(name)
+	os := make([]Occurrence, len(ixs))
+	for i, ix := range ixs {
+		os[i] = Occurrence{
+			Index: ix,
+			Body:  h.GetField(ix).Body(),
+		}
+	}
+	return os
+}
+
 // SetAll replaces all the header fields with the given name with the
 // bodies given. After a successful completion of this method, the field with
 // the given name will occur exactly len(bodies) times in the header. If the
 // field is already present in the header, existing fields will have their
 // bodies replaced with the new values. Any new fields will be appended to the
 // end of the header.
+//
+// This method panics if the name is not valid according to ValidFieldName.
 func (h *Header) SetAll(name string, bodies ...string) {
+	mustBeValidFieldName(name)
+
 	ixs := h.GetIndexesNamed(name)
 
 	for i, b := range bodies {
@@ -457,11 +755,29 @@ func (h *Header) SetAll(name string, bodies ...string) {
 
 // SetKeywordsList will replace all Keywords headers currently set in the
 // header with one Keywords header with all the given keywords separated by
-// a comma.
+// a comma. Each keyword containing characters outside of US-ASCII is written
+// as a MIME encoded word of its own.
 func (h *Header) SetKeywordsList(name string, keywords ...string) {
 	h.setValue(name, keywords)
-	bodyStr := strings.Join(keywords, ", ")
-	h.Set(name, bodyStr)
+	h.Set(name, strings.Join(keywords, ", "))
+	h.encodeKeywords(h.GetField(h.GetIndexesNamed(name)[0]), keywords)
+}
+
+// encodeKeywords gives the keywords field a Raw value with each keyword
+// containing characters outside of US-ASCII written as a MIME encoded word of
+// its own. The field is left as-is if every keyword is US-ASCII or the header
+// permits UTF-8.
+func (h *Header) encodeKeywords(f *field.Field, keywords []string) {
+	if h.utf8 || isASCII([]byte(f.Body())) {
+		return
+	}
+
+	eks := make([]string, len(keywords))
+	for i, k := range keywords {
+		eks[i] = encodeWords(k)
+	}
+
+	h.setEncodedRaw(f, strings.Join(eks, ", "))
 }
 
 // Set will replace all existing header fields with the given name with a single
@@ -472,7 +788,18 @@ func (h *Header) SetKeywordsList(name string, keywords ...string) {
 //
 // The procedure for replacing above is used to fall the Set* methods that
 // replace all fields with a single field.
+//
+// Setting an empty body does not remove the field. The header will still
+// contain the field, but with an empty body (e.g., "To: "). Use Unset to remove
+// the field entirely.
+//
+// This method panics if the name is not valid according to ValidFieldName, as
+// such a field could not be parsed again once written. Use SetChecked to get an
+// error instead. The same is true of the other methods that set a field by
+// name.
 func (h *Header) Set(name, body string) {
+	mustBeValidFieldName(name)
+
 	// Check for existing fields
 	ixs := h.GetIndexesNamed(name)
 
@@ -496,6 +823,135 @@ func (h *Header) Set(name, body string) {
 	f.SetBody(body)
 }
 
+// SetChecked works just like Set, but returns ErrIllegalFieldName rather than
+// panicking if the name is not valid according to ValidFieldName. Use this
+// when the name comes from input rather than from the program itself.
+func (h *Header) SetChecked(name, body string) error {
+	if !ValidFieldName(name) {
+		return fmt.Errorf("%w: %q", ErrIllegalFieldName, name)
+	}
+
+	h.Set(name, body)
+	return nil
+}
+
+// ValidFieldName returns true if the string is a valid header field name as
+// defined by RFC 5322. A field name must be at least one character long and may
+// only contain printable US-ASCII characters other than the colon (i.e., no
+// spaces, control characters, or characters outside of US-ASCII).
+func ValidFieldName(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+
+	for _, c := range []byte(name) {
+		if c < '!' || c > '~' || c == ':' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// mustBeValidFieldName panics with an error wrapping ErrIllegalFieldName if the
+// name is not valid according to ValidFieldName.
+func mustBeValidFieldName(name string) {
+	if !ValidFieldName(name) {
+		panic(fmt.Errorf("%w: %q", ErrIllegalFieldName, name))
+	}
+}
+
+// Unset removes every field with the given name from the header. Afterward, the
+// getters for that field will return ErrNoSuchField. This is different from
+// calling Set with an empty body, which leaves a field with an empty body in
+// the header.
+func (h *Header) Unset(name string) {
+	h.DeleteAll(name)
+	delete(h.valueCache, strings.ToLower(name))
+}
+
+// InsertFields inserts all the given fields into the header at the given index
+// at once. See Base.InsertFields for details. Any cached value for a field with
+// the same name as one of the inserted fields is discarded so that the getters
+// will see the new fields.
+func (h *Header) InsertFields(n int, fields ...*field.Field) {
+	h.Base.InsertFields(n, fields...)
+	for _, f := range fields {
+		delete(h.valueCache, strings.ToLower(f.Name()))
+	}
+}
+
+// PrependRawField inserts a pre-formatted field at the top of the header. The
+// raw value is everything that follows the colon after the name, including any
+// leading whitespace and folding, but without the final line break. The field
+// is written by WriteTo as the name, a colon, and then raw exactly as given,
+// with no folding or encoding applied. This is needed for fields like
+// DKIM-Signature, where the whitespace of the field is part of the signed data.
+//
+// The body of the field, as returned by the getters, is the raw value
+// unfolded and decoded just as if the field had been parsed. This method panics
+// if the name is not valid according to ValidFieldName.
+func (h *Header) PrependRawField(name string, raw []byte) {
+	mustBeValidFieldName(name)
+
+	line := make(field.Line, 0, len(name)+1+len(raw))
+	line = append(line, name...)
+	line = append(line, ':')
+	line = append(line, raw...)
+
+	h.InsertFields(0, field.Parse(line, nil))
+}
+
+// insertValue inserts a new field with the given name and body at the given
+// index. The value is cached for the getters if this is now the only field with
+// that name. Otherwise, any cached value is discarded so that the getters will
+// report ErrManyFields.
+func (h *Header) insertValue(n int, name, body string, value any) {
+	h.InsertBeforeField(n, name, body)
+	if len(h.GetIndexesNamed(name)) == 1 {
+		h.setValue(name, value)
+		return
+	}
+	delete(h.valueCache, strings.ToLower(name))
+}
+
+// InsertTime works just like SetTime, but inserts a new field at the given
+// index instead of replacing the existing fields with the given name. The index
+// is capped to the range of the fields already in the header.
+func (h *Header) InsertTime(n int, name string, body time.Time) {
+	h.insertValue(n, name, body.Format(time.RFC1123Z), body)
+}
+
+// InsertAddressList works just like SetAddressList, but inserts a new field at
+// the given index instead of replacing the existing fields with the given name.
+// The index is capped to the range of the fields already in the header.
+func (h *Header) InsertAddressList(n int, name string, body ...addr.Address) {
+	h.insertValue(n, name, encodeDisplayNames(body).String(), addr.AddressList(body))
+}
+
+// InsertParamValue works just like SetParamValue, but inserts a new field at
+// the given index instead of replacing the existing fields with the given name.
+// The index is capped to the range of the fields already in the header.
+func (h *Header) InsertParamValue(n int, name string, body *param.Value) {
+	h.insertValue(n, name, body.String(), body.Clone())
+}
+
+// InsertKeywordsList works just like SetKeywordsList, but inserts a new field
+// at the given index instead of replacing the existing fields with the given
+// name. The index is capped to the range of the fields already in the header.
+func (h *Header) InsertKeywordsList(n int, name string, keywords ...string) {
+	// cap the range of n to find the field once it is inserted
+	if n < 0 {
+		n = 0
+	}
+	if n > h.Len() {
+		n = h.Len()
+	}
+
+	h.insertValue(n, name, strings.Join(keywords, ", "), keywords)
+	h.encodeKeywords(h.GetField(n), keywords)
+}
+
 // SetTime will replace all existing header fields with the given name with a
 // single header field with the given name and time. The time will be formatted
 // via time.RFC1123Z.
@@ -507,9 +963,12 @@ func (h *Header) SetTime(name string, body time.Time) {
 
 // SetAddressList will replace all existing header fields with the given name
 // with a single header containing the given addr.AddressList.
+//
+// Any display name containing characters outside of US-ASCII is encoded as an
+// RFC 2047 encoded word, leaving the rest of the address as-is.
 func (h *Header) SetAddressList(name string, body ...addr.Address) {
 	h.setValue(name, body)
-	bodyStr := addr.AddressList(body).String()
+	bodyStr := encodeDisplayNames(body).String()
 	h.Set(name, bodyStr)
 }
 
@@ -524,6 +983,24 @@ func (h *Header) SetAllAddressLists(name string, bodies ...addr.AddressList) {
 	h.SetAll(name, strs...)
 }
 
+// SetAllAddressListsFromStrings works just like SetAllAddressLists(), but
+// takes each address list as a string. Each string is strictly parsed as an
+// address list. If any string fails to parse, the header is left unchanged and
+// an error is returned identifying the first entry that could not be parsed.
+func (h *Header) SetAllAddressListsFromStrings(name string, bodies ...string) error {
+	als := make([]addr.AddressList, len(bodies))
+	for i, body := range bodies {
+		al, err := parseAddressListStrict(body)
+		if err != nil {
+			return fmt.Errorf("unable to parse address list %d (%q) for %s: %w", i, body, name, err)
+		}
+		als[i] = al
+	}
+
+	h.SetAllAddressLists(name, als...)
+	return nil
+}
+
 // SetParamValue will replace all existing header fields with the given name
 // with a single param.Value header containing the given param.Value.
 func (h *Header) SetParamValue(name string, body *param.Value) {
@@ -610,6 +1087,15 @@ func (h *Header) SetContentType(v *param.Value) {
 	h.SetParamValue(ContentType, v)
 }
 
+// SetContentTypeParts replaces the Content-type with the given media type and
+// parameters in a single call, e.g., "text/plain" with a charset of "utf-8".
+// Unlike SetCharset() and SetBoundary(), this does not require the
+// Content-type to already be set. The parameters are written in sorted order.
+// The params may be nil if there are no parameters.
+func (h *Header) SetContentTypeParts(mediaType string, params map[string]string) {
+	h.SetContentType(param.New(mediaType, params))
+}
+
 // GetMediaType returns the MIME type set in the Content-type header (other
 // parameters will not be returned).
 //
@@ -667,11 +1153,62 @@ func (h *Header) GetBoundary() (string, error) {
 
 // SetBoundary sets the boundary on the Content-type header.
 //
+// This method fails with ErrInvalidBoundary if the boundary is not valid
+// according to ValidBoundary. This method fails with a ErrNoSuchField if the
+// field is not set on the header. This method fails with an error if the
+// parameter values cannot be parsed out of the field for some reason.
+func (h *Header) SetBoundary(b string) error {
+	if !ValidBoundary(b) {
+		return ErrInvalidBoundary
+	}
+
+	return h.setParamValueParam(ContentType, param.Boundary, b)
+}
+
+// ValidBoundary returns true if the string is a valid multipart boundary as
+// defined by RFC 2046. A boundary must be 1 to 70 characters long, may only
+// contain letters, digits, spaces, and the characters '()+_,-./:=? and may not
+// end with a space.
+func ValidBoundary(b string) bool {
+	if len(b) == 0 || len(b) > 70 || b[len(b)-1] == ' ' {
+		return false
+	}
+
+	for _, c := range []byte(b) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.IndexByte("'()+_,-./:=? ", c) >= 0:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// GetContentTypeParam gets the named parameter from the Content-type header
+// field, e.g., a custom "duration" parameter on an audio part. Parameter names
+// are matched without regard to case.
+//
+// This method returns an empty string with ErrNoSuchField if no field is
+// present in the header. This method returns an empty string with
+// ErrNoSuchFieldParameter if the field is present, but the parameter is not set
+// on the field. This method returns an empty string with ErrManyFields if
+// the field is set more than once on the header. This method returns an empty
+// string and an error if the parameter values cannot be parsed out of the
+// field for some reason.
+func (h *Header) GetContentTypeParam(name string) (string, error) {
+	return h.getParamValueParam(ContentType, strings.ToLower(name))
+}
+
+// SetContentTypeParam sets the named parameter on the Content-type header. The
+// parameter name is stored in lowercase, matching how parameters are parsed.
+//
 // This method fails with a ErrNoSuchField if the field is not set on the
 // header. This method fails with an error if the parameter values cannot be
 // parsed out of the field for some reason.
-func (h *Header) SetBoundary(b string) error {
-	return h.setParamValueParam(ContentType, param.Boundary, b)
+func (h *Header) SetContentTypeParam(name, value string) error {
+	return h.setParamValueParam(ContentType, strings.ToLower(name), value)
 }
 
 // GetContentDisposition returns the Content-disposition header as a
@@ -748,6 +1285,24 @@ func (h *Header) SetDate(d time.Time) {
 	h.SetTime(Date, d)
 }
 
+// SetDateIn updates the Date header from the given time.Time value after
+// converting it to the given location. This allows the date to be written with
+// the offset of a particular time zone, whatever the location of the given
+// time.Time. If loc is nil, the time is written in its own location, the same
+// as SetDate.
+func (h *Header) SetDateIn(d time.Time, loc *time.Location) {
+	if loc != nil {
+		d = d.In(loc)
+	}
+	h.SetDate(d)
+}
+
+// SetDateNow updates the Date header to the current time, as returned by Now.
+// The clock used may be replaced with SetClock to make the date predictable.
+func (h *Header) SetDateNow() {
+	h.SetDate(Now())
+}
+
 // GetSubject returns the value of the Subject header field.
 //
 // If Subject is not set in the header, it will return an empty string with
@@ -757,11 +1312,142 @@ func (h *Header) GetSubject() (string, error) {
 	return h.Get(Subject)
 }
 
+// GetSubjectLenient returns the value of the Subject header field after
+// repairing any extra rounds of MIME word encoding via DecodeWordRecursive. If
+// that repair fails, the value is returned as returned by GetSubject.
+//
+// If Subject is not set in the header, it will return an empty string with
+// ErrNoSuchField. If there are multiple Subject headers, it will return
+// ErrManyFields.
+func (h *Header) GetSubjectLenient() (string, error) {
+	subj, err := h.GetSubject()
+	if dsubj, derr := DecodeWordRecursive(subj); derr == nil {
+		subj = dsubj
+	}
+	return subj, err
+}
+
 // SetSubject replaces the Subject header field.
 func (h *Header) SetSubject(s string) {
 	h.Set(Subject, s)
 }
 
+// GetContentDescription returns the value of the Content-description header
+// field. Any MIME encoded words in the field are decoded.
+//
+// If Content-description is not set in the header, it will return an empty
+// string with ErrNoSuchField. If there are multiple Content-description
+// headers, it will return ErrManyFields.
+func (h *Header) GetContentDescription() (string, error) {
+	return h.Get(ContentDescription)
+}
+
+// SetContentDescription replaces the Content-description header field. The
+// value will be MIME word encoded on output, if necessary.
+func (h *Header) SetContentDescription(s string) {
+	h.Set(ContentDescription, s)
+}
+
+// GetContentID returns the Content-id header, if any. This is the ID used to
+// refer to a part from elsewhere in the message, e.g., from the start
+// parameter of a multipart/related or from a "cid:" URL. The ID is returned
+// as-is, usually enclosed in angle brackets.
+//
+// If Content-id is not set in the header, it will return an empty string with
+// ErrNoSuchField. If there are multiple Content-id headers, it will return
+// ErrManyFields.
+func (h *Header) GetContentID() (string, error) {
+	return h.Get(ContentID)
+}
+
+// SetContentID replaces the Content-id header. The ID will be enclosed in angle
+// brackets if it is not already.
+func (h *Header) SetContentID(id string) {
+	h.Set(ContentID, angleBracketID(id))
+}
+
+// GetMailerAgent returns the name of the software used to send the message.
+// This is the value of the X-mailer header or, if there is no X-mailer, the
+// value of the User-agent header. Any MIME encoded words in the field are
+// decoded.
+//
+// If neither field is set in the header, it will return an empty string with
+// ErrNoSuchField. If there are multiple of the field found, it will return
+// ErrManyFields.
+func (h *Header) GetMailerAgent() (string, error) {
+	m, err := h.Get(XMailer)
+	if errors.Is(err, ErrNoSuchField) {
+		return h.Get(UserAgent)
+	}
+	return m, err
+}
+
+// SetMailer replaces the X-mailer header field, which names the software used
+// to send the message. The value will be MIME word encoded on output, if
+// necessary.
+func (h *Header) SetMailer(s string) {
+	h.Set(XMailer, s)
+}
+
+// GetPrecedence returns the Precedence header, lowercased with surrounding
+// whitespace removed (e.g., "bulk", "list", or "junk"). This non-standard
+// header is set by mailing lists and other bulk mailers to ask that no
+// automatic reply be sent.
+//
+// If Precedence is not set in the header, it will return an empty string with
+// ErrNoSuchField. If there are multiple Precedence headers, it will return
+// ErrManyFields.
+func (h *Header) GetPrecedence() (string, error) {
+	p, err := h.Get(Precedence)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToLower(strings.TrimSpace(p)), nil
+}
+
+// SetPrecedence replaces the Precedence header.
+func (h *Header) SetPrecedence(s string) {
+	h.Set(Precedence, s)
+}
+
+// IsBulk returns true if the header indicates that the message was sent
+// automatically or in bulk, which means that an autoresponder should not reply
+// to it, following the advice of RFC 3834. This is the case when any of the
+// following is true:
+//
+// * The Precedence header is bulk, list, or junk.
+//
+// * Any List-* header is present (e.g., List-id or List-unsubscribe), as
+// defined in RFC 2369 and RFC 2919.
+//
+// * The Auto-submitted header is present with any value other than "no".
+func (h *Header) IsBulk() bool {
+	switch p, _ := h.GetPrecedence(); p {
+	case "bulk", "list", "junk":
+		return true
+	}
+
+	for _, f := range h.ListFields() {
+		name := strings.ToLower(strings.TrimSpace(f.Name()))
+		if strings.HasPrefix(name, "list-") {
+			return true
+		}
+
+		if name == strings.ToLower(AutoSubmitted) {
+			// the keyword may be followed by parameters or a comment
+			v := strings.TrimSpace(f.Body())
+			if ix := strings.IndexAny(v, "; \t("); ix >= 0 {
+				v = v[:ix]
+			}
+			if !strings.EqualFold(v, "no") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 // setAddress allows the setting of an address field either from a string or
 // from an address list or fails with an error.
 func (h *Header) setAddress(n string, as []any) error {
@@ -785,6 +1471,25 @@ func (h *Header) setAddress(n string, as []any) error {
 	return nil
 }
 
+// addAddress appends addresses given as either strings or addr.Address values
+// to the named address field, creating the field if it is not yet set. It fails
+// with an error if the existing field cannot be read or if the new addresses
+// cannot be parsed.
+func (h *Header) addAddress(n string, as []any) error {
+	al, err := h.GetAddressList(n)
+	if err != nil && !errors.Is(err, ErrNoSuchField) {
+		return err
+	}
+
+	all := make([]any, 0, len(al)+len(as))
+	for _, a := range al {
+		all = append(all, a)
+	}
+	all = append(all, as...)
+
+	return h.setAddress(n, all)
+}
+
 // GetTo returns the To address field as an addr.AddressList.
 //
 // It will return nil and ErrNoSuchField if the field is not set on the header.
@@ -802,6 +1507,17 @@ func (h *Header) SetTo(a ...any) error {
 	return h.setAddress(To, a)
 }
 
+// AddTo appends one or more addresses to the To address field. Each address
+// may be either an addr.Address or a string. If the field is not yet set, it
+// will be created.
+//
+// It will fail with an error if something other than those types is provided,
+// if the given string fails to strictly parse, or if the field is set more than
+// once on the header.
+func (h *Header) AddTo(a ...any) error {
+	return h.addAddress(To, a)
+}
+
 // GetCc returns the Cc address field as an addr.AddressList.
 //
 // It will return nil and ErrNoSuchField if the field is not set on the header.
@@ -819,6 +1535,17 @@ func (h *Header) SetCc(a ...any) error {
 	return h.setAddress(Cc, a)
 }
 
+// AddCc appends one or more addresses to the Cc address field. Each address
+// may be either an addr.Address or a string. If the field is not yet set, it
+// will be created.
+//
+// It will fail with an error if something other than those types is provided,
+// if the given string fails to strictly parse, or if the field is set more than
+// once on the header.
+func (h *Header) AddCc(a ...any) error {
+	return h.addAddress(Cc, a)
+}
+
 // GetBcc returns the Bcc address field as an addr.AddressList.
 //
 // It will return nil and ErrNoSuchField if the field is not set on the header.
@@ -837,6 +1564,17 @@ func (h *Header) SetBcc(a ...any) error {
 	return h.setAddress(Bcc, a)
 }
 
+// AddBcc appends one or more addresses to the Bcc address field. Each address
+// may be either an addr.Address or a string. If the field is not yet set, it
+// will be created.
+//
+// It will fail with an error if something other than those types is provided,
+// if the given string fails to strictly parse, or if the field is set more than
+// once on the header.
+func (h *Header) AddBcc(a ...any) error {
+	return h.addAddress(Bcc, a)
+}
+
 // GetFrom returns the From address field as an addr.AddressList.
 //
 // It will return nil and ErrNoSuchField if the field is not set on the header.
@@ -886,14 +1624,38 @@ func (h *Header) SetKeywords(ks ...string) {
 	h.SetKeywordsList(Keywords, ks...)
 }
 
-// GetComments returns the content of the Comments header fields.
+// GetComments returns the content of the Comments header fields with any MIME
+// encoded words decoded.
+//
+// It returns nil with ErrNoSuchField if there are no Comments fields.
 func (h *Header) GetComments() ([]string, error) {
-	return h.GetAll(Comments)
+	fs := h.GetAllFieldsNamed(Comments)
+	if len(fs) == 0 {
+		return nil, ErrNoSuchField
+	}
+
+	cs := make([]string, len(fs))
+	for i, f := range fs {
+		cs[i] = h.decodeWords(encodedBody(f))
+	}
+
+	return cs, nil
 }
 
-// SetComments replaces all Comments fields with the given bodies.
+// SetComments replaces all Comments fields with the given bodies. Each comment
+// containing characters outside of US-ASCII is written as MIME encoded words.
 func (h *Header) SetComments(cs ...string) {
 	h.SetAll(Comments, cs...)
+
+	if h.utf8 {
+		return
+	}
+
+	for _, f := range h.GetAllFieldsNamed(Comments) {
+		if !isASCII([]byte(f.Body())) {
+			h.setEncodedRaw(f, encodeWords(f.Body()))
+		}
+	}
 }
 
 // GetReferences returns the message ID in the References header, if any.
@@ -910,6 +1672,72 @@ func (h *Header) SetReferences(ref string) {
 	h.Set(References, ref)
 }
 
+// SetReferencesList sets the References header to the given list of message
+// IDs. Each ID will be enclosed in angle brackets, if it is not already, and the
+// IDs will be separated by a single space. Folding of a long list is left to
+// the field when the header is written.
+func (h *Header) SetReferencesList(ids ...string) {
+	refs := make([]string, len(ids))
+	for i, id := range ids {
+		refs[i] = angleBracketID(id)
+	}
+	h.Set(References, strings.Join(refs, " "))
+}
+
+// MaxReferencesLength is the longest body AddReference will produce for the
+// References header. This keeps the whole field within the 998 character line
+// limit of RFC 5322.
+const MaxReferencesLength = 998 - len(References) - 2
+
+// AddReference appends a single message ID to the end of the existing
+// References header. The ID will be enclosed in angle brackets, if it is not
+// already. If there is no References header, one will be set.
+//
+// If the resulting list of IDs would be longer than MaxReferencesLength, it is
+// trimmed using TrimReferences.
+func (h *Header) AddReference(id string) {
+	refs, _ := h.GetReferences()
+	ids := append(strings.Fields(refs), angleBracketID(id))
+	h.SetReferencesList(TrimReferences(ids, MaxReferencesLength)...)
+}
+
+// TrimReferences shortens a list of message IDs for the References header so
+// that the IDs, joined by single spaces, are no longer than maxLen. Following
+// the advice of RFC 5322, the first ID (the start of the thread) and the most
+// recent IDs are kept while IDs are dropped from the front of the list,
+// starting with the second. The first and last IDs are always kept, even if
+// they alone are longer than maxLen. The list given is not modified.
+func TrimReferences(ids []string, maxLen int) []string {
+	length := len(ids) - 1
+	for _, id := range ids {
+		length += len(id)
+	}
+
+	if len(ids) <= 2 || length <= maxLen {
+		return append([]string{}, ids...)
+	}
+
+	drop := 1
+	for length > maxLen && drop < len(ids)-1 {
+		length -= len(ids[drop]) + 1
+		drop++
+	}
+
+	return append([]string{ids[0]}, ids[drop:]...)
+}
+
+// angleBracketID returns the message ID enclosed in angle brackets.
+func angleBracketID(id string) string {
+	id = strings.TrimSpace(id)
+	if !strings.HasPrefix(id, "<") {
+		id = "<" + id
+	}
+	if !strings.HasSuffix(id, ">") {
+		id += ">"
+	}
+	return id
+}
+
 // GetInReplyTo returns the message ID in the In-reply-to header, if any.
 //
 // If In-reply-to is not set in the header, it will return an empty string with
@@ -956,6 +1784,164 @@ func (h *Header) SetSender(a ...any) error {
 	return h.setAddress(Sender, a)
 }
 
+// AllRecipients returns every recipient address found in the To, Cc, and Bcc
+// fields combined into a single list in that order. The members of a group are
+// included as individual mailboxes. An address is only included the first time
+// it is found. Addresses are compared by addr-spec with the domain compared
+// without regard to case.
+//
+// It will return an error if any of the fields is set more than once on the
+// header. A field that is not set is skipped.
+func (h *Header) AllRecipients() (addr.AddressList, error) {
+	var (
+		rcpts = addr.AddressList{}
+		seen  = map[string]bool{}
+	)
+
+	add := func(a addr.Address, localPart, domain string) {
+		key := recipientKey(localPart, domain)
+		if !seen[key] {
+			seen[key] = true
+			rcpts = append(rcpts, a)
+		}
+	}
+
+	for _, name := range []string{To, Cc, Bcc} {
+		al, err := h.GetAddressList(name)
+		if errors.Is(err, ErrNoSuchField) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to read recipients from %s: %w", name, err)
+		}
+
+		for _, a := range al {
+			switch a := a.(type) {
+			case *addr.Mailbox:
+				add(a, a.LocalPart(), a.Domain())
+			case *addr.AddrSpec:
+				add(a, a.LocalPart(), a.Domain())
+			case *addr.Group:
+				for _, mb := range a.MailboxList() {
+					add(mb, mb.LocalPart(), mb.Domain())
+				}
+			}
+		}
+	}
+
+	return rcpts, nil
+}
+
+// recipientKey returns the key used to compare recipient addresses. The local
+// part and domain are trimmed and the domain is compared without regard to
+// case.
+func recipientKey(localPart, domain string) string {
+	return strings.TrimSpace(localPart) + "@" + strings.ToLower(strings.TrimSpace(domain))
+}
+
+// DeduplicateRecipients removes every duplicate recipient address from the To,
+// Cc, and Bcc fields. Addresses are compared as done by AllRecipients. The first
+// occurrence of each address is kept, with To taking precedence over Cc and Cc
+// over Bcc. For example, an address found in both To and Bcc is removed from
+// Bcc. The members of a group are deduplicated, but the group itself is always
+// kept, even if it is left empty.
+//
+// Only the fields that change are replaced, using SetAddressList. A field left
+// with no addresses is deleted. It returns the number of addresses removed.
+//
+// It will return an error if any of the fields is set more than once on the
+// header, in which case, the header is left unchanged. A field that is not set
+// is skipped.
+func (h *Header) DeduplicateRecipients() (int, error) {
+	var (
+		seen    = map[string]bool{}
+		removed = 0
+		lists   = make(map[string]addr.AddressList, 3)
+		names   = []string{To, Cc, Bcc}
+	)
+
+	for _, name := range names {
+		al, err := h.GetAddressList(name)
+		if errors.Is(err, ErrNoSuchField) {
+			continue
+		} else if err != nil {
+			return 0, fmt.Errorf("unable to read recipients from %s: %w", name, err)
+		}
+		lists[name] = al
+	}
+
+	keep := func(localPart, domain string) bool {
+		key := recipientKey(localPart, domain)
+		if seen[key] {
+			removed++
+			return false
+		}
+		seen[key] = true
+		return true
+	}
+
+	for _, name := range names {
+		al, found := lists[name]
+		if !found {
+			continue
+		}
+
+		before := removed
+		dal := make(addr.AddressList, 0, len(al))
+		for _, a := range al {
+			switch a := a.(type) {
+			case *addr.Mailbox:
+				if keep(a.LocalPart(), a.Domain()) {
+					dal = append(dal, a)
+				}
+			case *addr.AddrSpec:
+				if keep(a.LocalPart(), a.Domain()) {
+					dal = append(dal, a)
+				}
+			case *addr.Group:
+				mbs := make(addr.MailboxList, 0, len(a.MailboxList()))
+				for _, mb := range a.MailboxList() {
+					if keep(mb.LocalPart(), mb.Domain()) {
+						mbs = append(mbs, mb)
+					}
+				}
+
+				if len(mbs) < len(a.MailboxList()) {
+					dal = append(dal, addr.NewGroupParsed(a.DisplayName(), mbs, ""))
+				} else {
+					dal = append(dal, a)
+				}
+			default:
+				dal = append(dal, a)
+			}
+		}
+
+		switch {
+		case removed == before:
+		case len(dal) == 0:
+			h.Unset(name)
+		default:
+			h.SetAddressList(name, dal...)
+		}
+	}
+
+	return removed, nil
+}
+
+// RecipientCount returns the number of unique recipient addresses found in the
+// To, Cc, and Bcc fields, as returned by AllRecipients. This is handy for
+// enforcing a limit on the number of recipients before submission.
+//
+// It will return an error if any of the fields is set more than once on the
+// header.
+func (h *Header) RecipientCount() (int, error) {
+	rcpts, err := h.AllRecipients()
+	if err != nil {
+		return 0, err
+	}
+
+	return len(rcpts), nil
+}
+
 // GetTransferEncoding returns the content of the Content-transfer-encoding
 // header.
 //
@@ -971,6 +1957,61 @@ func (h *Header) SetTransferEncoding(b string) {
 	h.Set(ContentTransferEncoding, b)
 }
 
+// SanitizeControlChars replaces every unsafe control character in the body of
+// every field with a space and returns the number of fields changed. The unsafe
+// control characters are all those below 0x20, except for tab, which includes
+// CR, LF, NUL, form feed, and escape.
+//
+// When a message is parsed, control characters found in header bodies are
+// preserved as-is so that the message will round-trip unchanged. They are
+// returned by the getters as well. Call this before handing the header to
+// downstream software that may not tolerate them. The fields changed lose
+// their original formatting and will be folded and encoded anew when written.
+func (h *Header) SanitizeControlChars() int {
+	changed := 0
+	for _, f := range h.ListFields() {
+		body := f.Body()
+		if strings.IndexFunc(body, isUnsafeControl) < 0 {
+			continue
+		}
+
+		f.SetBody(strings.Map(func(c rune) rune {
+			if isUnsafeControl(c) {
+				return ' '
+			}
+			return c
+		}, body))
+		delete(h.valueCache, strings.ToLower(f.Name()))
+		changed++
+	}
+	return changed
+}
+
+// isUnsafeControl returns true for the control characters that
+// SanitizeControlChars replaces.
+func isUnsafeControl(c rune) bool {
+	return c < 0x20 && c != '\t'
+}
+
+// RewriteReceived calls fn with the body of each Received field and replaces
+// the body with the string returned. Each field keeps its position in the
+// header. This is intended for redacting information, such as internal host
+// names and IP addresses, before a message is archived.
+//
+// A field for which fn returns the body unchanged is not modified, so it will
+// still be written exactly as it was parsed. A field that is changed is
+// written according to the FoldEncoding of the header. Parsed headers use
+// field.DoNotFoldEncoding, so the rewritten fields will be written on a single
+// line unless SetFoldEncoding is used to choose another.
+func (h *Header) RewriteReceived(fn func(raw string) string) {
+	for _, f := range h.GetAllFieldsNamed(Received) {
+		body := f.Body()
+		if nb := fn(body); nb != body {
+			f.SetBody(nb)
+		}
+	}
+}
+
 // TODO Add support for resent blocks
 
 // TODO Add support for trace fields (Return-Path and Received)
@@ -984,19 +2025,45 @@ func (h *Header) SetTransferEncoding(b string) {
 //
 // It works as follows:
 //
-// 1. Split the string up by commas.
+// 1. Split the string up by commas (see splitAddressList).
 // 2. Each string resulting from the split is trimmed of whitespace.
 // 3. The comments are stripped from each string and held.
 // 4. All the words at the start are treated as the display name.
-// 5. The last word at the end is treated as the email address.
+// 5. The last word at the end is treated as the email address, without any
+// angle brackets around it.
 //
 // As some address fields have something other than an address in it because
 // people on the Internet are weird, the result will be wrong sometimes.
 //
-// We stuff whatever we get into an addr.Mailbox and call it good. As they are
-// so rare, we will assume we are never dealing with groups. This may lead to
-// oddness if a group is encountered.
+// We stuff whatever we get into an addr.Mailbox and call it good. The
+// exception is a group, such as "A Group: a@x, b@y;", which is kept together
+// and each of the members is treated as above and put into an addr.Group.
 func parseEmailAddressList(v string) addr.AddressList {
+	items := splitAddressList(v)
+	as := make(addr.AddressList, 0, len(items))
+	for _, item := range items {
+		if dn, members, isGroup := splitGroup(item); isGroup {
+			mbs := make(addr.MailboxList, 0, len(members))
+			for _, m := range members {
+				if mailbox := parseLenientMailbox(m); mailbox != nil {
+					mbs = append(mbs, mailbox)
+				}
+			}
+			as = append(as, addr.NewGroupParsed(dn, mbs, item))
+			continue
+		}
+
+		if mailbox := parseLenientMailbox(item); mailbox != nil {
+			as = append(as, mailbox)
+		}
+	}
+
+	return as
+}
+
+// parseLenientMailbox implements the lenient parsing of a single mailbox for
+// parseEmailAddressList. It returns nil if no address is found.
+func parseLenientMailbox(orig string) *addr.Mailbox {
 	extractComments := func(s string) (string, string) {
 		var clean, comment strings.Builder
 		nestLevel := 0
@@ -1030,51 +2097,396 @@ func parseEmailAddressList(v string) addr.AddressList {
 		return clean.String(), comment.String()
 	}
 
-	mbs := strings.Split(v, ",")
-	as := make(addr.AddressList, 0, len(mbs))
-	for _, orig := range mbs {
-		mb, com := extractComments(orig)
+	mb, com := extractComments(orig)
+
+	mb = strings.TrimSpace(mb)
+	com = strings.TrimSpace(com)
+
+	parts := strings.Fields(mb)
+
+	var dn, email string
+	switch {
+	case len(parts) == 0:
+		email = ""
+	case len(parts) > 1:
+		dn = strings.Join(parts[:len(parts)-1], " ")
+		email = parts[len(parts)-1]
+	default:
+		email = parts[0]
+	}
+
+	// the brackets around an angle-addr are not part of the address
+	if strings.HasPrefix(email, "<") && strings.HasSuffix(email, ">") {
+		email = email[1 : len(email)-1]
+	}
+
+	if email == "" {
+		return nil
+	}
 
-		mb = strings.TrimSpace(mb)
-		com = strings.TrimSpace(com)
+	var addrSpec *addr.AddrSpec
+	if i := strings.Index(email, "@"); i > -1 {
+		addrSpec = addr.NewAddrSpecParsed(
+			email[:i],
+			email[i+1:],
+			email,
+		)
+	} else {
+		addrSpec = addr.NewAddrSpecParsed(
+			email,
+			"",
+			email,
+		)
+	}
 
-		parts := strings.Fields(mb)
+	mailbox, err := addr.NewMailboxParsed(dn, addrSpec, com, orig)
+	if err != nil {
+		mailbox, _ = addr.NewMailboxParsed(dn, addrSpec, "", orig)
+	}
 
-		var dn, email string
+	return mailbox
+}
+
+// splitAddressList splits an address list on the commas between addresses,
+// trims each address of whitespace, and drops any that are empty. Commas inside
+// of quoted strings, comments, and angle brackets do not split. A group, such
+// as "A Group: a@x, b@y;", is kept together as one item, including the
+// terminating semicolon. A colon only starts a group if a semicolon follows
+// it, so an unterminated group is split as if it were not a group.
+func splitAddressList(v string) []string {
+	var (
+		items   = make([]string, 0, strings.Count(v, ",")+1)
+		start   = 0
+		quoted  = false
+		escaped = false
+		comment = 0
+		angle   = 0
+		group   = false
+	)
+
+	for i, c := range v {
 		switch {
-		case len(parts) == 0:
-			email = ""
-		case len(parts) > 1:
-			dn = strings.Join(parts[:len(parts)-1], " ")
-			email = parts[len(parts)-1]
-		default:
-			email = parts[0]
+		case escaped:
+			escaped = false
+		case c == '\\' && (quoted || comment > 0):
+			escaped = true
+		case quoted:
+			quoted = c != '"'
+		case c == '(':
+			comment++
+		case comment > 0:
+			if c == ')' {
+				comment--
+			}
+		case c == '"':
+			quoted = true
+		case c == '<':
+			angle++
+		case c == '>' && angle > 0:
+			angle--
+		case angle > 0:
+		case c == ':' && !group:
+			group = strings.ContainsRune(v[i:], ';')
+		case c == ';' && group:
+			group = false
+		case c == ',' && !group:
+			if item := strings.TrimSpace(v[start:i]); item != "" {
+				items = append(items, item)
+			}
+			start = i + 1
+		}
+	}
+
+	if item := strings.TrimSpace(v[start:]); item != "" {
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// splitGroup checks whether an item returned by splitAddressList is a group. If
+// so, it returns the display name of the group with the whitespace collapsed
+// and any quotes removed, the addresses of the members, and true. Otherwise, it
+// returns false.
+func splitGroup(item string) (string, []string, bool) {
+	if !strings.HasSuffix(item, ";") {
+		return "", nil, false
+	}
+
+	// the colon starting the group is the first one not in a quoted string
+	ix := -1
+	quoted := false
+	for i, c := range item {
+		if c == '"' {
+			quoted = !quoted
+		} else if c == ':' && !quoted {
+			ix = i
+			break
 		}
+	}
+
+	if ix < 0 {
+		return "", nil, false
+	}
+
+	dn := strings.Join(strings.Fields(item[:ix]), " ")
+	if len(dn) >= 2 && strings.HasPrefix(dn, `"`) && strings.HasSuffix(dn, `"`) {
+		dn = dn[1 : len(dn)-1]
+	}
+
+	return dn, splitAddressList(item[ix+1 : len(item)-1]), true
+}
 
-		if email != "" {
-			var addrSpec *addr.AddrSpec
-			if i := strings.Index(email, "@"); i > -1 {
-				addrSpec = addr.NewAddrSpecParsed(
-					email[:i],
-					email[i+1:],
-					email,
-				)
-			} else {
-				addrSpec = addr.NewAddrSpecParsed(
-					email,
-					"",
-					email,
-				)
+// parseAddressListStrict performs a strict parse of the address list using
+// addr.ParseEmailAddressList. That parser is unable to handle a group with a
+// member that is a bare address (e.g., "A Group: a@x.com;"), so when that
+// fails, the list is split apart with splitAddressList and each address and
+// group member is strictly parsed on its own. The display name of each group
+// is also restored from the original as the parser drops the whitespace from
+// it.
+func parseAddressListStrict(v string) (addr.AddressList, error) {
+	al, err := safeParseEmailAddressList(v)
+	if err == nil {
+		return restoreGroupNames(al), nil
+	}
+
+	if !errors.Is(err, errAddressParserPanic) {
+		return al, err
+	}
+
+	items := splitAddressList(v)
+	al = make(addr.AddressList, 0, len(items))
+	for _, item := range items {
+		dn, members, isGroup := splitGroup(item)
+		if !isGroup {
+			a, err := safeParseEmailAddress(item)
+			if err != nil {
+				return al, err
 			}
+			al = append(al, a)
+			continue
+		}
 
-			mailbox, err := addr.NewMailboxParsed(dn, addrSpec, com, orig)
+		mbs := make(addr.MailboxList, len(members))
+		for i, m := range members {
+			mbs[i], err = safeParseEmailMailbox(m)
 			if err != nil {
-				mailbox, _ = addr.NewMailboxParsed(dn, addrSpec, "", orig)
+				return al, err
 			}
+		}
+		al = append(al, addr.NewGroupParsed(dn, mbs, item))
+	}
 
-			as = append(as, mailbox)
+	return al, nil
+}
+
+// getEncoded returns the body of the first field with the given name as it was
+// before any MIME encoded words in it were decoded. It returns an empty string
+// if there is no such field.
+func (h *Header) getEncoded(name string) string {
+	ixs := h.GetIndexesNamed(name)
+	if len(ixs) == 0 {
+		return ""
+	}
+
+	return encodedBody(h.GetField(ixs[0]))
+}
+
+// encodedBody returns the body of the field as it was before any MIME encoded
+// words in it were decoded. This is the unfolded Raw body, if the field has
+// one, or the unfolded body otherwise. The body of a field set with Set may
+// still contain folding, so it is unfolded too, which lets a folded address
+// field be parsed strictly.
+func encodedBody(f *field.Field) string {
+	body := f.Body()
+	if f.Raw != nil {
+		body = f.Raw.Body()
+	}
+
+	unfolded := field.DefaultFoldEncoding.Unfold([]byte(body))
+	return strings.TrimSpace(string(unfolded))
+}
+
+// parseEncodedAddressList parses an address list out of a field body that may
+// contain MIME encoded words. The strict parse is made on the encoded body so
+// that only the display names are decoded, never the addr-spec. If that fails,
+// the decoded body is given to ParseAddressList instead. Encoded words with
+// whitespace in them are repaired first (see field.RepairEncodedWords) so that
+// they are not split apart by the address parser.
+func (h *Header) parseEncodedAddressList(encoded, decoded string) addr.AddressList {
+	if al, err := parseAddressListStrict(field.RepairEncodedWords(encoded)); err == nil {
+		return h.decodeDisplayNames(al)
+	}
+
+	return ParseAddressList(decoded)
+}
+
+// decodeDisplayNames replaces each address in the list having a display name
+// containing MIME encoded words with a copy that has the display name decoded.
+// The display names of group members are decoded as well.
+func (h *Header) decodeDisplayNames(al addr.AddressList) addr.AddressList {
+	for i, a := range al {
+		switch a := a.(type) {
+		case *addr.Mailbox:
+			al[i] = h.decodeMailboxName(a)
+		case *addr.Group:
+			mbs := make(addr.MailboxList, len(a.MailboxList()))
+			for j, mb := range a.MailboxList() {
+				mbs[j] = h.decodeMailboxName(mb)
+			}
+			al[i] = addr.NewGroupParsed(h.decodeWords(a.DisplayName()), mbs, a.OriginalString())
 		}
 	}
+	return al
+}
 
-	return as
+// decodeMailboxName returns a copy of the mailbox with the display name
+// decoded, or the mailbox itself if the display name has no MIME encoded words.
+func (h *Header) decodeMailboxName(mb *addr.Mailbox) *addr.Mailbox {
+	dn := h.decodeWords(mb.DisplayName())
+	if dn == mb.DisplayName() {
+		return mb
+	}
+
+	dmb, err := addr.NewMailboxParsed(dn, mb.AddrSpec(), mb.Comment(), mb.OriginalString())
+	if err != nil {
+		return mb
+	}
+	return dmb
+}
+
+// encodeDisplayNames returns a copy of the address list with each display name
+// containing characters outside of US-ASCII encoded as a MIME encoded word.
+// This includes the display names of groups and their members.
+func encodeDisplayNames(al []addr.Address) addr.AddressList {
+	eal := make(addr.AddressList, len(al))
+	for i, a := range al {
+		switch a := a.(type) {
+		case *addr.Mailbox:
+			eal[i] = encodeMailboxName(a)
+		case *addr.Group:
+			mbs := make(addr.MailboxList, len(a.MailboxList()))
+			for j, mb := range a.MailboxList() {
+				mbs[j] = encodeMailboxName(mb)
+			}
+			eal[i] = addr.NewGroupParsed(encodeWords(a.DisplayName()), mbs, "")
+		default:
+			eal[i] = a
+		}
+	}
+	return eal
+}
+
+// encodeMailboxName returns a copy of the mailbox with the display name
+// encoded, or the mailbox itself if the display name needs no encoding.
+func encodeMailboxName(mb *addr.Mailbox) *addr.Mailbox {
+	dn := encodeWords(mb.DisplayName())
+	if dn == mb.DisplayName() {
+		return mb
+	}
+
+	emb, err := addr.NewMailboxParsed(dn, mb.AddrSpec(), mb.Comment(), "")
+	if err != nil {
+		return mb
+	}
+	return emb
+}
+
+// encodedWordPattern matches a single MIME encoded word.
+var encodedWordPattern = regexp.MustCompile(`=\?[^?\s]+\?[bBqQ]\?[^?\s]*\?=`)
+
+// splitKeywords splits a Keywords body on each comma that is not inside of a
+// MIME encoded word.
+func splitKeywords(body string) []string {
+	words := encodedWordPattern.FindAllStringIndex(body, -1)
+
+	var (
+		ks    []string
+		start int
+	)
+
+Commas:
+	for i := 0; i < len(body); i++ {
+		if body[i] != ',' {
+			continue
+		}
+
+		for _, w := range words {
+			if i > w[0] && i < w[1] {
+				continue Commas
+			}
+		}
+
+		ks = append(ks, body[start:i])
+		start = i + 1
+	}
+
+	return append(ks, body[start:])
+}
+
+// encodeWords encodes text as MIME encoded words using UTF-8 if it contains
+// any characters outside of US-ASCII. Text that is mostly ASCII is Q encoded,
+// which leaves it readable. Otherwise, it is B encoded, which is shorter.
+func encodeWords(s string) string {
+	n := 0
+	for _, c := range s {
+		if c >= utf8.RuneSelf {
+			n++
+		}
+	}
+
+	if n > utf8.RuneCountInString(s)/2 {
+		return mime.BEncoding.Encode("utf-8", s)
+	}
+
+	return mime.QEncoding.Encode("utf-8", s)
+}
+
+// restoreGroupNames replaces each group parsed by addr.ParseEmailAddressList
+// with a copy that has the display name restored from the original string.
+func restoreGroupNames(al addr.AddressList) addr.AddressList {
+	for i, a := range al {
+		g, isGroup := a.(*addr.Group)
+		if !isGroup {
+			continue
+		}
+
+		if dn, _, isGroup := splitGroup(g.OriginalString()); isGroup {
+			al[i] = addr.NewGroupParsed(dn, g.MailboxList(), g.OriginalString())
+		}
+	}
+	return al
+}
+
+// errAddressParserPanic is wrapped by the error returned when the parser in
+// github.com/zostay/go-addr panics.
+var errAddressParserPanic = errors.New("the address parser failed")
+
+// recoverAddressParser recovers from a panic in the parser in
+// github.com/zostay/go-addr and turns it into an error.
+func recoverAddressParser(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("%w: %v", errAddressParserPanic, r)
+	}
+}
+
+// safeParseEmailAddressList calls addr.ParseEmailAddressList, but returns an
+// error rather than panicking.
+func safeParseEmailAddressList(v string) (al addr.AddressList, err error) {
+	defer recoverAddressParser(&err)
+	return addr.ParseEmailAddressList(v)
+}
+
+// safeParseEmailAddress calls addr.ParseEmailAddress, but returns an error
+// rather than panicking.
+func safeParseEmailAddress(v string) (a addr.Address, err error) {
+	defer recoverAddressParser(&err)
+	return addr.ParseEmailAddress(v)
+}
+
+// safeParseEmailMailbox calls addr.ParseEmailMailbox, but returns an error
+// rather than panicking.
+func safeParseEmailMailbox(v string) (mb *addr.Mailbox, err error) {
+	defer recoverAddressParser(&err)
+	return addr.ParseEmailMailbox(v)
 }
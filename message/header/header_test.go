@@ -3,7 +3,9 @@ package header_test
 import (
 	"bytes"
 	"fmt"
+	"regexp"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -13,6 +15,7 @@ import (
 
 	"github.com/zostay/go-email/v2/message"
 	"github.com/zostay/go-email/v2/message/header"
+	"github.com/zostay/go-email/v2/message/header/field"
 	"github.com/zostay/go-email/v2/message/header/param"
 	"github.com/zostay/go-email/v2/message/transfer"
 )
@@ -204,7 +207,7 @@ func TestHeader_SetMediaType(t *testing.T) {
 	assert.NoError(t, err)
 
 	const afterHeaderStr = `Subject: test
-Content-type: text/html; boundary=abc123; charset=latin1
+Content-type: text/html; charset=latin1; boundary=abc123
 
 `
 
@@ -216,7 +219,7 @@ Content-type: text/html; boundary=abc123; charset=latin1
 	m.GetHeader().SetMediaType("x-text/mshtml")
 
 	const afterHeaderStr2 = `Subject: test
-Content-type: x-text/mshtml; boundary=abc123; charset=latin1
+Content-type: x-text/mshtml; charset=latin1; boundary=abc123
 
 `
 
@@ -226,6 +229,48 @@ Content-type: x-text/mshtml; boundary=abc123; charset=latin1
 	assert.Equal(t, afterHeaderStr2, buf.String())
 }
 
+func TestHeader_SetBoundary_PreservesOrder(t *testing.T) {
+	t.Parallel()
+
+	const headerStr = `Content-type: multipart/alternative; charset=utf-8; boundary=abc; format=flowed
+
+`
+
+	m, err := message.Parse(strings.NewReader(headerStr))
+	require.NoError(t, err)
+
+	err = m.GetHeader().SetBoundary("xyz")
+	assert.NoError(t, err)
+
+	ct, err := m.GetHeader().GetContentType()
+	require.NoError(t, err)
+	m.GetHeader().SetContentType(param.Modify(ct, param.Set("delsp", "yes")))
+
+	const expect = `Content-type: multipart/alternative; charset=utf-8; boundary=xyz; format=flowed; delsp=yes
+
+`
+
+	buf := &strings.Builder{}
+	_, err = m.GetHeader().WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, expect, buf.String())
+}
+
+func TestHeader_SetCharset_PreservesParameters(t *testing.T) {
+	t.Parallel()
+
+	h, err := header.Parse([]byte(
+		`Content-Type: Text/Plain; Charset="us-ascii"; format=flowed; DelSp=yes; name="my notes.txt"`+"\n"), header.LF)
+	require.NoError(t, err)
+
+	require.NoError(t, h.SetCharset("utf-8"))
+	require.NoError(t, h.SetContentTypeParam("format", "fixed"))
+
+	body, err := h.Get(header.ContentType)
+	assert.NoError(t, err)
+	assert.Equal(t, `Text/Plain; charset=utf-8; format=fixed; DelSp=yes; name="my notes.txt"`, body)
+}
+
 func TestHeader_HeaderContentDisposition(t *testing.T) {
 	t.Parallel()
 
@@ -310,6 +355,39 @@ To: sterling@example.com, steve@example.com, bob@example.com
 	assert.Equal(t, afterHeaderStr, buf.String())
 }
 
+func TestHeader_SetAddressList_QuotedDisplayName(t *testing.T) {
+	t.Parallel()
+
+	const headerStr = `To: "Smith, Agent" <a@b>
+
+`
+
+	m, err := message.Parse(strings.NewReader(headerStr))
+	require.NoError(t, err)
+
+	to, err := m.GetHeader().GetTo()
+	require.NoError(t, err)
+	require.Len(t, to, 1)
+	assert.Equal(t, "Smith, Agent", to[0].DisplayName())
+
+	// re-emit the parsed addresses without the original raw field
+	m.GetHeader().SetAddressList(header.To, to...)
+
+	dr, err := addr.NewMailboxStr("Dr. J. Doe", "j@example.com", "")
+	require.NoError(t, err)
+	m.GetHeader().SetAddressList(header.Cc, dr)
+
+	const afterHeaderStr = `To: "Smith, Agent" <a@b>
+Cc: "Dr. J. Doe" <j@example.com>
+
+`
+
+	buf := &strings.Builder{}
+	_, err = m.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, afterHeaderStr, buf.String())
+}
+
 func TestHeader_HeaderGetDate(t *testing.T) {
 	t.Parallel()
 
@@ -349,6 +427,52 @@ Date: Mon, 05 Dec 2022 16:46:38 +0000
 	assert.Equal(t, afterHeaderStr, buf.String())
 }
 
+func TestValidateDate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		body     string
+		mismatch bool
+		invalid  bool
+	}{
+		{"matching day", "Mon, 05 Dec 2022 16:46:38 +0000", false, false},
+		{"no day", "05 Dec 2022 16:46:38 +0000", false, false},
+		{"lowercase day", "mon, 05 Dec 2022 16:46:38 +0000", false, false},
+		{"day in zone", "Sun, 04 Dec 2022 20:46:38 -0800", false, false},
+		{"wrong day", "Tue, 05 Dec 2022 16:46:38 +0000", true, false},
+		{"comma in comment", "2 Jan 2006 15:04 -0700 (foo, bar)", false, false},
+		{"wrong day with comment", "Tue, 05 Dec 2022 16:46:38 +0000 (a, b)", true, false},
+		{"unparseable", "not a date", false, true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := header.ValidateDate(test.body)
+			switch {
+			case test.mismatch:
+				assert.ErrorIs(t, err, header.ErrWeekdayMismatch)
+				assert.Contains(t, err.Error(), "Monday")
+			case test.invalid:
+				assert.Error(t, err)
+				assert.NotErrorIs(t, err, header.ErrWeekdayMismatch)
+			default:
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	// the lenient getter still accepts a date with the wrong day
+	h := &header.Header{}
+	h.Set(header.Date, "Tue, 05 Dec 2022 16:46:38 +0000")
+	d, err := h.GetDate()
+	assert.NoError(t, err)
+	assert.Equal(t, 5, d.Day())
+}
+
 func TestNewHeader(t *testing.T) {
 	t.Parallel()
 
@@ -486,6 +610,65 @@ func TestHeader_GetAddressList(t *testing.T) {
 	assert.ErrorIs(t, err, header.ErrManyFields)
 }
 
+func TestHeader_GetAddressListStrict(t *testing.T) {
+	t.Parallel()
+
+	h := &header.Header{}
+	h.Set(header.To, "sterling@example.com, Steve <steve@example.com>")
+	h.Set(header.Cc, "this is not an address")
+
+	al, err := h.GetAddressListStrict(header.To)
+	assert.NoError(t, err)
+	assert.Len(t, al, 2)
+
+	_, err = h.GetAddressListStrict(header.Cc)
+	assert.Error(t, err)
+
+	// the lenient version still returns something
+	al, err = h.GetAddressList(header.Cc)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, al)
+
+	_, err = h.GetAddressListStrict(header.Bcc)
+	assert.ErrorIs(t, err, header.ErrNoSuchField)
+}
+
+func TestHeader_GetAddressListStrict_Folded(t *testing.T) {
+	t.Parallel()
+
+	parsed, err := header.Parse([]byte(
+		"From: Foo\r\n (the great) <foo@example.com>\r\n"+
+			"Sender: \"Bar\r\n Baz\" (the\r\n\tlesser) <bar@example.com>"), header.CRLF)
+	require.NoError(t, err)
+
+	set := &header.Header{}
+	set.Set(header.From, "Foo\n (the great) <foo@example.com>")
+	set.Set(header.Sender, "\"Bar\n Baz\" (the\n\tlesser) <bar@example.com>")
+
+	for _, h := range []*header.Header{parsed, set} {
+		from, err := h.GetAddressListStrict(header.From)
+		require.NoError(t, err)
+		require.Len(t, from, 1)
+		fmb, isMailbox := from[0].(*addr.Mailbox)
+		require.True(t, isMailbox)
+		assert.Equal(t, "Foo", fmb.DisplayName())
+		assert.Equal(t, "the great", fmb.Comment())
+		assert.Equal(t, "foo@example.com", fmb.Address())
+
+		sender, err := h.GetAddressListStrict(header.Sender)
+		require.NoError(t, err)
+		require.Len(t, sender, 1)
+		smb, isMailbox := sender[0].(*addr.Mailbox)
+		require.True(t, isMailbox)
+		assert.Equal(t, "Bar Baz", smb.DisplayName())
+		assert.Equal(t, "the\tlesser", smb.Comment())
+
+		lenient, err := h.GetSender()
+		assert.NoError(t, err)
+		assert.Equal(t, sender.String(), lenient.String())
+	}
+}
+
 func TestHeader_GetAllAddressLists(t *testing.T) {
 	t.Parallel()
 
@@ -677,6 +860,168 @@ B: two
 	assert.Equal(t, expect, buf.String())
 }
 
+func TestHeader_InsertFields(t *testing.T) {
+	t.Parallel()
+
+	h := &header.Header{}
+	h.SetSubject("hello")
+	h.SetKeywords("a")
+
+	// warm the cache
+	kws, err := h.GetKeywords()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, kws)
+
+	h.InsertFields(h.Len(),
+		field.New(header.Keywords, "b, c"),
+		field.New(header.To, "x@example.com"),
+	)
+
+	kws, err = h.GetKeywords()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, kws)
+
+	al, err := h.GetTo()
+	assert.NoError(t, err)
+	assert.Equal(t, "x@example.com", al.String())
+
+	assert.Equal(t, []int{3}, h.GetIndexesNamed(header.To))
+}
+
+func TestHeader_PrependRawField(t *testing.T) {
+	t.Parallel()
+
+	h, err := header.Parse([]byte("From: a@example.com\r\nSubject: signed"), header.CRLF)
+	require.NoError(t, err)
+
+	raw := []byte(" v=1; a=rsa-sha256; d=example.com;\r\n\t h=from:subject;  b=")
+	h.PrependRawField("DKIM-Signature", raw)
+
+	buf := &bytes.Buffer{}
+	_, err = h.WriteTo(buf)
+	require.NoError(t, err)
+	assert.Equal(t,
+		"DKIM-Signature: v=1; a=rsa-sha256; d=example.com;\r\n\t h=from:subject;  b=\r\n"+
+			"From: a@example.com\r\nSubject: signed\r\n\r\n",
+		buf.String())
+
+	body, err := h.Get("DKIM-Signature")
+	assert.NoError(t, err)
+	assert.Equal(t, "v=1; a=rsa-sha256; d=example.com;\t h=from:subject;  b=", body)
+	assert.Equal(t, []int{0}, h.GetIndexesNamed("Dkim-signature"))
+}
+
+func TestHeader_InsertTyped(t *testing.T) {
+	t.Parallel()
+
+	h := &header.Header{}
+	h.Set(header.From, "me@example.com")
+	h.SetSubject("hello")
+
+	date := time.Date(2023, 4, 5, 6, 7, 8, 0, time.UTC)
+	h.InsertTime(1, header.Date, date)
+
+	to, err := addr.ParseEmailAddressList("you@example.com")
+	require.NoError(t, err)
+	h.InsertAddressList(0, header.To, to...)
+
+	pv := param.New("text/plain", map[string]string{"charset": "utf-8"})
+	h.InsertParamValue(h.Len(), header.ContentType, pv)
+	h.InsertKeywordsList(h.Len(), header.Keywords, "a", "b")
+
+	buf := &bytes.Buffer{}
+	_, err = h.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "To: you@example.com\n"+
+		"From: me@example.com\n"+
+		"Date: Wed, 05 Apr 2023 06:07:08 +0000\n"+
+		"Subject: hello\n"+
+		"Content-type: text/plain; charset=utf-8\n"+
+		"Keywords: a, b\n\n", buf.String())
+
+	d, err := h.GetDate()
+	assert.NoError(t, err)
+	assert.Equal(t, date, d)
+
+	al, err := h.GetTo()
+	assert.NoError(t, err)
+	assert.Equal(t, "you@example.com", al.String())
+
+	cs, err := h.GetCharset()
+	assert.NoError(t, err)
+	assert.Equal(t, "utf-8", cs)
+
+	kws, err := h.GetKeywords()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, kws)
+
+	// a second field of the same name discards the cached value
+	h.InsertTime(0, header.Date, date)
+	_, err = h.GetDate()
+	assert.ErrorIs(t, err, header.ErrManyFields)
+}
+
+func TestHeader_Occurrences(t *testing.T) {
+	t.Parallel()
+
+	h := &header.Header{}
+	h.Set("X-Foo", "one")
+	h.SetSubject("hello")
+	h.InsertBeforeField(h.Len(), "x-foo", "two")
+	h.InsertBeforeField(h.Len(), "X-Bar", "other")
+	h.InsertBeforeField(h.Len(), "X-FOO", "three")
+
+	os := h.Occurrences("X-Foo")
+	assert.Equal(t, []header.Occurrence{
+		{Index: 0, Body: "one"},
+		{Index: 2, Body: "two"},
+		{Index: 4, Body: "three"},
+	}, os)
+
+	assert.NoError(t, h.DeleteField(os[1].Index))
+	all, err := h.GetAll("X-Foo")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"one", "three"}, all)
+
+	assert.Empty(t, h.Occurrences("X-Missing"))
+}
+
+func TestHeader_Unset(t *testing.T) {
+	t.Parallel()
+
+	h := &header.Header{}
+	h.SetSubject("hello")
+	h.Set(header.To, "")
+
+	// an empty body is still a field
+	to, err := h.Get(header.To)
+	assert.NoError(t, err)
+	assert.Equal(t, "", to)
+
+	al, err := h.GetTo()
+	assert.NoError(t, err)
+	assert.Len(t, al, 0)
+
+	buf := &bytes.Buffer{}
+	_, err = h.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "Subject: hello\nTo: \n\n", buf.String())
+
+	// unset removes the field altogether
+	h.Unset(header.To)
+
+	_, err = h.Get(header.To)
+	assert.ErrorIs(t, err, header.ErrNoSuchField)
+
+	_, err = h.GetTo()
+	assert.ErrorIs(t, err, header.ErrNoSuchField)
+
+	buf.Reset()
+	_, err = h.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "Subject: hello\n\n", buf.String())
+}
+
 func TestHeader_Set2(t *testing.T) {
 	// check the edge case when the deleted field is last
 	t.Parallel()
@@ -769,6 +1114,36 @@ func TestHeader_SetAllAddressLists(t *testing.T) {
 	}, bs)
 }
 
+func TestHeader_SetAllAddressListsFromStrings(t *testing.T) {
+	t.Parallel()
+
+	h := &header.Header{}
+	err := h.SetAllAddressListsFromStrings("Delivered-to",
+		"sterling@example.com",
+		`"Steve Steverson" <steve@example.com>, stan@example.com`,
+	)
+	assert.NoError(t, err)
+
+	bs, err := h.GetAll("Delivered-to")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"sterling@example.com",
+		`"Steve Steverson" <steve@example.com>, stan@example.com`,
+	}, bs)
+
+	err = h.SetAllAddressListsFromStrings("Delivered-to",
+		"stu@example.com",
+		"not an address",
+	)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `address list 1 ("not an address")`)
+
+	// the header is not modified on error
+	bs, err = h.GetAll("Delivered-to")
+	assert.NoError(t, err)
+	assert.Len(t, bs, 2)
+}
+
 func TestHeader_SetParamValue(t *testing.T) {
 	t.Parallel()
 
@@ -810,6 +1185,30 @@ func TestHeader_SetContentType(t *testing.T) {
 	assert.Equal(t, "text/plain; boundary=abc123", b)
 }
 
+func TestHeader_SetContentTypeParts(t *testing.T) {
+	t.Parallel()
+
+	h := &header.Header{}
+	h.SetContentTypeParts("text/plain", map[string]string{
+		param.Charset: "utf-8",
+		"format":      "flowed",
+	})
+
+	b, err := h.Get(header.ContentType)
+	assert.NoError(t, err)
+	assert.Equal(t, "text/plain; charset=utf-8; format=flowed", b)
+
+	cs, err := h.GetCharset()
+	assert.NoError(t, err)
+	assert.Equal(t, "utf-8", cs)
+
+	h.SetContentTypeParts("application/octet-stream", nil)
+
+	b, err = h.Get(header.ContentType)
+	assert.NoError(t, err)
+	assert.Equal(t, "application/octet-stream", b)
+}
+
 func TestHeader_GetMediaType(t *testing.T) {
 	t.Parallel()
 
@@ -853,6 +1252,43 @@ func TestHeader_SetCharset(t *testing.T) {
 	assert.Equal(t, "something; charset=something", b)
 }
 
+func TestHeader_GetContentTypeParam(t *testing.T) {
+	t.Parallel()
+
+	h := &header.Header{}
+
+	_, err := h.GetContentTypeParam("duration")
+	assert.ErrorIs(t, err, header.ErrNoSuchField)
+
+	h.InsertBeforeField(0, header.ContentType, "audio/ogg; Duration=93")
+
+	d, err := h.GetContentTypeParam("DURATION")
+	assert.NoError(t, err)
+	assert.Equal(t, "93", d)
+
+	_, err = h.GetContentTypeParam("bitrate")
+	assert.ErrorIs(t, err, header.ErrNoSuchFieldParameter)
+}
+
+func TestHeader_SetContentTypeParam(t *testing.T) {
+	t.Parallel()
+
+	h := &header.Header{}
+
+	err := h.SetContentTypeParam("duration", "93")
+	assert.ErrorIs(t, err, header.ErrNoSuchField)
+
+	h.SetMediaType("audio/ogg")
+	err = h.SetContentTypeParam("Duration", "93")
+	assert.NoError(t, err)
+	err = h.SetContentTypeParam("duration", "94")
+	assert.NoError(t, err)
+
+	b, err := h.Get(header.ContentType)
+	assert.NoError(t, err)
+	assert.Equal(t, "audio/ogg; duration=94", b)
+}
+
 func TestHeader_SetBoundary(t *testing.T) {
 	t.Parallel()
 
@@ -868,6 +1304,13 @@ func TestHeader_SetBoundary(t *testing.T) {
 	b, err := h.Get(header.ContentType)
 	assert.NoError(t, err)
 	assert.Equal(t, "something; boundary=something", b)
+
+	err = h.SetBoundary("bad\nboundary")
+	assert.ErrorIs(t, err, header.ErrInvalidBoundary)
+
+	b, err = h.Get(header.ContentType)
+	assert.NoError(t, err)
+	assert.Equal(t, "something; boundary=something", b)
 }
 
 func TestHeader_GetContentDisposition(t *testing.T) {
@@ -973,50 +1416,235 @@ func TestHeader_GetSubject(t *testing.T) {
 	assert.Equal(t, "this is a test", s)
 }
 
-func TestHeader_SetSubject(t *testing.T) {
+func TestDecodeWordRecursive(t *testing.T) {
 	t.Parallel()
 
-	h := &header.Header{}
-	h.SetSubject("woo boo too")
+	const (
+		once  = "=?utf-8?q?Caf=C3=A9?="
+		twice = "=?utf-8?q?=3D=3Futf-8=3Fq=3FCaf=3DC3=3DA9=3F=3D?="
+	)
 
-	b, err := h.Get(header.Subject)
+	s, err := header.DecodeWordRecursive(twice)
 	assert.NoError(t, err)
-	assert.Equal(t, "woo boo too", b)
+	assert.Equal(t, "Café", s)
+
+	s, err = header.DecodeWordRecursive(once)
+	assert.NoError(t, err)
+	assert.Equal(t, "Café", s)
+
+	s, err = header.DecodeWordRecursive("plain =? text")
+	assert.NoError(t, err)
+	assert.Equal(t, "plain =? text", s)
 }
 
-func TestHeader_Get_BccCcToFromSenderReplyTo(t *testing.T) {
+func TestHeader_GetSubjectLenient(t *testing.T) {
 	t.Parallel()
 
-	const sterling = `sterling@example.com`
-
-	h := &header.Header{}
-	h.InsertBeforeField(0, "to", sterling)
-	h.InsertBeforeField(1, "cc", sterling)
-	h.InsertBeforeField(2, "bcc", sterling)
-	h.InsertBeforeField(3, "from", sterling)
-	h.InsertBeforeField(4, "sender", sterling)
-	h.InsertBeforeField(5, "reply-to", sterling)
+	const twice = "=?utf-8?q?=3D=3Futf-8=3Fq=3FCaf=3DC3=3DA9=3F=3D?="
+	m, err := message.Parse(strings.NewReader("Subject: " + twice + "\n\nHello"))
+	require.NoError(t, err)
 
-	sterlingAddr, err := addr.ParseEmailAddrSpec(sterling)
+	s, err := m.GetHeader().GetSubject()
 	assert.NoError(t, err)
+	assert.Equal(t, "=?utf-8?q?Caf=C3=A9?=", s)
 
-	sa := addr.AddressList{sterlingAddr}
-
-	to, err := h.GetTo()
+	s, err = m.GetHeader().GetSubjectLenient()
 	assert.NoError(t, err)
-	assert.Equal(t, sa, to)
+	assert.Equal(t, "Café", s)
+}
 
-	cc, err := h.GetCc()
-	assert.NoError(t, err)
-	assert.Equal(t, sa, cc)
+func TestHeader_GetOr(t *testing.T) {
+	t.Parallel()
 
-	bcc, err := h.GetBcc()
-	assert.NoError(t, err)
-	assert.Equal(t, sa, bcc)
+	h := &header.Header{}
+	h.SetSubject("hello")
+	h.InsertBeforeField(1, "X-Empty", "")
 
-	from, err := h.GetFrom()
-	assert.NoError(t, err)
-	assert.Equal(t, sa, from)
+	assert.Equal(t, "hello", h.GetOr(header.Subject, "default"))
+	assert.Equal(t, "", h.GetOr("X-Empty", "default"))
+	assert.Equal(t, "default", h.GetOr("X-Missing", "default"))
+}
+
+func TestHeader_GetTimeOr(t *testing.T) {
+	t.Parallel()
+
+	def := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	date := time.Date(2023, 3, 14, 15, 9, 26, 0, time.UTC)
+
+	h := &header.Header{}
+	h.SetDate(date)
+	h.InsertBeforeField(1, "X-Bad-Date", "not a date")
+
+	assert.True(t, date.Equal(h.GetTimeOr(header.Date, def)))
+	assert.Equal(t, def, h.GetTimeOr("X-Bad-Date", def))
+	assert.Equal(t, def, h.GetTimeOr("X-Missing", def))
+}
+
+func TestHeader_RawUTF8(t *testing.T) {
+	t.Parallel()
+
+	const src = "Subject: Café menu\n\nHello"
+
+	m, err := message.Parse(strings.NewReader(src))
+	require.NoError(t, err)
+
+	s, err := m.GetHeader().GetSubject()
+	assert.NoError(t, err)
+	assert.Equal(t, "Café menu", s)
+
+	h := &header.Header{}
+	h.SetUTF8Allowed(true)
+	h.SetSubject(s)
+
+	buf := &bytes.Buffer{}
+	_, err = h.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "Subject: Café menu\n\n", buf.String())
+}
+
+func TestHeader_Freeze(t *testing.T) {
+	t.Parallel()
+
+	h := &header.Header{}
+	h.SetSubject("frozen")
+	h.SetMediaType("text/plain")
+	h.InsertBeforeField(h.Len(), header.To, "one@example.com, two@example.com")
+	h.InsertBeforeField(h.Len(), header.Comments, "a comment")
+
+	fh := h.Freeze()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			mt, err := fh.GetMediaType()
+			assert.NoError(t, err)
+			assert.Equal(t, "text/plain", mt)
+
+			to, err := fh.GetTo()
+			assert.NoError(t, err)
+			assert.Len(t, to, 2)
+
+			cs, err := fh.GetComments()
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"a comment"}, cs)
+		}()
+	}
+	wg.Wait()
+
+	// a clone of a frozen header may be modified
+	ch := fh.Clone()
+	ch.SetSubject("thawed")
+	subj, err := ch.GetSubject()
+	assert.NoError(t, err)
+	assert.Equal(t, "thawed", subj)
+
+	subj, err = fh.GetSubject()
+	assert.NoError(t, err)
+	assert.Equal(t, "frozen", subj)
+}
+
+func TestHeader_GetFloatAndInt(t *testing.T) {
+	t.Parallel()
+
+	h := &header.Header{}
+	h.InsertBeforeField(0, "X-Spam-Score", " 5.2 ")
+	h.InsertBeforeField(1, "X-Spam-Score", "9.9")
+	h.InsertBeforeField(2, "X-Priority", "3")
+	h.InsertBeforeField(3, "X-Spam-Status", "Yes, score=5.2")
+
+	f, err := h.GetFloat("X-Spam-Score")
+	assert.NoError(t, err)
+	assert.Equal(t, 5.2, f)
+
+	i, err := h.GetInt("X-Priority")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, i)
+
+	_, err = h.GetInt("X-Spam-Score")
+	assert.Error(t, err)
+
+	_, err = h.GetFloat("X-Spam-Status")
+	assert.Error(t, err)
+
+	_, err = h.GetFloat("X-Missing")
+	assert.ErrorIs(t, err, header.ErrNoSuchField)
+
+	_, err = h.GetInt("X-Missing")
+	assert.ErrorIs(t, err, header.ErrNoSuchField)
+}
+
+func TestHeader_SetSubject(t *testing.T) {
+	t.Parallel()
+
+	h := &header.Header{}
+	h.SetSubject("woo boo too")
+
+	b, err := h.Get(header.Subject)
+	assert.NoError(t, err)
+	assert.Equal(t, "woo boo too", b)
+}
+
+func TestHeader_ContentDescription(t *testing.T) {
+	t.Parallel()
+
+	h := &header.Header{}
+	_, err := h.GetContentDescription()
+	assert.ErrorIs(t, err, header.ErrNoSuchField)
+
+	h.SetContentDescription("Résumé ☺")
+
+	d, err := h.GetContentDescription()
+	assert.NoError(t, err)
+	assert.Equal(t, "Résumé ☺", d)
+
+	buf := &bytes.Buffer{}
+	_, _ = h.WriteTo(buf)
+	assert.Equal(t, "Content-description: =?utf-8?b?UsOpc3Vtw6kg4pi6?=\n\n", buf.String())
+
+	m, err := message.Parse(buf)
+	require.NoError(t, err)
+
+	d, err = m.GetHeader().GetContentDescription()
+	assert.NoError(t, err)
+	assert.Equal(t, "Résumé ☺", d)
+}
+
+func TestHeader_Get_BccCcToFromSenderReplyTo(t *testing.T) {
+	t.Parallel()
+
+	const sterling = `sterling@example.com`
+
+	h := &header.Header{}
+	h.InsertBeforeField(0, "to", sterling)
+	h.InsertBeforeField(1, "cc", sterling)
+	h.InsertBeforeField(2, "bcc", sterling)
+	h.InsertBeforeField(3, "from", sterling)
+	h.InsertBeforeField(4, "sender", sterling)
+	h.InsertBeforeField(5, "reply-to", sterling)
+
+	sterlingAddr, err := addr.ParseEmailAddrSpec(sterling)
+	assert.NoError(t, err)
+
+	sa := addr.AddressList{sterlingAddr}
+
+	to, err := h.GetTo()
+	assert.NoError(t, err)
+	assert.Equal(t, sa, to)
+
+	cc, err := h.GetCc()
+	assert.NoError(t, err)
+	assert.Equal(t, sa, cc)
+
+	bcc, err := h.GetBcc()
+	assert.NoError(t, err)
+	assert.Equal(t, sa, bcc)
+
+	from, err := h.GetFrom()
+	assert.NoError(t, err)
+	assert.Equal(t, sa, from)
 
 	sender, err := h.GetSender()
 	assert.NoError(t, err)
@@ -1205,6 +1833,43 @@ Reply-to: sterling@example.com
 	}
 }
 
+func TestHeader_Add_ToCcBcc(t *testing.T) {
+	t.Parallel()
+
+	h := &header.Header{}
+	err := h.SetTo("sterling@example.com")
+	require.NoError(t, err)
+
+	bob, err := addr.NewMailboxStr("Bob", "bob@example.com", "")
+	require.NoError(t, err)
+
+	err = h.AddTo("steve@example.com", bob)
+	assert.NoError(t, err)
+
+	err = h.AddCc("carol@example.com")
+	assert.NoError(t, err)
+
+	err = h.AddBcc("archive@example.com")
+	assert.NoError(t, err)
+
+	err = h.AddBcc(42)
+	assert.ErrorIs(t, err, header.ErrWrongAddressType)
+
+	const headerStr = `To: sterling@example.com, steve@example.com, Bob <bob@example.com>
+Cc: carol@example.com
+Bcc: archive@example.com
+
+`
+
+	s := &bytes.Buffer{}
+	_, _ = h.WriteTo(s)
+	assert.Equal(t, headerStr, s.String())
+
+	h.InsertBeforeField(h.Len(), header.Cc, "dave@example.com")
+	err = h.AddCc("erin@example.com")
+	assert.ErrorIs(t, err, header.ErrManyFields)
+}
+
 func TestHeader_GetKeywords(t *testing.T) {
 	t.Parallel()
 
@@ -1278,6 +1943,194 @@ Comments: three
 	assert.Equal(t, expect, buf.String())
 }
 
+func TestHeader_KeywordsCommentsEncodedWords(t *testing.T) {
+	t.Parallel()
+
+	const src = "Keywords: =?utf-8?Q?caf=C3=A9?=, bar\n" +
+		"Keywords: =?utf-8?Q?one,two?=\n" +
+		"Comments: =?utf-8?Q?r=C3=A9sum=C3=A9?= attached\n"
+
+	h, err := header.Parse([]byte(src), header.LF)
+	require.NoError(t, err)
+
+	ks, err := h.GetKeywords()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"café", "bar", "one,two"}, ks)
+
+	cs, err := h.GetComments()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"résumé attached"}, cs)
+
+	// fields inserted with encoded bodies are decoded too
+	h.InsertBeforeField(h.Len(), header.Comments, "=?utf-8?B?5pel5pys?=")
+	cs, err = h.GetComments()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"résumé attached", "日本"}, cs)
+
+	h.SetKeywords("café", "bar")
+	h.SetComments(cs...)
+	h.InsertKeywordsList(h.Len(), header.Keywords, "thé")
+
+	const expect = "Keywords: =?utf-8?q?caf=C3=A9?=, bar\n" +
+		"Comments: =?utf-8?q?r=C3=A9sum=C3=A9_attached?=\n" +
+		"Comments: =?utf-8?b?5pel5pys?=\n" +
+		"Keywords: =?utf-8?q?th=C3=A9?=\n" +
+		"\n"
+
+	buf := &bytes.Buffer{}
+	_, err = h.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, expect, buf.String())
+
+	rh, err := header.Parse(bytes.TrimSuffix(buf.Bytes(), []byte("\n\n")), header.LF)
+	require.NoError(t, err)
+
+	ks, err = rh.GetKeywords()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"café", "bar", "thé"}, ks)
+}
+
+func TestHeader_ContentID(t *testing.T) {
+	t.Parallel()
+
+	h := &header.Header{}
+
+	_, err := h.GetContentID()
+	assert.ErrorIs(t, err, header.ErrNoSuchField)
+
+	h.SetContentID("part1@example.com")
+
+	id, err := h.GetContentID()
+	assert.NoError(t, err)
+	assert.Equal(t, "<part1@example.com>", id)
+}
+
+func TestHeader_MailerAgent(t *testing.T) {
+	t.Parallel()
+
+	h, err := header.Parse([]byte("User-Agent: =?utf-8?q?M=C3=BCtt?= 1.0\n"), header.LF)
+	require.NoError(t, err)
+
+	m, err := h.GetMailerAgent()
+	assert.NoError(t, err)
+	assert.Equal(t, "Mütt 1.0", m)
+
+	h.SetMailer("go-email")
+	m, err = h.GetMailerAgent()
+	assert.NoError(t, err)
+	assert.Equal(t, "go-email", m)
+
+	_, err = (&header.Header{}).GetMailerAgent()
+	assert.ErrorIs(t, err, header.ErrNoSuchField)
+}
+
+func TestHeader_Precedence(t *testing.T) {
+	t.Parallel()
+
+	h := &header.Header{}
+	_, err := h.GetPrecedence()
+	assert.ErrorIs(t, err, header.ErrNoSuchField)
+	assert.False(t, h.IsBulk())
+
+	h.SetPrecedence(" Bulk ")
+	p, err := h.GetPrecedence()
+	assert.NoError(t, err)
+	assert.Equal(t, "bulk", p)
+	assert.True(t, h.IsBulk())
+
+	h.SetPrecedence("first-class")
+	assert.False(t, h.IsBulk())
+}
+
+func TestHeader_IsBulk(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		src  string
+		bulk bool
+	}{
+		{"personal", "From: a@example.com\nTo: b@example.com\n", false},
+		{"precedence list", "Precedence: list\n", true},
+		{"precedence junk", "PRECEDENCE: JUNK\n", true},
+		{"list-id", "List-Id: <news.example.com>\n", true},
+		{"list-unsubscribe", "List-Unsubscribe: <mailto:leave@example.com>\n", true},
+		{"auto-submitted", "Auto-Submitted: auto-replied\n", true},
+		{"auto-submitted no", "Auto-Submitted: no\n", false},
+		{"auto-submitted no comment", "Auto-Submitted: No (human)\n", false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			h, err := header.Parse([]byte(test.src), header.LF)
+			require.NoError(t, err)
+			assert.Equal(t, test.bulk, h.IsBulk())
+		})
+	}
+}
+
+func TestHeader_EncodedWordsWithSpaces(t *testing.T) {
+	t.Parallel()
+
+	h, err := header.Parse([]byte(
+		"Subject: =?utf-8?B?aGVsbG8g d29ybGQ=?=\n"+
+			"From: =?utf-8?Q?Jos=C3=A9 Garc=C3=ADa?= <jose@example.com>\n"+
+			"Comments: =?iso-8859-1?Q?caf=E9 au lait?=\n"), header.LF)
+	require.NoError(t, err)
+
+	s, err := h.GetSubject()
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", s)
+
+	from, err := h.GetFrom()
+	assert.NoError(t, err)
+	require.Len(t, from, 1)
+	assert.Equal(t, "José García", from[0].DisplayName())
+	assert.Equal(t, "jose@example.com", from[0].Address())
+
+	cs, err := h.GetComments()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"café au lait"}, cs)
+}
+
+func TestHeader_DeduplicateRecipients(t *testing.T) {
+	t.Parallel()
+
+	h, err := header.Parse([]byte(
+		"From: sender@example.com\n"+
+			"To: Alice <alice@example.com>, bob@example.com, Alice Again <alice@EXAMPLE.com>\n"+
+			"Cc: Bob <bob@Example.Com>, carol@example.com, team: alice@example.com, dave@example.com;\n"+
+			"Bcc: carol@example.com, dave@example.com\n"), header.LF)
+	require.NoError(t, err)
+
+	n, err := h.DeduplicateRecipients()
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+
+	to, err := h.Get(header.To)
+	assert.NoError(t, err)
+	assert.Equal(t, "Alice <alice@example.com>, bob@example.com", to)
+
+	cc, err := h.Get(header.Cc)
+	assert.NoError(t, err)
+	assert.Equal(t, "carol@example.com, team: dave@example.com;", cc)
+
+	_, err = h.Get(header.Bcc)
+	assert.ErrorIs(t, err, header.ErrNoSuchField)
+
+	n, err = h.DeduplicateRecipients()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	h, err = header.Parse([]byte("To: a@example.com\nTo: a@example.com\n"), header.LF)
+	require.NoError(t, err)
+	_, err = h.DeduplicateRecipients()
+	assert.ErrorIs(t, err, header.ErrManyFields)
+}
+
 func TestHeader_Get_ReferencesInReplyToMessageID(t *testing.T) {
 	t.Parallel()
 
@@ -1331,6 +2184,104 @@ In-reply-to: baz
 	assert.Equal(t, expect, buf.String())
 }
 
+func TestHeader_SetReferencesList(t *testing.T) {
+	t.Parallel()
+
+	h := &header.Header{}
+
+	h.SetReferencesList("a@example.com", "<b@example.com>")
+	refs, err := h.GetReferences()
+	assert.NoError(t, err)
+	assert.Equal(t, "<a@example.com> <b@example.com>", refs)
+
+	h.AddReference("c@example.com")
+	refs, err = h.GetReferences()
+	assert.NoError(t, err)
+	assert.Equal(t, "<a@example.com> <b@example.com> <c@example.com>", refs)
+
+	h2 := &header.Header{}
+	h2.AddReference("<d@example.com>")
+	refs, err = h2.GetReferences()
+	assert.NoError(t, err)
+	assert.Equal(t, "<d@example.com>", refs)
+}
+
+func TestTrimReferences(t *testing.T) {
+	t.Parallel()
+
+	ids := []string{"<a@x>", "<b@x>", "<c@x>", "<d@x>", "<e@x>"}
+
+	assert.Equal(t, ids, header.TrimReferences(ids, 100))
+	assert.Equal(t,
+		[]string{"<a@x>", "<d@x>", "<e@x>"},
+		header.TrimReferences(ids, 17))
+	assert.Equal(t,
+		[]string{"<a@x>", "<e@x>"},
+		header.TrimReferences(ids, 1))
+	assert.Equal(t,
+		[]string{"<a@x>", "<b@x>", "<c@x>", "<d@x>", "<e@x>"},
+		ids)
+}
+
+func TestHeader_AddReference_Trimmed(t *testing.T) {
+	t.Parallel()
+
+	h := &header.Header{}
+	for i := 0; i < 100; i++ {
+		h.AddReference(fmt.Sprintf("message-%03d@example.com", i))
+	}
+
+	refs, err := h.GetReferences()
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, len(refs), header.MaxReferencesLength)
+	assert.True(t, strings.HasPrefix(refs, "<message-000@example.com> "))
+	assert.True(t, strings.HasSuffix(refs, " <message-099@example.com>"))
+}
+
+func TestHeader_RewriteReceived(t *testing.T) {
+	t.Parallel()
+
+	const src = "Received: from internal.example.com ([10.0.0.5])\n" +
+		"  by mx.example.com; Tue, 14 Mar 2023 15:09:26 +0000\n" +
+		"Subject: hello\n" +
+		"Received: from outside.example.net ([203.0.113.9])\n" +
+		"  by internal.example.com; Tue, 14 Mar 2023 15:09:20 +0000\n" +
+		"\n" +
+		"Hello."
+
+	m, err := message.Parse(strings.NewReader(src))
+	require.NoError(t, err)
+
+	internalIP := regexp.MustCompile(`\[10\.[0-9.]+\]`)
+	m.GetHeader().RewriteReceived(func(raw string) string {
+		return internalIP.ReplaceAllString(raw, "[redacted]")
+	})
+
+	const expect = "Received: from internal.example.com ([redacted])  by mx.example.com; Tue, 14 Mar 2023 15:09:26 +0000\n" +
+		"Subject: hello\n" +
+		"Received: from outside.example.net ([203.0.113.9])\n" +
+		"  by internal.example.com; Tue, 14 Mar 2023 15:09:20 +0000\n" +
+		"\n" +
+		"Hello."
+
+	buf := &bytes.Buffer{}
+	_, err = m.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, expect, buf.String())
+
+	m.GetHeader().SetFoldEncoding(field.DefaultFoldEncoding)
+	m.GetHeader().RewriteReceived(func(raw string) string {
+		return raw + " (archived by a rather long-winded archival system)"
+	})
+
+	buf.Reset()
+	_, err = m.GetHeader().WriteTo(buf)
+	assert.NoError(t, err)
+	for _, line := range strings.Split(buf.String(), "\n") {
+		assert.LessOrEqual(t, len(line), 80)
+	}
+}
+
 func TestHeader_GetTransferEncoding(t *testing.T) {
 	t.Parallel()
 
@@ -1359,3 +2310,288 @@ func TestHeader_SetTransferEncoding(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, expect, buf.String())
 }
+
+func TestHeader_SanitizeControlChars(t *testing.T) {
+	t.Parallel()
+
+	const src = "Subject: a\fb\x01c\td\x1b\nX-Other: ok\n"
+	h, err := header.Parse([]byte(src), header.LF)
+	require.NoError(t, err)
+
+	// control characters are preserved when parsed
+	subject, err := h.GetSubject()
+	assert.NoError(t, err)
+	assert.Equal(t, "a\fb\x01c\td\x1b", subject)
+
+	buf := &bytes.Buffer{}
+	_, err = h.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, src+"\n", buf.String())
+
+	assert.Equal(t, 1, h.SanitizeControlChars())
+
+	subject, err = h.GetSubject()
+	assert.NoError(t, err)
+	assert.Equal(t, "a b c\td ", subject)
+
+	other, err := h.Get("X-Other")
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", other)
+
+	assert.Equal(t, 0, h.SanitizeControlChars())
+}
+
+func TestParseAddressList_Group(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		body        string
+		groupName   string
+		groupMember []string
+		rest        []string
+		clean       string
+	}{
+		{
+			body:        "A Group: a@x, b@y;, c@z",
+			groupName:   "A Group",
+			groupMember: []string{"a@x", "b@y"},
+			rest:        []string{"c@z"},
+			clean:       "A Group: a@x, b@y;, c@z",
+		},
+		{
+			body:        "My  Group: A <a@example.com>;, C <c@example.com>",
+			groupName:   "My Group",
+			groupMember: []string{"a@example.com"},
+			rest:        []string{"c@example.com"},
+			clean:       "My Group: A <a@example.com>;, C <c@example.com>",
+		},
+		{
+			body:        `c@z.com, "Team, Inc.": a@x.com,b@y.com ;`,
+			groupName:   "Team, Inc.",
+			groupMember: []string{"a@x.com", "b@y.com"},
+			rest:        []string{"c@z.com"},
+		},
+		{
+			// not strictly valid, so this is parsed leniently
+			body:        "A Group: a@x, bad address here;, c@z",
+			groupName:   "A Group",
+			groupMember: []string{"a@x", "here"},
+			rest:        []string{"c@z"},
+		},
+	}
+
+	for _, test := range tests {
+		al := header.ParseAddressList(test.body)
+		require.Len(t, al, 2, test.body)
+
+		var group *addr.Group
+		var rest []string
+		for _, a := range al {
+			if g, isGroup := a.(*addr.Group); isGroup {
+				group = g
+				continue
+			}
+			rest = append(rest, a.Address())
+		}
+
+		require.NotNil(t, group, test.body)
+		assert.Equal(t, test.groupName, group.DisplayName(), test.body)
+		assert.Equal(t, test.rest, rest, test.body)
+
+		members := make([]string, len(group.MailboxList()))
+		for i, mb := range group.MailboxList() {
+			members[i] = mb.Address()
+		}
+		assert.Equal(t, test.groupMember, members, test.body)
+
+		if test.clean != "" {
+			assert.Equal(t, test.clean, al.String(), test.body)
+		}
+	}
+}
+
+func TestHeader_GroupRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	const src = "To: A Group: a@x, b@y;, c@z\nCc: Undisclosed recipients:;, d@w\n"
+	h, err := header.Parse([]byte(src), header.LF)
+	require.NoError(t, err)
+
+	to, err := h.GetAddressListStrict(header.To)
+	require.NoError(t, err)
+	require.Len(t, to, 2)
+
+	cc, err := h.GetCc()
+	require.NoError(t, err)
+	require.Len(t, cc, 2)
+
+	out := &header.Header{}
+	out.SetAddressList(header.To, to...)
+	out.SetAddressList(header.Cc, cc...)
+
+	buf := &bytes.Buffer{}
+	_, err = out.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "To: A Group: a@x, b@y;, c@z\nCc: Undisclosed recipients: ;, d@w\n\n", buf.String())
+}
+
+func TestHeader_EncodedDisplayName(t *testing.T) {
+	t.Parallel()
+
+	const src = "To: =?utf-8?Q?Jos=C3=A9?= <jose@x.com>, =?iso-8859-1?q?Ren=E9e?= <renee@y.com>\n" +
+		"Cc: =?utf-8?Q?Caf=C3=A9?=: =?utf-8?Q?Zo=C3=AB?= <zoe@z.com>;\n"
+	h, err := header.Parse([]byte(src), header.LF)
+	require.NoError(t, err)
+
+	to, err := h.GetTo()
+	require.NoError(t, err)
+	require.Len(t, to, 2)
+
+	mb, isMailbox := to[0].(*addr.Mailbox)
+	require.True(t, isMailbox)
+	assert.Equal(t, "José", mb.DisplayName())
+	assert.Equal(t, "jose@x.com", mb.Address())
+
+	mb, isMailbox = to[1].(*addr.Mailbox)
+	require.True(t, isMailbox)
+	assert.Equal(t, "Renée", mb.DisplayName())
+	assert.Equal(t, "renee@y.com", mb.Address())
+
+	cc, err := h.GetAddressListStrict(header.Cc)
+	require.NoError(t, err)
+	require.Len(t, cc, 1)
+
+	g, isGroup := cc[0].(*addr.Group)
+	require.True(t, isGroup)
+	assert.Equal(t, "Café", g.DisplayName())
+	require.Len(t, g.MailboxList(), 1)
+	assert.Equal(t, "Zoë", g.MailboxList()[0].DisplayName())
+
+	out := &header.Header{}
+	out.SetAddressList(header.To, to...)
+	out.SetAddressList(header.Cc, cc...)
+
+	buf := &bytes.Buffer{}
+	_, err = out.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"To: =?utf-8?q?Jos=C3=A9?= <jose@x.com>, =?utf-8?q?Ren=C3=A9e?= <renee@y.com>\n"+
+			"Cc: =?utf-8?q?Caf=C3=A9?=: =?utf-8?q?Zo=C3=AB?= <zoe@z.com>;\n\n",
+		buf.String())
+
+	rt, err := header.Parse(bytes.TrimSuffix(buf.Bytes(), []byte("\n")), header.LF)
+	require.NoError(t, err)
+
+	to, err = rt.GetTo()
+	require.NoError(t, err)
+	require.Len(t, to, 2)
+	assert.Equal(t, "José", to[0].DisplayName())
+}
+
+func TestHeader_AllRecipients(t *testing.T) {
+	t.Parallel()
+
+	const src = "To: Alice <alice@example.com>, bob@example.com\n" +
+		"Cc: Team: alice@EXAMPLE.com, carol@example.com;, Bob@example.com\n" +
+		"Bcc: bob@Example.COM, dave@example.net\n"
+
+	h, err := header.Parse([]byte(src), header.LF)
+	require.NoError(t, err)
+
+	rcpts, err := h.AllRecipients()
+	require.NoError(t, err)
+
+	addrs := make([]string, len(rcpts))
+	for i, a := range rcpts {
+		addrs[i] = a.Address()
+	}
+	assert.Equal(t, []string{
+		"alice@example.com",
+		"bob@example.com",
+		"carol@example.com",
+		"Bob@example.com",
+		"dave@example.net",
+	}, addrs)
+
+	n, err := h.RecipientCount()
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+
+	h.Unset(header.To)
+	h.Unset(header.Cc)
+	n, err = h.RecipientCount()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	h.InsertFields(h.Len(), field.New(header.Bcc, "eve@example.com"))
+	_, err = h.RecipientCount()
+	assert.ErrorIs(t, err, header.ErrManyFields)
+}
+
+func TestParseAddressList_LenientAngleAddr(t *testing.T) {
+	t.Parallel()
+
+	al := header.ParseAddressList("José <jose@münchen.example>")
+	require.Len(t, al, 1)
+	assert.Equal(t, "José", al[0].DisplayName())
+
+	mb, isMailbox := al[0].(*addr.Mailbox)
+	require.True(t, isMailbox)
+	assert.Equal(t, "jose", mb.LocalPart())
+	assert.Equal(t, "münchen.example", mb.Domain())
+}
+
+func TestValidFieldName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"Subject", true},
+		{"X-Custom_Field.1~", true},
+		{"", false},
+		{"Bad Name", false},
+		{"Bad:Name", false},
+		{"Bad\tName", false},
+		{"Bad\nName", false},
+		{"Ünicode", false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.valid, header.ValidFieldName(test.name))
+		})
+	}
+}
+
+func TestHeader_SetChecked(t *testing.T) {
+	t.Parallel()
+
+	h := &header.Header{}
+	assert.NoError(t, h.SetChecked("X-Good", "yes"))
+	assert.Equal(t, "yes", h.GetOr("X-Good", ""))
+
+	err := h.SetChecked("X Bad", "no")
+	assert.ErrorIs(t, err, header.ErrIllegalFieldName)
+	assert.Equal(t, 1, h.Len())
+
+	assert.Panics(t, func() { h.Set("X: Bad", "no") })
+	assert.Panics(t, func() { h.SetAll("", "no") })
+	assert.Panics(t, func() { h.InsertBeforeField(0, "X\nBad", "no") })
+	assert.Panics(t, func() { h.PrependRawField("X Bad", []byte(" no")) })
+	assert.Equal(t, 1, h.Len())
+
+	// a field parsed with an invalid name may still be copied between headers,
+	// even once its Raw value has been dropped
+	ph, err := header.Parse([]byte("X Bad: a\x01b\n"), header.LF)
+	require.NoError(t, err)
+	assert.Equal(t, 1, ph.SanitizeControlChars())
+	assert.Nil(t, ph.GetField(0).Raw)
+
+	var mh *header.Header
+	assert.NotPanics(t, func() { mh = header.Merge(h, ph, nil) })
+	assert.Equal(t, []int{1}, mh.GetIndexesNamed("X Bad"))
+}
@@ -0,0 +1,103 @@
+package header
+
+import (
+	"strings"
+
+	"github.com/zostay/go-email/v2/message/header/field"
+)
+
+// MergeAction tells Merge what to do with the fields of a given name found in
+// the overlay header.
+type MergeAction int
+
+const (
+	// MergeOverlay replaces the fields in the base header with the fields from
+	// the overlay header. The overlay fields take the place of the first base
+	// field or are added to the end if the base has no such field.
+	MergeOverlay MergeAction = iota
+
+	// MergeAppend keeps the fields in the base header and adds the fields from
+	// the overlay header after the last of them, or to the end if the base has
+	// no such field.
+	MergeAppend
+
+	// MergeKeepBase keeps the fields in the base header and ignores the fields
+	// from the overlay header. If the base header has no such field, the
+	// overlay fields are added to the end.
+	MergeKeepBase
+)
+
+// MergePolicy chooses the MergeAction for each field name found in the overlay
+// header given to Merge.
+type MergePolicy func(name string) MergeAction
+
+// DefaultMergePolicy is the MergePolicy used by Merge when none is given. The
+// fields that may be repeated in a header (Received, Comments, Keywords, and
+// the Resent-* fields) are appended with MergeAppend. All other fields, such as
+// From, Subject, and Date, are replaced with MergeOverlay.
+func DefaultMergePolicy(name string) MergeAction {
+	n := strings.ToLower(name)
+	switch {
+	case n == strings.ToLower(Received),
+		n == strings.ToLower(Comments),
+		n == strings.ToLower(Keywords),
+		strings.HasPrefix(n, "resent-"):
+		return MergeAppend
+	}
+
+	return MergeOverlay
+}
+
+// Merge returns a new header combining the fields of base and overlay. The new
+// header starts as a clone of base. Then, for each field name found in overlay,
+// the policy chooses whether the overlay fields replace, are appended to, or
+// are ignored in favor of the base fields. If policy is nil,
+// DefaultMergePolicy is used.
+//
+// Neither base nor overlay is modified. The fields copied from overlay are
+// cloned, so the new header may be modified freely.
+func Merge(base, overlay *Header, policy MergePolicy) *Header {
+	if policy == nil {
+		policy = DefaultMergePolicy
+	}
+
+	h := base.Clone()
+	done := make(map[string]bool, overlay.Len())
+	for _, of := range overlay.ListFields() {
+		name := of.Name()
+		if done[strings.ToLower(name)] {
+			continue
+		}
+		done[strings.ToLower(name)] = true
+
+		ofs := overlay.GetAllFieldsNamed(name)
+		fs := make([]*field.Field, len(ofs))
+		for i, f := range ofs {
+			fs[i] = f.Clone()
+		}
+
+		ixs := h.GetIndexesNamed(name)
+		at := h.Len()
+		switch policy(name) {
+		case MergeKeepBase:
+			if len(ixs) > 0 {
+				continue
+			}
+		case MergeAppend:
+			if len(ixs) > 0 {
+				at = ixs[len(ixs)-1] + 1
+			}
+		default:
+			if len(ixs) > 0 {
+				at = ixs[0]
+				for i := len(ixs) - 1; i >= 0; i-- {
+					_ = h.DeleteField(ixs[i])
+				}
+			}
+		}
+
+		h.InsertFields(at, fs...)
+	}
+
+	return h
+}
@@ -0,0 +1,90 @@
+package header_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/go-email/v2/message/header"
+)
+
+const (
+	mergeBase = "From: template@example.com\n" +
+		"Received: from a\n" +
+		"Subject: Template\n" +
+		"Keywords: base\n" +
+		"X-Mailer: template\n"
+
+	mergeOverlay = "Subject: Actual\n" +
+		"Received: from b\n" +
+		"To: you@example.com\n" +
+		"X-Mailer: overlay\n" +
+		"Keywords: overlay\n"
+)
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+
+	base, err := header.Parse([]byte(mergeBase), header.LF)
+	require.NoError(t, err)
+
+	overlay, err := header.Parse([]byte(mergeOverlay), header.LF)
+	require.NoError(t, err)
+
+	h := header.Merge(base, overlay, nil)
+
+	buf := &bytes.Buffer{}
+	_, err = h.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "From: template@example.com\n"+
+		"Received: from a\n"+
+		"Received: from b\n"+
+		"Subject: Actual\n"+
+		"Keywords: base\n"+
+		"Keywords: overlay\n"+
+		"X-Mailer: overlay\n"+
+		"To: you@example.com\n\n", buf.String())
+
+	s, err := h.GetSubject()
+	assert.NoError(t, err)
+	assert.Equal(t, "Actual", s)
+
+	// the inputs are left alone
+	s, err = base.GetSubject()
+	assert.NoError(t, err)
+	assert.Equal(t, "Template", s)
+	assert.Equal(t, 5, overlay.Len())
+}
+
+func TestMerge_Policy(t *testing.T) {
+	t.Parallel()
+
+	base, err := header.Parse([]byte(mergeBase), header.LF)
+	require.NoError(t, err)
+
+	overlay, err := header.Parse([]byte(mergeOverlay), header.LF)
+	require.NoError(t, err)
+
+	h := header.Merge(base, overlay, func(name string) header.MergeAction {
+		switch name {
+		case "X-Mailer", header.To:
+			return header.MergeKeepBase
+		case header.Subject:
+			return header.MergeAppend
+		}
+		return header.MergeOverlay
+	})
+
+	buf := &bytes.Buffer{}
+	_, err = h.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "From: template@example.com\n"+
+		"Received: from b\n"+
+		"Subject: Template\n"+
+		"Subject: Actual\n"+
+		"Keywords: overlay\n"+
+		"X-Mailer: template\n"+
+		"To: you@example.com\n\n", buf.String())
+}
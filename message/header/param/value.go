@@ -19,6 +19,14 @@ const (
 	// Filename is the name of the filename parameter that may be present in the
 	// Content-disposition header.
 	Filename = "filename"
+
+	// Protocol is the name of the protocol parameter that may be present in the
+	// Content-type header of a multipart/signed or multipart/encrypted part.
+	Protocol = "protocol"
+
+	// SMIMEType is the name of the smime-type parameter that may be present in
+	// the Content-type header of an application/pkcs7-mime part.
+	SMIMEType = "smime-type"
 )
 
 // Value represents a parsed parameterized header field, such as is used in the
@@ -26,29 +34,119 @@ const (
 // You cannot change it in place. However, a Modify() function is provided to
 // perform transformation of a Value into a new Value.
 type Value struct {
-	v  string
-	ps map[string]string
+	v     string
+	ps    map[string]string
+	order []string
+
+	// rawV and raw hold the text of the primary value and of each parameter
+	// as parsed, so that anything left unchanged is written back as-is
+	rawV string
+	raw  map[string]string
 }
 
 // Parse takes a header field body, parses it as a Value and returns it. If an
-// error occurs in the process, it returns an error.
+// error occurs in the process, it returns an error. The order of the
+// parameters is preserved when the Value is serialized again, as is the
+// original text of the primary value and of each parameter that has not been
+// changed since (including the case of the name, any quoting, and any RFC 2231
+// encoding).
 func Parse(v string) (*Value, error) {
 	mt, ps, err := mime.ParseMediaType(v)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Value{mt, ps}, nil
+	rawV, order, raw := parameterSegments(v, ps)
+	return &Value{mt, ps, order, rawV, raw}, nil
+}
+
+// parameterSegments splits the unparsed field body into the primary value and
+// the text of each parameter in ps. It returns the primary value, the order in
+// which the parameters appear, and the text of each. The segments of a
+// parameter split using RFC 2231 continuations (e.g., title*0*=...) are joined
+// together. Any parameter that cannot be found is put at the end in sorted
+// order with no text.
+func parameterSegments(v string, ps map[string]string) (string, []string, map[string]string) {
+	var (
+		order = make([]string, 0, len(ps))
+		raw   = make(map[string]string, len(ps))
+		rawV  string
+	)
+
+	addSegment := func(seg string, first bool) {
+		seg = strings.TrimSpace(seg)
+		if first {
+			rawV = seg
+			return
+		}
+
+		ix := strings.IndexRune(seg, '=')
+		if ix < 0 {
+			return
+		}
+
+		k := strings.ToLower(strings.TrimSpace(seg[:ix]))
+		// RFC 2231 continuations and encodings (e.g., title*0*=...)
+		if ix := strings.IndexRune(k, '*'); ix >= 0 {
+			k = k[:ix]
+		}
+
+		if _, exists := ps[k]; !exists {
+			return
+		}
+
+		if r, seen := raw[k]; seen {
+			raw[k] = r + "; " + seg
+		} else {
+			order = append(order, k)
+			raw[k] = seg
+		}
+	}
+
+	inQuote, escaped := false, false
+	start, first := 0, true
+	for i, c := range v {
+		switch {
+		case escaped:
+			escaped = false
+		case inQuote && c == '\\':
+			escaped = true
+		case c == '"':
+			inQuote = !inQuote
+		case !inQuote && c == ';':
+			addSegment(v[start:i], first)
+			start, first = i+1, false
+		}
+	}
+	addSegment(v[start:], first)
+
+	rest := make([]string, 0, len(ps)-len(order))
+	for k := range ps {
+		if _, seen := raw[k]; !seen {
+			rest = append(rest, k)
+		}
+	}
+	sort.Strings(rest)
+
+	return rawV, append(order, rest...), raw
 }
 
 // New creates a new parameterized header field with or without parameters.
+// The parameters will be serialized in sorted order.
 func New(v string, ps ...map[string]string) *Value {
-	pv := &Value{v, map[string]string{}}
+	pv := &Value{v: v, ps: map[string]string{}}
 	for _, p := range ps {
 		for k, v := range p {
 			pv.ps[k] = v
 		}
 	}
+
+	pv.order = make([]string, 0, len(pv.ps))
+	for k := range pv.ps {
+		pv.order = append(pv.order, k)
+	}
+	sort.Strings(pv.order)
+
 	return pv
 }
 
@@ -63,10 +161,20 @@ func Change(value string) Modifier {
 	}
 }
 
-// Set is a Modifier that sets a parameter with the given name on the Value.
+// Set is a Modifier that sets a parameter with the given name on the Value. If
+// the parameter is already set, it keeps its position. Otherwise, it is added
+// after all the other parameters. If the parameter is already set to the same
+// value, it is left exactly as it was.
 func Set(name, value string) Modifier {
 	return func(pv *Value) {
+		old, exists := pv.ps[name]
+		if !exists {
+			pv.order = append(pv.order, name)
+		} else if old == value {
+			return
+		}
 		pv.ps[name] = value
+		delete(pv.raw, name)
 	}
 }
 
@@ -75,6 +183,13 @@ func Set(name, value string) Modifier {
 func Delete(name string) Modifier {
 	return func(pv *Value) {
 		delete(pv.ps, name)
+		delete(pv.raw, name)
+		for i, k := range pv.order {
+			if k == name {
+				pv.order = append(pv.order[:i], pv.order[i+1:]...)
+				break
+			}
+		}
 	}
 }
 
@@ -111,7 +226,8 @@ func (pv *Value) MediaType() string {
 
 // Type is only intended for use with the Content-type header. It searches the
 // MediaType() for a slash. If found, it will return the string before that
-// slash. If no slash is found, it returns an empty string.
+// slash. If no slash is found, the media type is malformed (e.g., "text"), and
+// the whole MediaType() is returned.
 //
 // For example, if MediaType() returns "image/jpeg", this method will return
 // "image".
@@ -119,7 +235,7 @@ func (pv *Value) Type() string {
 	if ix := strings.IndexRune(pv.v, '/'); ix >= 0 {
 		return pv.v[:ix]
 	}
-	return ""
+	return pv.v
 }
 
 // Subtype is only intended for use with the Content-type header. It searches
@@ -166,24 +282,39 @@ func (pv *Value) Boundary() string {
 }
 
 // String returns the serialized value of the Value including the primary value
-// and all parameters.
+// and all parameters. The parameters are written in the order they were parsed
+// (or in sorted order for a Value made with New), with any parameters added
+// since at the end. The primary value and each parameter that was parsed and
+// has not been changed since are written exactly as they were parsed. Other
+// parameter values are quoted or RFC 2231 encoded, as needed.
 func (pv *Value) String() string {
-	pks := make([]string, 0, len(pv.ps))
-	for k := range pv.ps {
-		pks = append(pks, k)
-	}
-	sort.Strings(pks)
-
-	parts := make([]string, len(pv.ps)+1)
+	parts := make([]string, len(pv.order)+1)
 	parts[0] = pv.v
+	if strings.EqualFold(pv.rawV, pv.v) {
+		parts[0] = pv.rawV
+	}
 
-	for n, k := range pks {
-		parts[n+1] = fmt.Sprintf("%s=%s", k, pv.ps[k])
+	for n, k := range pv.order {
+		if r, isRaw := pv.raw[k]; isRaw {
+			parts[n+1] = r
+		} else {
+			parts[n+1] = formatParameter(k, pv.ps[k])
+		}
 	}
 
 	return strings.Join(parts, "; ")
 }
 
+// formatParameter formats a single parameter, quoting or encoding the value as
+// done by mime.FormatMediaType.
+func formatParameter(k, v string) string {
+	const prefix = "x/x; "
+	if s := mime.FormatMediaType("x/x", map[string]string{k: v}); strings.HasPrefix(s, prefix) {
+		return s[len(prefix):]
+	}
+	return fmt.Sprintf("%s=%s", k, v)
+}
+
 // Bytes returns the serialized value of the Value including the primary value
 // and all parameters.
 func (pv *Value) Bytes() []byte {
@@ -198,5 +329,13 @@ func (pv *Value) Clone() *Value {
 	for k, v := range pv.ps {
 		cp.ps[k] = v
 	}
+	cp.order = append([]string{}, pv.order...)
+	cp.rawV = pv.rawV
+	if pv.raw != nil {
+		cp.raw = make(map[string]string, len(pv.raw))
+		for k, r := range pv.raw {
+			cp.raw[k] = r
+		}
+	}
 	return &cp
 }
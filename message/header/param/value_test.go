@@ -1,9 +1,11 @@
 package param_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/zostay/go-email/v2/message/header/param"
 )
@@ -18,12 +20,19 @@ func TestParse(t *testing.T) {
 	assert.NoError(t, err)
 
 	assert.Equal(t, "text", mt.MediaType())
-	assert.Equal(t, "", mt.Type())
+	assert.Equal(t, "text", mt.Type())
 	assert.Equal(t, "", mt.Subtype())
 	assert.Equal(t, "text", mt.Presentation())
 	assert.Equal(t, "text", mt.Value())
 	assert.Equal(t, map[string]string{}, mt.Parameters())
 
+	mt, err = param.Parse("application")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "application", mt.MediaType())
+	assert.Equal(t, "application", mt.Type())
+	assert.Equal(t, "", mt.Subtype())
+
 	mt, err = param.Parse("image/jpeg")
 	assert.NoError(t, err)
 
@@ -78,6 +87,78 @@ func TestModify(t *testing.T) {
 	assert.Equal(t, []byte("text/x-json; charset=utf-8"), mt.Bytes())
 }
 
+func TestModify_PreservesOrder(t *testing.T) {
+	t.Parallel()
+
+	mt, err := param.Parse(`multipart/mixed; charset=utf-8; boundary="abc"; format=flowed`)
+	assert.NoError(t, err)
+	assert.Equal(t, `multipart/mixed; charset=utf-8; boundary="abc"; format=flowed`, mt.String())
+
+	mt = param.Modify(mt,
+		param.Set(param.Boundary, "xyz"),
+		param.Set("delsp", "yes"),
+		param.Delete(param.Charset),
+	)
+	assert.Equal(t, "multipart/mixed; boundary=xyz; format=flowed; delsp=yes", mt.String())
+}
+
+func TestModify_PreservesOtherParameters(t *testing.T) {
+	t.Parallel()
+
+	segments := []struct {
+		name   string
+		raw    string
+		change string
+		expect string
+	}{
+		{"charset", `Charset="UTF-8"`, "iso-8859-1", `charset=iso-8859-1`},
+		{"format", `format=flowed`, "fixed", `format=fixed`},
+		{"delsp", `DelSp=yes`, "no", `delsp=no`},
+		{"name", `name="my notes.txt"`, "other notes.txt", `name="other notes.txt"`},
+		{"title", `title*0*=utf-8''caf%C3%A9; title*1=" menu"`, "plats du jour", `title="plats du jour"`},
+		{"x-note", `x-note=a`, "café", `x-note*=utf-8''caf%C3%A9`},
+	}
+
+	raw := make([]string, len(segments))
+	for i, seg := range segments {
+		raw[i] = seg.raw
+	}
+
+	const mediaType = "Text/Plain"
+	src := mediaType + "; " + strings.Join(raw, "; ")
+
+	for i, seg := range segments {
+		i, seg := i, seg
+		t.Run(seg.name, func(t *testing.T) {
+			t.Parallel()
+
+			mt, err := param.Parse(src)
+			require.NoError(t, err)
+			assert.Equal(t, src, mt.String())
+
+			// setting the same value leaves it alone
+			same := param.Modify(mt, param.Set(seg.name, mt.Parameter(seg.name)))
+			assert.Equal(t, src, same.String())
+
+			expect := make([]string, len(segments))
+			copy(expect, raw)
+			expect[i] = seg.expect
+
+			changed := param.Modify(mt, param.Set(seg.name, seg.change))
+			assert.Equal(t, mediaType+"; "+strings.Join(expect, "; "), changed.String())
+			assert.Equal(t, seg.change, changed.Parameter(seg.name))
+
+			reparsed, err := param.Parse(changed.String())
+			require.NoError(t, err)
+			assert.Equal(t, changed.Parameters(), reparsed.Parameters())
+
+			expect = append(append([]string{}, raw[:i]...), raw[i+1:]...)
+			deleted := param.Modify(mt, param.Delete(seg.name))
+			assert.Equal(t, mediaType+"; "+strings.Join(expect, "; "), deleted.String())
+		})
+	}
+}
+
 func TestValue_Parameter(t *testing.T) {
 	t.Parallel()
 
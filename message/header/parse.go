@@ -38,5 +38,10 @@ func Parse(m []byte, lb Break) (*Header, error) {
 		valueCache: nil,
 	}
 
+	// decode again with a charset reader that handles more than field.Parse
+	for _, f := range fields {
+		h.decodeField(f)
+	}
+
 	return h, finalErr
 }
@@ -0,0 +1,158 @@
+package message
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/zostay/go-email/v2/message/header"
+)
+
+// jsonField is the JSON projection of a single header field.
+type jsonField struct {
+	Name string `json:"name"`
+	Body string `json:"body"`
+}
+
+// jsonPart is the JSON projection of a message or message part.
+type jsonPart struct {
+	Header      []jsonField `json:"header"`
+	ContentType string      `json:"content_type,omitempty"`
+	Disposition string      `json:"disposition,omitempty"`
+	Text        *string     `json:"text,omitempty"`
+	Data        []byte      `json:"data,omitempty"`
+	Parts       []*jsonPart `json:"parts,omitempty"`
+}
+
+// ToJSON returns a JSON representation of the message, which is intended for
+// logging and for indexing pipelines. This is a read-only projection and
+// cannot be parsed back into a message. Each part, starting with the message
+// itself, is represented by a JSON object with the following keys:
+//
+// * "header" is a list of objects, each with a "name" and "body", one for each
+// header field in the order they appear.
+//
+// * "content_type" is the media type from the Content-type header, if any.
+//
+// * "disposition" is the presentation from the Content-disposition header, if
+// any.
+//
+// * "parts" is a list of these objects, one for each sub-part of a multipart.
+//
+// * "text" holds the content of a text part (or a part with no Content-type)
+// with the Content-transfer-encoding decoded and the charset converted to
+// UTF-8.
+//
+// * "data" holds the content of any other part, with the
+// Content-transfer-encoding decoded, as a base64 string. A text part whose
+// content cannot be converted to UTF-8 is also given as "data".
+//
+// In order to project an *Opaque part, its io.Reader must be read completely.
+// The Reader will be replaced with an in-memory copy of the same bytes so the
+// part may still be used afterwards. It returns an error if there is a problem
+// reading any part.
+func ToJSON(msg Generic) ([]byte, error) {
+	jp, err := toJSONPart(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(jp)
+}
+
+// toJSONPart implements the recursive part of ToJSON.
+func toJSONPart(part Part) (*jsonPart, error) {
+	h := part.GetHeader()
+
+	fields := h.ListFields()
+	jp := &jsonPart{
+		Header: make([]jsonField, len(fields)),
+	}
+
+	for i, f := range fields {
+		jp.Header[i] = jsonField{
+			Name: f.Name(),
+			Body: f.Body(),
+		}
+	}
+
+	mt, err := h.GetMediaType()
+	if err == nil {
+		jp.ContentType = mt
+	}
+
+	pres, err := h.GetPresentation()
+	if err == nil {
+		jp.Disposition = pres
+	}
+
+	if part.IsMultipart() {
+		parts := part.GetParts()
+		jp.Parts = make([]*jsonPart, len(parts))
+		for i, p := range parts {
+			jp.Parts[i], err = toJSONPart(p)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return jp, nil
+	}
+
+	content, err := readDecodedContent(part)
+	if err != nil {
+		return nil, err
+	}
+
+	if mt == "" || strings.HasPrefix(strings.ToLower(mt), "text/") {
+		if text, ok := decodeText(h, content); ok {
+			jp.Text = &text
+			return jp, nil
+		}
+	}
+
+	jp.Data = content
+	return jp, nil
+}
+
+// decodeText converts the content to a UTF-8 string using the charset set on
+// the Content-type header. If a text/html part has no charset there, the
+// charset declared in the HTML is used (see HTMLCharset). Otherwise, the
+// content is assumed to be UTF-8. Other charsets are converted using
+// header.DefaultCharsetReader. It returns false if the content cannot be
+// converted.
+func decodeText(h *header.Header, content []byte) (string, bool) {
+	charset, err := h.GetCharset()
+	if err != nil && !errors.Is(err, header.ErrNoSuchField) &&
+		!errors.Is(err, header.ErrNoSuchFieldParameter) {
+		return "", false
+	}
+
+	if mt, _ := h.GetMediaType(); charset == "" && strings.EqualFold(mt, "text/html") {
+		charset = HTMLCharset(content)
+	}
+
+	text := string(content)
+	switch strings.ToLower(charset) {
+	case "", "us-ascii", "utf-8", "utf8":
+	default:
+		r, err := header.DefaultCharsetReader(charset, bytes.NewReader(content))
+		if err != nil {
+			return "", false
+		}
+
+		tb, err := io.ReadAll(r)
+		if err != nil {
+			return "", false
+		}
+		text = string(tb)
+	}
+
+	if !utf8.ValidString(text) {
+		return "", false
+	}
+
+	return text, true
+}
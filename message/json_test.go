@@ -0,0 +1,70 @@
+package message_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/go-email/v2/message"
+)
+
+func TestToJSON(t *testing.T) {
+	t.Parallel()
+
+	const src = `Subject: json me
+Content-type: multipart/mixed; boundary=outer
+
+--outer
+Content-type: text/plain; charset=utf-8
+Content-transfer-encoding: quoted-printable
+
+Caf=C3=A9 menu.
+--outer
+Content-type: application/octet-stream
+Content-disposition: attachment; filename=blob.bin
+Content-transfer-encoding: base64
+
+AAEC/w==
+--outer--
+`
+
+	m, err := message.Parse(strings.NewReader(src))
+	require.NoError(t, err)
+
+	js, err := message.ToJSON(m)
+	require.NoError(t, err)
+
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(js, &got))
+
+	assert.Equal(t, map[string]any{
+		"header": []any{
+			map[string]any{"name": "Subject", "body": "json me"},
+			map[string]any{"name": "Content-type", "body": "multipart/mixed; boundary=outer"},
+		},
+		"content_type": "multipart/mixed",
+		"parts": []any{
+			map[string]any{
+				"header": []any{
+					map[string]any{"name": "Content-type", "body": "text/plain; charset=utf-8"},
+					map[string]any{"name": "Content-transfer-encoding", "body": "quoted-printable"},
+				},
+				"content_type": "text/plain",
+				"text":         "Café menu.",
+			},
+			map[string]any{
+				"header": []any{
+					map[string]any{"name": "Content-type", "body": "application/octet-stream"},
+					map[string]any{"name": "Content-disposition", "body": "attachment; filename=blob.bin"},
+					map[string]any{"name": "Content-transfer-encoding", "body": "base64"},
+				},
+				"content_type": "application/octet-stream",
+				"disposition":  "attachment",
+				"data":         "AAEC/w==",
+			},
+		},
+	}, got)
+}
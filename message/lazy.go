@@ -0,0 +1,229 @@
+package message
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/zostay/go-email/v2/message/header"
+)
+
+// WithLazyParts is a ParseOption that defers parsing the parts of a multipart
+// message until they are used. The parser still splits each multipart on its
+// boundaries, but rather than parsing every part (and every part nested within
+// it) up front, each part found is held as a *LazyPart with a copy of its
+// original bytes. A *LazyPart is parsed the first time any of its methods is
+// called and the result is kept, so it is parsed at most once. This saves a
+// great deal of work when a message has many large parts, but only one or two
+// of them will ever be inspected. Parsing every part immediately remains the
+// default.
+//
+// Because of this, the parts returned by GetParts() will be *LazyPart objects
+// rather than *Opaque or *Multipart objects. Call Resolve() on a *LazyPart to
+// get at the parsed part itself (e.g., for use in a type switch).
+//
+// Since the parts are not parsed in order, WithStopAt() and
+// WithMaxTotalDepthWork() are applied to each lazily parsed part separately.
+// An error parsing a part is reported by Resolve() rather than by Parse().
+func WithLazyParts() ParseOption {
+	return func(pr *parser) { pr.lazy = true }
+}
+
+// LazyPart is a part of a *Multipart parsed with WithLazyParts(). It holds the
+// original bytes of the part and parses them the first time the part is used.
+// It implements Part by delegating to the parsed part, so it may be used
+// anywhere a Part is expected.
+//
+// Parsing is memoized and guarded by a mutex, so a *LazyPart may be resolved
+// from multiple goroutines at once and every caller will get the same parsed
+// part. However, the parsed part itself is no safer for concurrent use than any
+// other *Opaque or *Multipart (e.g., its io.Reader may only be read by one
+// goroutine).
+type LazyPart struct {
+	raw   []byte
+	pr    *parser
+	depth int
+	loc   partLocation
+	index int
+
+	// boundary and offset are used to report errors
+	boundary string
+	offset   int64
+
+	mu       sync.Mutex
+	resolved bool
+	part     Generic
+	err      error
+}
+
+// newLazyPart returns a *LazyPart for the ith part of the multipart at loc,
+// which is found at the given offset in the input. A copy of the bytes is
+// made.
+func newLazyPart(
+	raw []byte,
+	pr *parser,
+	depth int,
+	loc partLocation,
+	i int,
+	offset int64,
+	boundary string,
+) *LazyPart {
+	rb := make([]byte, len(raw))
+	copy(rb, raw)
+
+	// every part gets a fresh parser as the parts may be parsed in any order
+	lpr := pr.clone()
+	lpr.stopped = false
+	lpr.overBudget = false
+	lpr.work = 0
+
+	// the part was checked when it was split off and any violation found
+	// within it now could not be reported
+	lpr.checkBounds = false
+	lpr.violations = nil
+
+	return &LazyPart{
+		raw:      rb,
+		pr:       lpr,
+		depth:    depth,
+		loc:      loc,
+		index:    i,
+		boundary: boundary,
+		offset:   offset,
+	}
+}
+
+// Resolve parses the part, if it has not been parsed already, and returns it.
+// The returned part will be an *Opaque or a *Multipart. If there is an error
+// parsing the part, the error is returned along with an *Opaque holding the
+// original bytes of the part. The same part and error are returned every time
+// this is called.
+func (lp *LazyPart) Resolve() (Generic, error) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+
+	if !lp.resolved {
+		lp.part, lp.err = lp.parse()
+		lp.resolved = true
+	}
+
+	return lp.part, lp.err
+}
+
+// IsResolved returns true if the part has been parsed already.
+func (lp *LazyPart) IsResolved() bool {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	return lp.resolved
+}
+
+// parse implements the parsing done by Resolve.
+func (lp *LazyPart) parse() (Generic, error) {
+	opMsg, hdrLen, err := lp.pr.parseToOpaque(bytes.NewReader(lp.raw), true)
+	if err != nil {
+		return lp.original(), lp.loc.parseError(err, lp.index, lp.offset, lp.boundary)
+	}
+
+	msg, err := lp.pr.parse(opMsg, lp.depth, lp.loc.child(lp.index, lp.offset+int64(hdrLen)))
+	if err != nil {
+		return lp.original(), err
+	}
+
+	if op, isOpaque := msg.(*Opaque); isOpaque && lp.pr.keepRaw {
+		op.raw = lp.raw
+	}
+
+	return msg, nil
+}
+
+// original returns an *Opaque with no header and the original bytes of the
+// part as its content.
+func (lp *LazyPart) original() *Opaque {
+	return &Opaque{Reader: bytes.NewReader(lp.raw)}
+}
+
+// resolve returns the parsed part, ignoring any error.
+func (lp *LazyPart) resolve() Generic {
+	part, _ := lp.Resolve()
+	return part
+}
+
+// writesRaw returns true if the part has not been parsed yet or could not be
+// parsed, in which case it is written from its original bytes.
+func (lp *LazyPart) writesRaw() bool {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	return !lp.resolved || lp.err != nil
+}
+
+// WriteTo writes the part to the given io.Writer. If the part has not been
+// parsed yet or could not be parsed, the original bytes of the part are
+// written as-is without parsing it. Otherwise, the parsed part is written.
+func (lp *LazyPart) WriteTo(w io.Writer) (int64, error) {
+	if lp.writesRaw() {
+		n, err := w.Write(lp.raw)
+		return int64(n), err
+	}
+
+	return lp.resolve().WriteTo(w)
+}
+
+// hasContent returns true if WriteTo will write any bytes for the part without
+// parsing it to find out. An unparsed part is written from its original bytes.
+func (lp *LazyPart) hasContent() bool {
+	if lp.writesRaw() {
+		return lp.raw != nil
+	}
+
+	return partHasContent(lp.resolve())
+}
+
+// unwrapLazy returns the parsed part if the given part is a *LazyPart, so that
+// it may be type checked as an *Opaque or *Multipart. Any other part is
+// returned as-is.
+func unwrapLazy(part Part) Part {
+	if lp, isLazy := part.(*LazyPart); isLazy {
+		return lp.resolve()
+	}
+	return part
+}
+
+// partHasContent returns true if the part has sub-parts or a body to write,
+// which decides whether Multipart.WriteTo writes a line break before the next
+// boundary. A *LazyPart is checked without parsing it.
+func partHasContent(part Part) bool {
+	if lp, isLazy := part.(*LazyPart); isLazy {
+		return lp.hasContent()
+	}
+	return part.IsMultipart() || part.GetReader() != nil
+}
+
+// IsMultipart parses the part, if needed, and returns IsMultipart() of the
+// parsed part.
+func (lp *LazyPart) IsMultipart() bool {
+	return lp.resolve().IsMultipart()
+}
+
+// IsEncoded parses the part, if needed, and returns IsEncoded() of the parsed
+// part.
+func (lp *LazyPart) IsEncoded() bool {
+	return lp.resolve().IsEncoded()
+}
+
+// GetHeader parses the part, if needed, and returns GetHeader() of the parsed
+// part.
+func (lp *LazyPart) GetHeader() *header.Header {
+	return lp.resolve().GetHeader()
+}
+
+// GetReader parses the part, if needed, and returns GetReader() of the parsed
+// part.
+func (lp *LazyPart) GetReader() io.Reader {
+	return lp.resolve().GetReader()
+}
+
+// GetParts parses the part, if needed, and returns GetParts() of the parsed
+// part.
+func (lp *LazyPart) GetParts() []Part {
+	return lp.resolve().GetParts()
+}
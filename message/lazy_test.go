@@ -0,0 +1,146 @@
+package message_test
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/go-email/v2/message"
+)
+
+const lazySrc = `Subject: lazy
+Content-type: multipart/mixed; boundary=outer
+
+--outer
+Content-type: text/plain
+
+first part
+--outer
+Content-type: multipart/alternative; boundary=inner
+
+--inner
+Content-type: text/plain
+
+inner text
+--inner
+Content-type: text/html
+
+<p>inner html</p>
+--inner--
+--outer--
+`
+
+func TestWithLazyParts(t *testing.T) {
+	t.Parallel()
+
+	m, err := message.Parse(strings.NewReader(lazySrc), message.WithLazyParts())
+	require.NoError(t, err)
+	require.True(t, m.IsMultipart())
+
+	parts := m.GetParts()
+	require.Len(t, parts, 2)
+
+	lp0, isLazy := parts[0].(*message.LazyPart)
+	require.True(t, isLazy)
+	lp1, isLazy := parts[1].(*message.LazyPart)
+	require.True(t, isLazy)
+	assert.False(t, lp0.IsResolved())
+	assert.False(t, lp1.IsResolved())
+
+	// only the part used is parsed
+	assert.True(t, lp1.IsMultipart())
+	assert.False(t, lp0.IsResolved())
+	assert.True(t, lp1.IsResolved())
+
+	p1, err := lp1.Resolve()
+	require.NoError(t, err)
+	assert.IsType(t, &message.Multipart{}, p1)
+
+	again, err := lp1.Resolve()
+	require.NoError(t, err)
+	assert.Same(t, p1, again)
+
+	inner := lp1.GetParts()
+	require.Len(t, inner, 2)
+	mt, err := inner[1].GetHeader().GetMediaType()
+	require.NoError(t, err)
+	assert.Equal(t, "text/html", mt)
+
+	buf := &bytes.Buffer{}
+	_, err = m.WriteTo(buf)
+	require.NoError(t, err)
+	assert.Equal(t, lazySrc, buf.String())
+
+	// writing the message does not parse the parts that were never used
+	assert.False(t, lp0.IsResolved())
+}
+
+func TestWithLazyParts_WriteTo(t *testing.T) {
+	t.Parallel()
+
+	m, err := message.Parse(strings.NewReader(lazySrc), message.WithLazyParts())
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	_, err = m.WriteTo(buf)
+	require.NoError(t, err)
+	assert.Equal(t, lazySrc, buf.String())
+
+	for _, p := range m.(*message.Multipart).GetParts() {
+		assert.False(t, p.(*message.LazyPart).IsResolved())
+	}
+}
+
+func TestLazyPart_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	m, err := message.Parse(strings.NewReader(lazySrc), message.WithLazyParts())
+	require.NoError(t, err)
+
+	lp := m.GetParts()[1].(*message.LazyPart)
+
+	const n = 10
+	got := make([]message.Generic, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got[i], _ = lp.Resolve()
+		}()
+	}
+	wg.Wait()
+
+	for _, p := range got {
+		assert.Same(t, got[0], p)
+	}
+}
+
+func TestWithLazyParts_Error(t *testing.T) {
+	t.Parallel()
+
+	src := "Content-type: multipart/mixed; boundary=x\n\n--x\n" +
+		"Subject: " + strings.Repeat("long header, ", 10) + "\n\nbody\n--x--\n"
+
+	m, err := message.Parse(strings.NewReader(src),
+		message.WithLazyParts(), message.WithMaxHeaderLength(60), message.WithChunkSize(16))
+	require.NoError(t, err)
+
+	lp := m.GetParts()[0].(*message.LazyPart)
+	p, err := lp.Resolve()
+	assert.ErrorIs(t, err, message.ErrLargeHeader)
+	var pe *message.ParseError
+	require.ErrorAs(t, err, &pe)
+	assert.Equal(t, []int{0}, pe.Path)
+	assert.IsType(t, &message.Opaque{}, p)
+
+	buf := &bytes.Buffer{}
+	_, err = m.WriteTo(buf)
+	require.NoError(t, err)
+	assert.Equal(t, src, buf.String())
+}
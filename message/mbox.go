@@ -0,0 +1,224 @@
+package message
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/zostay/go-email/v2/message/header"
+)
+
+// MboxTimeLayout is the layout of the date on the "From " separator line
+// written by MboxWriter, which is the ctime layout used by mbox tools.
+const MboxTimeLayout = time.ANSIC
+
+// DefaultMboxEnvelopeFrom is the envelope sender written by MboxWriter when
+// none is given.
+const DefaultMboxEnvelopeFrom = "MAILER-DAEMON"
+
+// mboxFrom is the start of the separator line found before each message in an
+// mbox file.
+var mboxFrom = []byte("From ")
+
+// MboxReader reads the messages out of a Unix mailbox (mbox) file one at a
+// time. It is created with NewMboxReader.
+type MboxReader struct {
+	r    *bufio.Reader
+	opts []ParseOption
+	next []byte
+	done bool
+}
+
+// MboxMessage is a message read by MboxReader along with the envelope sender
+// found on the "From " separator line before it, which is not recorded
+// anywhere in the message itself. It embeds the parsed message, so it may be
+// used anywhere a Generic is expected. Use the embedded Generic to get at the
+// *Opaque or *Multipart itself. Use EnvelopeFrom to get the envelope sender.
+type MboxMessage struct {
+	Generic
+
+	envelopeFrom string
+}
+
+// EnvelopeFrom returns the envelope sender from the "From " separator line of
+// a message read by MboxReader, e.g., "alice@example.com" from the line "From
+// alice@example.com Mon Jan  2 15:04:05 2006". It returns false if msg was not
+// read by MboxReader or if there was no separator line before it.
+func EnvelopeFrom(msg Generic) (string, bool) {
+	mm, isMbox := msg.(*MboxMessage)
+	if !isMbox || mm.envelopeFrom == "" {
+		return "", false
+	}
+	return mm.envelopeFrom, true
+}
+
+// NewMboxReader returns an MboxReader for reading messages in mbox format from
+// r. Each message is expected to start with a "From " separator line and the
+// messages are parsed with Parse using the given options.
+func NewMboxReader(r io.Reader, opts ...ParseOption) *MboxReader {
+	return &MboxReader{
+		r:    bufio.NewReader(r),
+		opts: opts,
+	}
+}
+
+// Next reads the next message from the mbox and returns it parsed. The "From "
+// separator line is not part of the message. Any body line quoted as ">From ",
+// ">>From ", etc. is unquoted by removing one ">" and the blank line that ends
+// each message in the mbox is removed.
+//
+// Each message that follows a separator line is returned as an *MboxMessage
+// holding the envelope sender from that line (see EnvelopeFrom). Any bytes
+// found before the first separator line, other than blank lines, are treated
+// as a message and returned as parsed, without an *MboxMessage.
+//
+// It returns io.EOF when there are no more messages. If Parse fails, the
+// partially parsed message is returned with the error and the MboxReader may
+// still be used to read the messages that follow.
+func (mr *MboxReader) Next() (Generic, error) {
+	if mr.done {
+		return nil, io.EOF
+	}
+
+	var (
+		buf  = &bytes.Buffer{}
+		from []byte
+	)
+
+	for {
+		line := mr.next
+		mr.next = nil
+		if line == nil {
+			var err error
+			line, err = mr.r.ReadBytes('\n')
+			if errors.Is(err, io.EOF) {
+				if len(line) == 0 {
+					mr.done = true
+					break
+				}
+			} else if err != nil {
+				return nil, err
+			}
+		}
+
+		if bytes.HasPrefix(line, mboxFrom) {
+			// nothing but blank lines so far, so this starts the message
+			if len(bytes.TrimSpace(buf.Bytes())) == 0 {
+				buf.Reset()
+				from = line
+				continue
+			}
+
+			// this starts the next message
+			mr.next = line
+			break
+		}
+
+		buf.Write(unquoteMboxFrom(line))
+	}
+
+	if len(bytes.TrimSpace(buf.Bytes())) == 0 {
+		return nil, io.EOF
+	}
+
+	msg, err := Parse(bytes.NewReader(trimMboxBlankLine(buf.Bytes())), mr.opts...)
+	if msg == nil || from == nil {
+		return msg, err
+	}
+
+	var envelopeFrom string
+	if fs := bytes.Fields(from[len(mboxFrom):]); len(fs) > 0 {
+		envelopeFrom = string(fs[0])
+	}
+
+	return &MboxMessage{
+		Generic:      msg,
+		envelopeFrom: envelopeFrom,
+	}, err
+}
+
+// unquoteMboxFrom removes one ">" from the front of a line that starts with
+// one or more ">" followed by "From ".
+func unquoteMboxFrom(line []byte) []byte {
+	if bytes.HasPrefix(bytes.TrimLeft(line, ">"), mboxFrom) && line[0] == '>' {
+		return line[1:]
+	}
+	return line
+}
+
+// trimMboxBlankLine removes the blank line that ends each message in an mbox.
+func trimMboxBlankLine(msg []byte) []byte {
+	for _, br := range [][]byte{[]byte("\r\n\r\n"), []byte("\n\n")} {
+		if bytes.HasSuffix(msg, br) {
+			return msg[:len(msg)-len(br)/2]
+		}
+	}
+	return msg
+}
+
+// MboxWriter writes messages to a Unix mailbox (mbox) file. It is created with
+// NewMboxWriter.
+type MboxWriter struct {
+	w   io.Writer
+	lbr header.Break
+}
+
+// NewMboxWriter returns an MboxWriter that appends messages to w in mbox
+// format, using the given line break for every line written. If lbr is empty,
+// header.LF is used.
+func NewMboxWriter(w io.Writer, lbr header.Break) *MboxWriter {
+	if lbr == "" {
+		lbr = header.LF
+	}
+
+	return &MboxWriter{w: w, lbr: lbr}
+}
+
+// Append writes the message to the mbox. It is preceded by a "From " separator
+// line naming the envelope sender and the time formatted with MboxTimeLayout,
+// e.g., "From alice@example.com Mon Jan  2 15:04:05 2006". If envelopeFrom is
+// empty, DefaultMboxEnvelopeFrom is used. If t is the zero time, the current
+// time is used (see SetClock).
+//
+// Any line of the message starting with "From ", ">From ", ">>From ", etc. is
+// quoted by adding a ">" to the front, which MboxReader will undo. Every line
+// break in the message is replaced with the line break of the MboxWriter and
+// the message is followed by a blank line.
+//
+// The message is written using WriteTo, so the message's io.Reader objects will
+// be consumed.
+func (mw *MboxWriter) Append(msg Generic, envelopeFrom string, t time.Time) error {
+	if envelopeFrom == "" {
+		envelopeFrom = DefaultMboxEnvelopeFrom
+	}
+
+	if t.IsZero() {
+		t = header.Now()
+	}
+
+	buf := &bytes.Buffer{}
+	_, err := msg.WriteTo(buf)
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(mw.w)
+	_, _ = fmt.Fprintf(out, "From %s %s%s", envelopeFrom, t.Format(MboxTimeLayout), mw.lbr)
+
+	for buf.Len() > 0 {
+		line, _ := buf.ReadBytes('\n')
+		line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r"))
+		if bytes.HasPrefix(bytes.TrimLeft(line, ">"), mboxFrom) {
+			_ = out.WriteByte('>')
+		}
+		_, _ = out.Write(line)
+		_, _ = out.WriteString(mw.lbr.String())
+	}
+
+	_, _ = out.WriteString(mw.lbr.String())
+
+	return out.Flush()
+}
@@ -0,0 +1,187 @@
+package message_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/go-email/v2/message"
+	"github.com/zostay/go-email/v2/message/header"
+)
+
+const mboxSrc = `From alice@example.com Thu Jan  1 00:00:00 2023
+Subject: one
+
+>From the top.
+>>From the quote.
+> From stays.
+
+From bob@example.com Fri Jan  2 00:00:00 2023
+Subject: two
+Content-type: multipart/mixed; boundary=b
+
+--b
+
+part
+--b--
+
+From carol@example.com Sat Jan  3 00:00:00 2023
+Subject: three
+
+last
+`
+
+func TestMboxReader(t *testing.T) {
+	t.Parallel()
+
+	mr := message.NewMboxReader(strings.NewReader(mboxSrc))
+
+	expect := []string{
+		"Subject: one\n\nFrom the top.\n>From the quote.\n> From stays.\n",
+		"Subject: two\nContent-type: multipart/mixed; boundary=b\n\n--b\n\npart\n--b--\n",
+		"Subject: three\n\nlast\n",
+	}
+
+	expectFrom := []string{
+		"alice@example.com",
+		"bob@example.com",
+		"carol@example.com",
+	}
+
+	for i, e := range expect {
+		m, err := mr.Next()
+		require.NoError(t, err)
+
+		assert.Equal(t, i == 1, m.IsMultipart())
+
+		from, ok := message.EnvelopeFrom(m)
+		assert.True(t, ok)
+		assert.Equal(t, expectFrom[i], from)
+
+		buf := &bytes.Buffer{}
+		_, err = m.WriteTo(buf)
+		assert.NoError(t, err)
+		assert.Equal(t, e, buf.String())
+	}
+
+	_, err := mr.Next()
+	assert.ErrorIs(t, err, io.EOF)
+	_, err = mr.Next()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestMboxReader_Options(t *testing.T) {
+	t.Parallel()
+
+	mr := message.NewMboxReader(strings.NewReader(mboxSrc), message.WithoutMultipart())
+
+	for i := 0; i < 3; i++ {
+		m, err := mr.Next()
+		require.NoError(t, err)
+		assert.False(t, m.IsMultipart())
+	}
+
+	_, err := mr.Next()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestMboxReader_NoSeparator(t *testing.T) {
+	t.Parallel()
+
+	mr := message.NewMboxReader(strings.NewReader("Subject: stray\n\nbody\n\n" + mboxSrc))
+
+	m, err := mr.Next()
+	require.NoError(t, err)
+	_, isMbox := m.(*message.MboxMessage)
+	assert.False(t, isMbox)
+
+	from, ok := message.EnvelopeFrom(m)
+	assert.False(t, ok)
+	assert.Equal(t, "", from)
+
+	m, err = mr.Next()
+	require.NoError(t, err)
+	mm, isMbox := m.(*message.MboxMessage)
+	require.True(t, isMbox)
+	assert.IsType(t, &message.Opaque{}, mm.Generic)
+
+	from, ok = message.EnvelopeFrom(m)
+	assert.True(t, ok)
+	assert.Equal(t, "alice@example.com", from)
+}
+
+func TestMboxReader_Empty(t *testing.T) {
+	t.Parallel()
+
+	mr := message.NewMboxReader(strings.NewReader("\n\n"))
+	_, err := mr.Next()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestMboxWriter(t *testing.T) {
+	t.Parallel()
+
+	const (
+		srcA = "Subject: one\n\nFrom the top.\n>From the quote.\nno break"
+		srcB = "Subject: two\r\n\r\nFrom CRLF.\r\n"
+	)
+
+	when := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	buf := &bytes.Buffer{}
+	mw := message.NewMboxWriter(buf, "")
+	for i, src := range []string{srcA, srcB} {
+		m, err := message.Parse(strings.NewReader(src))
+		require.NoError(t, err)
+
+		from := "alice@example.com"
+		if i == 1 {
+			from = ""
+		}
+
+		err = mw.Append(m, from, when)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, "From alice@example.com Mon Jan  2 03:04:05 2023\n"+
+		"Subject: one\n\n>From the top.\n>>From the quote.\nno break\n\n"+
+		"From MAILER-DAEMON Mon Jan  2 03:04:05 2023\n"+
+		"Subject: two\n\n>From CRLF.\n\n", buf.String())
+
+	mr := message.NewMboxReader(bytes.NewReader(buf.Bytes()))
+	for _, expect := range []string{
+		"Subject: one\n\nFrom the top.\n>From the quote.\nno break\n",
+		"Subject: two\n\nFrom CRLF.\n",
+	} {
+		m, err := mr.Next()
+		require.NoError(t, err)
+
+		out := &bytes.Buffer{}
+		_, err = m.WriteTo(out)
+		assert.NoError(t, err)
+		assert.Equal(t, expect, out.String())
+	}
+
+	_, err := mr.Next()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestMboxWriter_Break(t *testing.T) {
+	t.Parallel()
+
+	m, err := message.Parse(strings.NewReader("Subject: crlf\n\nbody\n"))
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	mw := message.NewMboxWriter(buf, header.CRLF)
+	err = mw.Append(m, "bob@example.com", time.Date(2023, 11, 12, 13, 14, 15, 0, time.UTC))
+	require.NoError(t, err)
+
+	assert.Equal(t, "From bob@example.com Sun Nov 12 13:14:15 2023\r\n"+
+		"Subject: crlf\r\n\r\nbody\r\n\r\n", buf.String())
+}
@@ -1,8 +1,10 @@
 package message
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/zostay/go-email/v2/message/header"
 )
@@ -100,6 +102,17 @@ type Multipart struct {
 
 	// parts holds this layer's parts
 	parts []Part
+
+	// rest holds the unparsed remainder of the message following the last part
+	// when parsing was stopped early by WithStopAt(). It includes everything
+	// following the boundary after the last part, including any final boundary
+	// and suffix.
+	rest io.Reader
+
+	// raw holds a copy of the original bytes of each part of a parsed
+	// multipart/signed message, which SignedContent() needs because signature
+	// verification requires the exact bytes that were signed
+	raw [][]byte
 }
 
 // WriteTo writes the Opaque header and parts to the destination io.Writer.
@@ -149,7 +162,7 @@ func (mm *Multipart) WriteTo(w io.Writer) (int64, error) {
 			}
 
 			// only insert a newline if there are some bytes in here...
-			hadContent = part.IsMultipart() || part.GetReader() != nil
+			hadContent = partHasContent(part)
 
 			pn, err := part.WriteTo(w)
 			n += pn
@@ -158,6 +171,28 @@ func (mm *Multipart) WriteTo(w io.Writer) (int64, error) {
 			}
 		}
 
+		if mm.rest != nil {
+			if hadContent {
+				bn, err := fmt.Fprint(w, br)
+				n += int64(bn)
+				if err != nil {
+					return n, err
+				}
+			}
+
+			bn, err := fmt.Fprintf(w, "--%s%s", boundary, br)
+			n += int64(bn)
+			if err != nil {
+				return n, err
+			}
+
+			rn, err := io.Copy(w, mm.rest)
+			n += rn
+			if err != nil {
+				return n, err
+			}
+		}
+
 		if mm.suffix != nil {
 			bn, err := fmt.Fprintf(w, "%s--%s--", br, boundary)
 			n += int64(bn)
@@ -201,6 +236,72 @@ func (mm *Multipart) GetParts() []Part {
 	return mm.parts
 }
 
+// BodyParts returns the leaf parts of this message that a mail client would
+// display as the body of the message. These are the text/plain and text/html
+// parts (or parts with no Content-type at all) that are either marked inline
+// or have no Content-disposition, and have no filename. Sub-parts of nested
+// multipart parts are searched as well. The parts are returned in the order
+// they appear in the message.
+func (mm *Multipart) BodyParts() []Generic {
+	return collectParts(mm, isBodyPart)
+}
+
+// AttachmentParts returns the leaf parts of this message that a mail client
+// would present as attachments. These are the parts with a Content-disposition
+// of attachment or with a filename set. Sub-parts of nested multipart parts
+// are searched as well. The parts are returned in the order they appear in the
+// message.
+func (mm *Multipart) AttachmentParts() []Generic {
+	return collectParts(mm, isAttachmentPart)
+}
+
+// collectParts returns every leaf part under the given part that matches.
+func collectParts(part Part, matches func(Part) bool) []Generic {
+	found := []Generic{}
+	for _, p := range part.GetParts() {
+		if p.IsMultipart() {
+			found = append(found, collectParts(p, matches)...)
+		} else if matches(p) {
+			found = append(found, p)
+		}
+	}
+	return found
+}
+
+// isAttachmentPart returns true if the part is marked as an attachment or has a
+// filename.
+func isAttachmentPart(part Part) bool {
+	h := part.GetHeader()
+	if pres, err := h.GetPresentation(); err == nil && strings.EqualFold(pres, "attachment") {
+		return true
+	}
+
+	fn, err := h.GetFilename()
+	return err == nil && fn != ""
+}
+
+// isBodyPart returns true if the part is a text/plain or text/html part that is
+// not an attachment.
+func isBodyPart(part Part) bool {
+	if isAttachmentPart(part) {
+		return false
+	}
+
+	h := part.GetHeader()
+	if pres, err := h.GetPresentation(); err == nil && !strings.EqualFold(pres, "inline") {
+		return false
+	}
+
+	mt, err := h.GetMediaType()
+	if errors.Is(err, header.ErrNoSuchField) {
+		return true
+	} else if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(mt, "text/plain") || strings.EqualFold(mt, "text/html")
+}
+
 // MultipartAlternative returns a Multipart with a Content-type header set to
 // multipart/alternative and the given parts attached.
 func MultipartAlternative(parts ...Part) *Multipart {
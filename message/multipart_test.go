@@ -2,9 +2,13 @@ package message_test
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/go-email/v2/message"
 )
 
 func TestMultipart(t *testing.T) {
@@ -34,3 +38,60 @@ func TestMultipart(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, expect, out.String())
 }
+
+func TestMultipart_BodyAndAttachmentParts(t *testing.T) {
+	t.Parallel()
+
+	const src = `Content-type: multipart/mixed; boundary=outer
+
+--outer
+Content-type: multipart/alternative; boundary=inner
+
+--inner
+Content-type: text/plain
+
+Plain text.
+--inner
+Content-type: text/html
+
+<p>HTML text.</p>
+--inner--
+--outer
+Content-type: image/png
+Content-disposition: inline
+
+PNGDATA
+--outer
+Content-type: text/plain
+Content-disposition: inline; filename=notes.txt
+
+Notes.
+--outer
+Content-type: application/pdf
+Content-disposition: attachment
+
+PDFDATA
+--outer--
+`
+
+	m, err := message.Parse(strings.NewReader(src))
+	require.NoError(t, err)
+
+	mm, isMultipart := m.(*message.Multipart)
+	require.True(t, isMultipart)
+
+	mediaTypes := func(parts []message.Generic) []string {
+		mts := make([]string, len(parts))
+		for i, p := range parts {
+			mts[i], _ = p.GetHeader().GetMediaType()
+		}
+		return mts
+	}
+
+	assert.Equal(t,
+		[]string{"text/plain", "text/html"},
+		mediaTypes(mm.BodyParts()))
+	assert.Equal(t,
+		[]string{"text/plain", "application/pdf"},
+		mediaTypes(mm.AttachmentParts()))
+}
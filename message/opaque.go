@@ -1,6 +1,8 @@
 package message
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -9,6 +11,10 @@ import (
 	"github.com/zostay/go-email/v2/message/transfer"
 )
 
+// ErrBodyConsumed is returned by WriteTo() and Rewind() when the body of an
+// Opaque has already been read and cannot be read again.
+var ErrBodyConsumed = errors.New("message body has already been consumed and cannot be replayed")
+
 // Opaque is the base-level email message interface. It is simply a header
 // and a message body, very similar to the net/mail message implementation.
 type Opaque struct {
@@ -30,21 +36,81 @@ type Opaque struct {
 	// - creating an opaque with a buffer will leave this false unless the
 	// object is constructed using OpaqueAlreadyEncoded
 	encoded bool
+
+	// truncated is set when the header was cut short by the
+	// WithTruncateLongHeader() parse option
+	truncated bool
+
+	// written is set once WriteTo() has consumed the body
+	written bool
+
+	// base64LineLength records the line length of base64 content decoded by
+	// the DecodeTransferEncoding() parse option, so that WriteTo() can wrap
+	// the re-encoded content the same way
+	base64LineLength int
+
+	// raw holds the original bytes of the part when parsed with the
+	// WithRawParts() option
+	raw []byte
+}
+
+// NewOpaque constructs an Opaque from the given header and body without any
+// parsing. The encoded flag indicates whether the bytes read from body already
+// have the Content-transfer-encoding applied. If false, the body will be
+// encoded by WriteTo() as it is written. The body may be nil if the message has
+// no content.
+func NewOpaque(h *header.Header, body io.Reader, encoded bool) *Opaque {
+	return &Opaque{
+		Header:  *h,
+		Reader:  body,
+		encoded: encoded,
+	}
+}
+
+// WithBody returns a new Opaque with a copy of the header of this one, but with
+// the given body. The body is taken to be decoded (i.e., IsEncoded() returns
+// false), so the Content-transfer-encoding of the header is applied to it when
+// the new Opaque is written. This allows the content of a part to be rewritten
+// (e.g., to sanitize HTML) while keeping every header field exactly as it was,
+// including the original folding and encoding of each field. The body may be
+// nil if the part should have no content.
+//
+// The header is cloned, so changes made to the header of either Opaque are not
+// seen by the other. This Opaque is not changed.
+func (m *Opaque) WithBody(r io.Reader) *Opaque {
+	return &Opaque{
+		Header:           *m.Header.Clone(),
+		Reader:           r,
+		truncated:        m.truncated,
+		base64LineLength: m.base64LineLength,
+	}
 }
 
+// writeChunkSize is the number of bytes of the body copied at a time by
+// WriteTo.
+const writeChunkSize = 32 * 1024
+
 // WriteTo writes the Opaque header and body to the destination
 // io.Writer.
 //
 // If the bytes head in io.Reader have had the Content-transfer-encoding decoded
 // (e.g., the message was parsed with the DecodeTransferEncoding() option or was
 // created via a Buffer), then this will encode the data as it is being written.
+// The body is streamed through the encoder in fixed-size chunks, so the memory
+// used does not depend on the size of the body.
+//
+// This consumes the io.Reader. If the body is replayable (see IsReplayable()),
+// calling this again will rewind the body and write it again. Otherwise, a
+// second call will fail with ErrBodyConsumed before anything is written.
 //
-// This can only be safely called once as it will consume the io.Reader.
+// If an error occurs while copying the body, the error returned will be
+// wrapped to indicate that it happened during the body copy and the count
+// returned will include every byte written up to that point.
 func (m *Opaque) WriteTo(w io.Writer) (int64, error) {
-	var tw io.WriteCloser
-	if !m.encoded {
-		tw = transfer.ApplyTransferEncoding(&m.Header, w)
-		defer func() { _ = tw.Close() }()
+	if m.written {
+		if err := m.Rewind(); err != nil {
+			return 0, err
+		}
 	}
 
 	total, err := m.Header.WriteTo(w)
@@ -52,19 +118,103 @@ func (m *Opaque) WriteTo(w io.Writer) (int64, error) {
 		return total, err
 	}
 
-	if tw != nil {
-		w = tw
+	if m.Reader == nil {
+		return total, nil
 	}
 
-	if m.Reader != nil {
-		bn, err := io.Copy(w, m.Reader)
+	if m.encoded {
+		m.written = true
+		bn, err := copyChunks(w, m.Reader)
 		total += bn
 		if err != nil {
-			return total, err
+			return total, fmt.Errorf("error copying message body after %d bytes: %w", bn, err)
 		}
+
+		return total, nil
+	}
+
+	// the encoder does not write the same number of bytes it is given, so the
+	// bytes it writes to w are counted instead
+	cw := &countingWriter{w: w}
+	tw := m.transferEncoder(cw)
+
+	m.written = true
+	_, err = copyChunks(tw, m.Reader)
+	if err != nil {
+		_ = tw.Close()
+		return total + cw.n, fmt.Errorf("error copying message body after %d bytes: %w", cw.n, err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return total + cw.n, fmt.Errorf("error completing message body encoding: %w", err)
 	}
 
-	return total, nil
+	return total + cw.n, nil
+}
+
+// copyChunks copies r to w using a buffer of writeChunkSize bytes. Unlike
+// io.Copy, the io.WriterTo and io.ReaderFrom methods of r and w are never
+// used, so a large in-memory body is not handed to w in a single write.
+func copyChunks(w io.Writer, r io.Reader) (int64, error) {
+	return io.CopyBuffer(
+		struct{ io.Writer }{w},
+		struct{ io.Reader }{r},
+		make([]byte, writeChunkSize))
+}
+
+// transferEncoder returns the io.WriteCloser that applies the
+// Content-transfer-encoding to the body. Base64 content decoded during parsing
+// is wrapped at its original line length.
+func (m *Opaque) transferEncoder(w io.Writer) io.WriteCloser {
+	if m.base64LineLength > 0 {
+		if cte, err := m.GetTransferEncoding(); err == nil && cte == transfer.Base64 {
+			return transfer.WithBase64LineLength(m.base64LineLength)(w)
+		}
+	}
+
+	return transfer.ApplyTransferEncoding(&m.Header, w)
+}
+
+// RawBytes returns the original bytes of this part, header and body, exactly as
+// they appeared between the boundaries of the enclosing multipart message. The
+// bytes are only kept when the message is parsed with the WithRawParts()
+// option, so this returns nil otherwise, including for a top-level message and
+// any Opaque that was not created by the parser. The returned slice must not be
+// modified.
+//
+// The raw bytes are unaffected by any changes made to the Opaque after it was
+// parsed.
+func (m *Opaque) RawBytes() []byte {
+	return m.raw
+}
+
+// IsReplayable returns true if the body of the Opaque can be read more than
+// once. This is true when the body is empty or when the io.Reader is also an
+// io.Seeker, such as the *bytes.Reader used for bodies built in memory. A body
+// read from a stream, such as the top-level body of a message parsed from a
+// network connection, can only be read once.
+func (m *Opaque) IsReplayable() bool {
+	if m.Reader == nil {
+		return true
+	}
+	_, isSeeker := m.Reader.(io.Seeker)
+	return isSeeker
+}
+
+// Rewind returns the body to the beginning so it may be read again. It returns
+// ErrBodyConsumed if the body is not replayable (see IsReplayable()).
+func (m *Opaque) Rewind() error {
+	if m.Reader == nil {
+		return nil
+	}
+
+	s, isSeeker := m.Reader.(io.Seeker)
+	if !isSeeker {
+		return ErrBodyConsumed
+	}
+
+	_, err := s.Seek(0, io.SeekStart)
+	return err
 }
 
 // IsMultipart always returns false.
@@ -89,6 +239,14 @@ func (m *Opaque) IsEncoded() bool {
 	return m.encoded
 }
 
+// IsTruncated returns true if the message was parsed with the
+// WithTruncateLongHeader() option and the header was longer than the maximum
+// header length. In that case, the header only contains the fields found before
+// the limit was reached and the rest of the header is part of the body.
+func (m *Opaque) IsTruncated() bool {
+	return m.truncated
+}
+
 // GetHeader returns the header for the message.
 func (m *Opaque) GetHeader() *header.Header {
 	return &m.Header
@@ -2,13 +2,21 @@ package message_test
 
 import (
 	"bytes"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
+	"mime/quotedprintable"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/zostay/go-email/v2/message"
+	"github.com/zostay/go-email/v2/message/header"
+	"github.com/zostay/go-email/v2/message/transfer"
 )
 
 func TestOpaque(t *testing.T) {
@@ -59,10 +67,203 @@ Content-type: text/plain
 	return buf, expect + encoded, expect + decoded, err
 }
 
+func TestNewOpaque(t *testing.T) {
+	t.Parallel()
+
+	h := &header.Header{}
+	h.SetSubject("test simple")
+	h.SetTransferEncoding("quoted-printable")
+	h.SetMediaType("text/plain")
+
+	const expectHead = `Subject: test simple
+Content-transfer-encoding: quoted-printable
+Content-type: text/plain
+
+`
+
+	m := message.NewOpaque(h, strings.NewReader("I ❤ email!\n"), false)
+	assert.False(t, m.IsEncoded())
+
+	out := &bytes.Buffer{}
+	_, err := m.WriteTo(out)
+	assert.NoError(t, err)
+	assert.Equal(t, expectHead+"I =E2=9D=A4 email!\r\n", out.String())
+
+	m = message.NewOpaque(h, strings.NewReader("I =E2=9D=A4 email!\n"), true)
+	assert.True(t, m.IsEncoded())
+
+	out = &bytes.Buffer{}
+	_, err = m.WriteTo(out)
+	assert.NoError(t, err)
+	assert.Equal(t, expectHead+"I =E2=9D=A4 email!\n", out.String())
+}
+
+func TestOpaque_WriteTo_ReaderError(t *testing.T) {
+	t.Parallel()
+
+	h := &header.Header{}
+	h.SetSubject("broken stream")
+
+	hbuf := &bytes.Buffer{}
+	hn, err := h.WriteTo(hbuf)
+	require.NoError(t, err)
+
+	errBroken := errors.New("connection reset")
+	body := io.MultiReader(
+		strings.NewReader("0123456789"),
+		iotest.ErrReader(errBroken),
+	)
+
+	m := message.NewOpaque(h, body, true)
+
+	buf := &bytes.Buffer{}
+	n, err := m.WriteTo(buf)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, errBroken)
+	assert.Contains(t, err.Error(), "error copying message body after 10 bytes")
+	assert.Equal(t, hn+10, n)
+	assert.Equal(t, int64(buf.Len()), n)
+}
+
+// failingWriter is an io.Writer that accepts limit bytes and then fails with
+// err.
+type failingWriter struct {
+	buf   bytes.Buffer
+	limit int64
+	err   error
+}
+
+func (fw *failingWriter) Write(p []byte) (int, error) {
+	if room := fw.limit - int64(fw.buf.Len()); int64(len(p)) > room {
+		n, _ := fw.buf.Write(p[:room])
+		return n, fw.err
+	}
+	return fw.buf.Write(p)
+}
+
+func TestOpaque_WriteTo_WriterError(t *testing.T) {
+	t.Parallel()
+
+	h := &header.Header{}
+	h.SetSubject("broken stream")
+	h.SetTransferEncoding(transfer.Base64)
+
+	hbuf := &bytes.Buffer{}
+	hn, err := h.WriteTo(hbuf)
+	require.NoError(t, err)
+
+	m := message.NewOpaque(h, bytes.NewReader(largeBody(10_000)), false)
+
+	// the count is of the encoded bytes written, not the decoded bytes read
+	errBroken := errors.New("connection reset")
+	fw := &failingWriter{limit: hn + 100, err: errBroken}
+	n, err := m.WriteTo(fw)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, errBroken)
+	assert.Equal(t, hn+100, n)
+	assert.Equal(t, int64(fw.buf.Len()), n)
+}
+
+func TestOpaque_WithBody(t *testing.T) {
+	t.Parallel()
+
+	const src = "Subject:   keep   me\n" +
+		"Content-type: text/html;\n charset=utf-8\n" +
+		"Content-transfer-encoding: quoted-printable\n" +
+		"\n" +
+		"<script>x=3D1</script><p>Caf=C3=A9</p>"
+
+	m, err := message.Parse(strings.NewReader(src))
+	require.NoError(t, err)
+	op := m.(*message.Opaque)
+	require.True(t, op.IsEncoded())
+
+	nop := op.WithBody(strings.NewReader("<p>Café</p>"))
+	assert.False(t, nop.IsEncoded())
+
+	buf := &bytes.Buffer{}
+	_, err = nop.WriteTo(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "Subject:   keep   me\n"+
+		"Content-type: text/html;\n charset=utf-8\n"+
+		"Content-transfer-encoding: quoted-printable\n"+
+		"\n"+
+		"<p>Caf=C3=A9</p>", buf.String())
+
+	// the headers are not shared
+	nop.SetSubject("changed")
+	subj, err := op.GetSubject()
+	assert.NoError(t, err)
+	assert.Equal(t, "keep   me", subj)
+	assert.True(t, op.IsEncoded())
+
+	empty := op.WithBody(nil)
+	buf.Reset()
+	_, err = empty.WriteTo(buf)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(buf.String(), "quoted-printable\n\n"))
+}
+
+func TestOpaque_WriteTo_Replay(t *testing.T) {
+	t.Parallel()
+
+	h := &header.Header{}
+	h.SetSubject("again")
+
+	m := message.NewOpaque(h, bytes.NewReader([]byte("replay me\n")), true)
+	assert.True(t, m.IsReplayable())
+
+	first := &bytes.Buffer{}
+	_, err := m.WriteTo(first)
+	require.NoError(t, err)
+
+	second := &bytes.Buffer{}
+	_, err = m.WriteTo(second)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Subject: again\n\nreplay me\n", first.String())
+	assert.Equal(t, first.String(), second.String())
+}
+
+func TestOpaque_WriteTo_ParsedReplay(t *testing.T) {
+	t.Parallel()
+
+	const src = "Subject: parsed\n\nparsed body\n"
+	m, err := message.Parse(strings.NewReader(src))
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		buf := &bytes.Buffer{}
+		_, err = m.WriteTo(buf)
+		require.NoError(t, err)
+		assert.Equal(t, src, buf.String())
+	}
+}
+
+func TestOpaque_WriteTo_Consumed(t *testing.T) {
+	t.Parallel()
+
+	h := &header.Header{}
+	h.SetSubject("once")
+
+	m := message.NewOpaque(h, io.MultiReader(strings.NewReader("only once\n")), true)
+	assert.False(t, m.IsReplayable())
+
+	_, err := m.WriteTo(io.Discard)
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	n, err := m.WriteTo(buf)
+	assert.ErrorIs(t, err, message.ErrBodyConsumed)
+	assert.Equal(t, int64(0), n)
+	assert.Equal(t, 0, buf.Len())
+	assert.ErrorIs(t, m.Rewind(), message.ErrBodyConsumed)
+}
+
 func TestOpaque_TransferEncodingEncoded(t *testing.T) {
 	t.Parallel()
 
-	buf, expectEnc, expectDec, err := makeSimpleWithEncoding()
+	buf, expectEnc, _, err := makeSimpleWithEncoding()
 	assert.NoError(t, err)
 
 	m := buf.Opaque()
@@ -78,11 +279,9 @@ func TestOpaque_TransferEncodingEncoded(t *testing.T) {
 	assert.False(t, m.IsMultipart())
 	assert.False(t, m.IsEncoded())
 
-	// TODO the discrepancy between bytes written and n returned from WriteTo feels like a bug
-
 	out := &bytes.Buffer{}
 	n, err := m.WriteTo(out)
-	assert.Equal(t, int64(len(expectDec)), n)
+	assert.Equal(t, int64(len(expectEnc)), n)
 	assert.NoError(t, err)
 	assert.Equal(t, expectEnc, out.String())
 }
@@ -159,7 +358,100 @@ lC9jFgBJMyYCCCgRMODoseFElx0tCvxYIEAAAwkWRggIADs=`
 
 	buf := &bytes.Buffer{}
 	n, err := af.WriteTo(buf)
-	assert.Equal(t, int64(len(headerPart)+890), n)
+	assert.Equal(t, int64(len(headerPart)+len(attPart)), n)
 	assert.NoError(t, err)
 	assert.Equal(t, []byte(headerPart+attPart), buf.Bytes())
 }
+
+// largeBody returns n bytes of text with long lines and some bytes that must
+// be quoted-printable encoded.
+func largeBody(n int) []byte {
+	line := []byte(strings.Repeat("caf\xc3\xa9 = menu ", 20) + "\n")
+	return bytes.Repeat(line, n/len(line)+1)[:n]
+}
+
+func TestOpaque_WriteTo_LargeBody(t *testing.T) {
+	t.Parallel()
+
+	body := largeBody(300 * 1024)
+
+	t.Run("base64", func(t *testing.T) {
+		t.Parallel()
+
+		h := &header.Header{}
+		h.Set(header.ContentTransferEncoding, "base64")
+
+		expect := &bytes.Buffer{}
+		_, err := h.WriteTo(expect)
+		require.NoError(t, err)
+		enc := base64.StdEncoding.EncodeToString(body)
+		for len(enc) > 76 {
+			expect.WriteString(enc[:76] + "\n")
+			enc = enc[76:]
+		}
+		expect.WriteString(enc)
+
+		out := &bytes.Buffer{}
+		_, err = message.NewOpaque(h, bytes.NewReader(body), false).WriteTo(out)
+		assert.NoError(t, err)
+		assert.Equal(t, expect.String(), out.String())
+	})
+
+	t.Run("quoted-printable", func(t *testing.T) {
+		t.Parallel()
+
+		h := &header.Header{}
+		h.Set(header.ContentTransferEncoding, "quoted-printable")
+
+		expect := &bytes.Buffer{}
+		_, err := h.WriteTo(expect)
+		require.NoError(t, err)
+		qpw := quotedprintable.NewWriter(expect)
+		_, err = qpw.Write(body)
+		require.NoError(t, err)
+		require.NoError(t, qpw.Close())
+
+		out := &bytes.Buffer{}
+		_, err = message.NewOpaque(h, bytes.NewReader(body), false).WriteTo(out)
+		assert.NoError(t, err)
+		assert.Equal(t, expect.String(), out.String())
+	})
+}
+
+// repeatReader endlessly repeats its bytes without holding more of them in
+// memory.
+type repeatReader struct {
+	b   []byte
+	off int
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		c := copy(p[n:], r.b[r.off:])
+		n += c
+		r.off = (r.off + c) % len(r.b)
+	}
+	return n, nil
+}
+
+// BenchmarkOpaque_WriteTo_Base64 encodes a 50MB body to show that the memory
+// used by WriteTo does not grow with the size of the body.
+func BenchmarkOpaque_WriteTo_Base64(b *testing.B) {
+	const size = 50 * 1024 * 1024
+
+	h := &header.Header{}
+	h.Set(header.ContentType, "application/octet-stream")
+	h.Set(header.ContentTransferEncoding, "base64")
+
+	b.SetBytes(size)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		body := io.LimitReader(&repeatReader{b: largeBody(4096)}, size)
+		_, err := message.NewOpaque(h, body, false).WriteTo(io.Discard)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
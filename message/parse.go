@@ -6,10 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/zostay/go-email/v2/internal/scanner"
 	"github.com/zostay/go-email/v2/message/header"
 	"github.com/zostay/go-email/v2/message/header/field"
+	"github.com/zostay/go-email/v2/message/header/param"
 	"github.com/zostay/go-email/v2/message/transfer"
 )
 
@@ -43,8 +45,109 @@ var (
 	// ErrLargePart is returned by Parse when  apart is longer than the configured
 	// WithMaxPartLength option (or the default, DefaultMaxPartLength).
 	ErrLargePart = errors.New("a message part exceeds the maximum parse length")
+
+	// ErrParseBudgetExceeded is returned by Parse when the number of parts
+	// visited exceeds the limit set by the WithMaxTotalDepthWork option.
+	ErrParseBudgetExceeded = errors.New("the message exceeds the parse work budget")
 )
 
+// ParseError is returned by Parse to describe where in the message
+// ErrLargeHeader or ErrLargePart occurred. Use errors.Is to check for the
+// underlying error and errors.As to get at the details.
+type ParseError struct {
+	// Err is the error that occurred, such as ErrLargeHeader.
+	Err error
+
+	// Path holds the index of the part at each level of nesting, starting with
+	// the top-level message, down to the part where the error occurred. It is
+	// empty if the error occurred in the top-level message.
+	Path []int
+
+	// Offset is the byte offset in the input where the part in which the error
+	// occurred begins.
+	Offset int64
+
+	// Boundary is the boundary of the multipart message enclosing the part
+	// where the error occurred. It is empty if the error occurred in the
+	// top-level message.
+	Boundary string
+}
+
+// Error returns the error message, including where the error occurred.
+func (e *ParseError) Error() string {
+	if len(e.Path) == 0 {
+		return fmt.Sprintf("%v in the top-level message at offset %d", e.Err, e.Offset)
+	}
+
+	var path strings.Builder
+	for _, ix := range e.Path {
+		fmt.Fprintf(&path, "[%d]", ix)
+	}
+
+	return fmt.Sprintf("%v at part %s at offset %d with boundary %q",
+		e.Err, path.String(), e.Offset, e.Boundary)
+}
+
+// Unwrap returns the error that occurred.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// partLocation locates the part being parsed within the input so that a
+// ParseError can report where an error occurred.
+type partLocation struct {
+	// path is the index of the part at each level of nesting
+	path []int
+
+	// bodyOffset is the byte offset of the body of the part in the input
+	bodyOffset int64
+
+	// boundaries holds the boundary of each multipart enclosing the part
+	boundaries []string
+}
+
+// child returns the location of the ith sub-part whose body begins at the
+// given offset.
+func (loc partLocation) child(i int, bodyOffset int64) partLocation {
+	path := make([]int, len(loc.path)+1)
+	copy(path, loc.path)
+	path[len(loc.path)] = i
+	return partLocation{path, bodyOffset, loc.boundaries}
+}
+
+// name describes the part at this location for use in a violation, e.g.,
+// "message part 1 part 2".
+func (loc partLocation) name() string {
+	var name strings.Builder
+	name.WriteString("message")
+	for _, ix := range loc.path {
+		fmt.Fprintf(&name, " part %d", ix+1)
+	}
+	return name.String()
+}
+
+// parseError wraps err in a ParseError for the ith sub-part of this location,
+// which begins at the given offset in the input and is separated by the given
+// boundary. Errors other than ErrLargeHeader and ErrLargePart are returned
+// as-is.
+func (loc partLocation) parseError(err error, i int, offset int64, boundary string) error {
+	if !errors.Is(err, ErrLargeHeader) && !errors.Is(err, ErrLargePart) {
+		return err
+	}
+
+	var pe *ParseError
+	if errors.As(err, &pe) {
+		return err
+	}
+
+	return &ParseError{
+		Err:      err,
+		Path:     loc.child(i, 0).path,
+		Offset:   offset,
+		Boundary: boundary,
+	}
+}
+
 var splits = [][]byte{
 	[]byte("\x0d\x0a\x0d\x0a"), // \r\n\r\n
 	[]byte("\x0a\x0d\x0a\x0d"), // \n\r\n\r, extremely unlikely, possibly never
@@ -58,6 +161,26 @@ type parser struct {
 	maxDepth     int
 	chunkSize    int
 	decode       bool
+	decompress   bool
+	canonical    bool
+	tolerant     bool
+	stopAt       func(*header.Header) bool
+	truncate     bool
+	maxWork      int
+	keepRaw      bool
+	lazy         bool
+	checkBounds  bool
+
+	// violations holds each boundary found in content by WithBoundaryCheck
+	violations []string
+
+	// stopped is set once a part matching stopAt is found or the work budget
+	// is exhausted
+	stopped bool
+
+	// work counts the parts visited, overBudget is set once it exceeds maxWork
+	work       int
+	overBudget bool
 }
 
 func (pr *parser) clone() *parser {
@@ -102,10 +225,61 @@ func WithMaxPartLength(n int) ParseOption {
 // Content-transfer-encoding. By default, Content-transfer-encoding will not be
 // decoded, which allows for safer round-tripping of messages. However, if you
 // want to display or process the message body, you will want to enable this.
+//
+// The line length of base64 content is recorded as it is decoded, so that the
+// content will be wrapped the same way when it is encoded again by WriteTo().
 func DecodeTransferEncoding() ParseOption {
 	return func(pr *parser) { pr.decode = true }
 }
 
+// WithContentEncodingDecoding is a ParseOption that enables decompression of
+// parts that carry a Content-encoding header. This header is not part of the
+// MIME standard (it is borrowed from HTTP), but some systems put it on message
+// parts anyway. When a part has a Content-encoding of gzip (or x-gzip) or
+// deflate, the body of the part will be decompressed after the
+// Content-transfer-encoding is decoded. Any other Content-encoding is left
+// untouched.
+//
+// As decompression must happen after transfer decoding, this option implies
+// DecodeTransferEncoding(). The Content-encoding field is removed from the
+// header of every part that is decompressed so that the header continues to
+// describe the body correctly when it is written back out.
+func WithContentEncodingDecoding() ParseOption {
+	return func(pr *parser) {
+		pr.decode = true
+		pr.decompress = true
+	}
+}
+
+// WithCanonicalFieldNames is a ParseOption that rewrites the name of every
+// parsed header field into canonical form, as returned by
+// field.Field.CanonicalName() (e.g., "content-TYPE" becomes "Content-Type").
+// The bodies of the fields, including any folding, are left exactly as they
+// were.
+//
+// This means the message will no longer round-trip byte-for-byte if any field
+// name was not already in canonical form. It is intended for feeding
+// normalized headers into storage or other downstream systems. To display
+// field names consistently without changing the output, call CanonicalName()
+// on each field instead.
+func WithCanonicalFieldNames() ParseOption {
+	return func(pr *parser) { pr.canonical = true }
+}
+
+// WithTruncateLongHeader is a ParseOption that changes how the parser handles
+// a header longer than the WithMaxHeaderLength() setting (or the default,
+// DefaultMaxHeaderLength). Rather than failing with ErrLargeHeader, the parser
+// will stop accumulating the header at the limit, parse the header fields found
+// before the last line break within the limit, and treat the remaining bytes as
+// the body of the message.
+//
+// When this happens, the returned message part will be an *Opaque with
+// IsTruncated() returning true. As the header is incomplete, a part with a
+// truncated header is never parsed as a multipart.
+func WithTruncateLongHeader() ParseOption {
+	return func(pr *parser) { pr.truncate = true }
+}
+
 // WithChunkSize is a ParseOption that controls how many bytes to read at a time
 // while parsing an email message. The default chunk size is DefaultChunkSize.
 func WithChunkSize(chunkSize int) ParseOption {
@@ -119,6 +293,106 @@ func WithMaxDepth(maxDepth int) ParseOption {
 	return func(pr *parser) { pr.maxDepth = maxDepth }
 }
 
+// WithMaxTotalDepthWork is a ParseOption that sets a work budget for parsing
+// untrusted input. The budget is the total number of parts the parser may
+// visit, counting the message itself and every sub-part at every level of
+// nesting. This bounds the product of depth and breadth, which protects against
+// messages that nest many tiny multiparts within each other to amplify the
+// cost of parsing. Setting n to 0 or less removes the budget, which is the
+// default.
+//
+// Once the budget is used up, parsing stops in the same way as WithStopAt():
+// the part that exceeded the budget is returned unparsed as an *Opaque, the
+// parts already parsed are returned as a partial tree, and the remaining input
+// is retained unparsed so the message still round-trips via WriteTo(). Parse
+// returns the partial tree along with ErrParseBudgetExceeded.
+//
+// This works alongside the other limits, and whichever limit is reached first
+// takes effect. WithMaxDepth() limits how deep the parser descends, but parts
+// found at the maximum depth still count toward the budget as they are
+// visited. WithMaxPartLength() and WithMaxHeaderLength() limit the size of
+// each individual part, but not how many parts there are.
+func WithMaxTotalDepthWork(n int) ParseOption {
+	return func(pr *parser) { pr.maxWork = n }
+}
+
+// WithRawParts is a ParseOption that keeps a copy of the original bytes of
+// every leaf part of a multipart message (i.e., every part parsed into an
+// *Opaque), which are then returned by the RawBytes() method of the part. The
+// bytes are exactly those that appeared between the boundaries, including the
+// header and body of the part, so they may be hashed or forwarded without any
+// re-encoding.
+//
+// Be aware that this roughly doubles the memory used to hold a parsed message
+// as the content of every leaf part is held twice, once in the raw copy and
+// once for the io.Reader of the part. The raw copy is kept for as long as the
+// part is.
+func WithRawParts() ParseOption {
+	return func(pr *parser) { pr.keepRaw = true }
+}
+
+// WithTolerantBoundaries is a ParseOption that allows the multipart boundaries
+// of a message to be matched regardless of the line break surrounding them.
+// Normally, the parser expects the line breaks around each boundary to match
+// the line break detected while parsing the header. With this option, either
+// CRLF or LF will be accepted before and after each "--boundary" line.
+//
+// This helps with messages that mix line endings (e.g., CRLF in the header,
+// but LF in the body). Be aware that when such a message is written back out,
+// the boundaries will be written using the line break of the header, so the
+// output may not match the input byte-for-byte.
+//
+// This option also accepts a boundary followed by trailing spaces or tabs
+// (transport padding), a boundary indented with leading spaces or tabs, and a
+// boundary that follows content on the same line, with no line break before
+// it. None of these are kept, so they are also lost when the message is written
+// back out.
+func WithTolerantBoundaries() ParseOption {
+	return func(pr *parser) { pr.tolerant = true }
+}
+
+// WithBoundaryCheck is a ParseOption that checks the content of every leaf part
+// of a multipart message for the boundary delimiter of any multipart enclosing
+// it while the message is split into parts. This is the same check performed by
+// ValidateBoundaries(), but it is done on the bytes already held by the parser,
+// so no part needs to be read again afterward. The check is made on the content
+// after the Content-transfer-encoding is decoded, whether or not
+// DecodeTransferEncoding() is set.
+//
+// The message is parsed as usual. If a boundary is found in any part, Parse
+// returns the message along with a *BoundaryInContentError describing each
+// violation, which a gateway may treat as a warning or reject the message.
+//
+// With WithLazyParts(), each part is checked as it is split off, before its
+// header is parsed, so only the raw bytes of its body are checked and the parts
+// nested within it are not checked at all.
+func WithBoundaryCheck() ParseOption {
+	return func(pr *parser) { pr.checkBounds = true }
+}
+
+// WithStopAt is a ParseOption that will stop the parser as soon as a part is
+// found whose header satisfies the given predicate. The matching part will not
+// be parsed any further (i.e., if it is a multipart, it will be returned as an
+// *Opaque). Any parts following the matching part will not be parsed at all.
+//
+// The parts already parsed will be returned as a partial tree. The bytes that
+// were not parsed are retained as-is, unparsed, by each enclosing *Multipart
+// so that the message still round-trips when written via WriteTo(). Those
+// unparsed parts will not be returned by GetParts().
+//
+// For example, to find the first text/plain part for a preview:
+//
+//	msg, err := message.Parse(r, message.WithStopAt(func(h *header.Header) bool {
+//	  mt, _ := h.GetMediaType()
+//	  return mt == "text/plain"
+//	}))
+//
+// Combined with WithMaxPartLength(), this bounds the amount of work done for
+// large messages.
+func WithStopAt(stopAt func(*header.Header) bool) ParseOption {
+	return func(pr *parser) { pr.stopAt = stopAt }
+}
+
 // WithoutMultipart is a ParseOption that will not allow parsing of any
 // multipart messages. The message returned from Parse() will always be *Opaque.
 //
@@ -161,13 +435,16 @@ func searchForSplit(buf []byte, subpart bool) (pos int, crlf []byte) {
 		}
 	}
 
-	// Find the split between header/body
+	// Find the split between header/body. The earliest split found wins
+	// because the body may contain any of the others, such as a binary body
+	// containing "\r\n\r\n" after a header using "\n".
 	pos = -1
+	first := -1
 	for _, s := range splits {
-		if testPos := bytes.Index(buf, s); testPos > -1 {
+		if testPos := bytes.Index(buf, s); testPos > -1 && (first < 0 || testPos < first) {
+			first = testPos
 			pos = testPos + len(s)
 			crlf = s[0 : len(s)/2]
-			return
 		}
 	}
 	return
@@ -176,8 +453,9 @@ func searchForSplit(buf []byte, subpart bool) (pos int, crlf []byte) {
 // splitHeadFromBody will pull the header off the front of the given input
 // splitHeadFromBody will detect the index of the split between the message
 // header and the message body as well as the line break the email is using. It
-// returns both.
-func (pr *parser) splitHeadFromBody(r io.Reader, subpart bool) ([]byte, []byte, io.Reader, error) {
+// returns both. It also returns whether the header was truncated by the
+// WithTruncateLongHeader() option.
+func (pr *parser) splitHeadFromBody(r io.Reader, subpart bool) ([]byte, []byte, io.Reader, bool, error) {
 	p := make([]byte, pr.chunkSize)
 	buf := &bytes.Buffer{}
 	searched := 0
@@ -186,33 +464,43 @@ func (pr *parser) splitHeadFromBody(r io.Reader, subpart bool) ([]byte, []byte,
 		n, err := r.Read(p)
 
 		// check to see if the header is too long
-		if pr.maxHeaderLen > 0 && n+buf.Len() > pr.maxHeaderLen {
-			return nil, nil, nil, ErrLargeHeader
+		tooLong := pr.maxHeaderLen > 0 && n+buf.Len() > pr.maxHeaderLen
+		if tooLong && !pr.truncate {
+			return nil, nil, nil, false, ErrLargeHeader
 		}
 
 		isEof := false
 		if errors.Is(err, io.EOF) {
 			isEof = true
 		} else if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, false, err
 		}
 
 		// add that to our buffer
 		_, err = buf.Write(p[:n])
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, false, err
 		}
 
 		// check the tail of the buffer for end of header
 		pos, crlf := searchForSplit(buf.Bytes()[searched:], subpart)
 		if pos >= 0 {
 			pos += searched
+		}
+
+		// the header is too long and we've been asked to truncate it
+		if tooLong && (pos < 0 || pos > pr.maxHeaderLen) {
+			hdr, crlf, body := pr.truncateHead(buf.Bytes(), r)
+			return hdr, crlf, body, true, nil
+		}
+
+		if pos >= 0 {
 			// we found the split, header is bytes up to the split
 			hdr := make([]byte, pos)
 			for hdrRead, n := 0, 0; hdrRead < pos; hdrRead += n {
 				n, err = buf.Read(hdr[hdrRead:])
 				if err != nil {
-					return nil, nil, nil, err
+					return nil, nil, nil, false, err
 				}
 			}
 
@@ -225,7 +513,7 @@ func (pr *parser) splitHeadFromBody(r io.Reader, subpart bool) ([]byte, []byte,
 				// the end of the byte.Buffer we've been building.
 				_, err = buf.ReadFrom(r)
 				if err != nil {
-					return nil, nil, nil, err
+					return nil, nil, nil, false, err
 				}
 				// Without this, the header bytes will still be in the buffer.
 				// This will cause those bytes to be discarded, which will
@@ -236,9 +524,9 @@ func (pr *parser) splitHeadFromBody(r io.Reader, subpart bool) ([]byte, []byte,
 				// as we must be reading an original input io.Reader. By not
 				// consuming it, we can improve the memory performance of
 				// Opaque message.
-				body = &remainder{buf.Bytes(), r}
+				body = newRemainder(buf.Bytes(), r)
 			}
-			return hdr, crlf, body, nil
+			return hdr, crlf, body, false, nil
 		}
 
 		// No split found and EOF? Let's break out and then we'll process as if
@@ -260,35 +548,190 @@ func (pr *parser) splitHeadFromBody(r io.Reader, subpart bool) ([]byte, []byte,
 	for _, s := range splits {
 		crlf := s[0 : len(s)/2]
 		if bytes.Contains(buf.Bytes(), crlf) {
-			return buf.Bytes(), crlf, nil, nil
+			return buf.Bytes(), crlf, nil, false, nil
 		}
 	}
 
 	// Or the ultimate fallback is...
-	return buf.Bytes(), []byte("\x0d"), nil, nil
+	return buf.Bytes(), []byte("\x0d"), nil, false, nil
+}
+
+// truncateHead is used by splitHeadFromBody when the header is too long and
+// the WithTruncateLongHeader() option is set. The header is cut after the last
+// line break found within the maximum header length and the rest of the bytes
+// read so far are put back in front of the unread input to make the body.
+func (pr *parser) truncateHead(data []byte, r io.Reader) ([]byte, []byte, io.Reader) {
+	cut := pr.maxHeaderLen
+	crlf := []byte("\x0d")
+	for _, s := range splits {
+		lb := s[0 : len(s)/2]
+		if ix := bytes.LastIndex(data[:pr.maxHeaderLen], lb); ix >= 0 {
+			cut = ix + len(lb)
+			crlf = lb
+			break
+		}
+	}
+
+	return data[:cut], crlf, newRemainder(data[cut:], r)
 }
 
-// parseOpaque turns a reader into an Opaque.
-func (pr *parser) parseToOpaque(r io.Reader, subpart bool) (*Opaque, error) {
-	hdr, crlf, body, err := pr.splitHeadFromBody(r, subpart)
+// parseOpaque turns a reader into an Opaque. It also returns the length of the
+// header in bytes, including the blank line separating it from the body.
+func (pr *parser) parseToOpaque(r io.Reader, subpart bool) (*Opaque, int, error) {
+	hdr, crlf, body, truncated, err := pr.splitHeadFromBody(r, subpart)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+
+	if ix := headerBoundaryIndex(hdr, crlf); ix >= 0 {
+		// the first boundary is stuck to the header, so give the header
+		// everything before it and the body everything from it on
+		body = io.MultiReader(bytes.NewReader(hdr[ix:]), body)
+		hdr = hdr[:ix]
 	}
 
 	head, err := header.Parse(hdr, header.Break(crlf))
+
 	var badStartErr *field.BadStartError // recoverable
 	var finalErr error
 	if errors.As(err, &badStartErr) {
 		finalErr = badStartErr
 	} else if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+
+	if pr.canonical {
+		canonicalizeFieldNames(head)
 	}
 
+	var b64LineLength int
 	if pr.decode {
+		if cte, err := head.GetTransferEncoding(); err == nil && cte == transfer.Base64 {
+			b64LineLength, body = peekLineLength(body)
+		}
 		body = transfer.ApplyTransferDecoding(head, body)
 	}
 
-	return &Opaque{*head, body, !pr.decode}, finalErr
+	if pr.decompress {
+		body = applyContentDecoding(head, body)
+	}
+
+	return &Opaque{
+		Header:           *head,
+		Reader:           body,
+		encoded:          !pr.decode,
+		truncated:        truncated,
+		base64LineLength: b64LineLength,
+	}, len(hdr), finalErr
+}
+
+// headerBoundaryIndex checks for a broken multipart message where the first
+// boundary follows the header without the blank line between them, so the
+// boundary and whatever follows up to the next blank line were read as part of
+// the header. It looks for the first line starting with "--" that is not a
+// header field and checks that it is the boundary named by the Content-type of
+// the header before it. It returns the index of that line in hdr or -1 if
+// there is none.
+func headerBoundaryIndex(hdr, crlf []byte) int {
+	ix := -1
+	var line []byte
+	for off := 0; off < len(hdr); {
+		next := bytes.Index(hdr[off:], crlf)
+		if next < 0 {
+			line = hdr[off:]
+		} else {
+			line = hdr[off : off+next]
+		}
+
+		if off > 0 && bytes.HasPrefix(line, []byte("--")) &&
+			!bytes.Contains(line, []byte(":")) {
+			ix = off
+			break
+		}
+
+		if next < 0 {
+			break
+		}
+		off += next + len(crlf)
+	}
+
+	if ix < 0 {
+		return -1
+	}
+
+	head, _ := header.Parse(hdr[:ix], header.Break(crlf))
+	if head == nil {
+		return -1
+	}
+
+	f := head.GetFieldNamed(header.ContentType, 0)
+	if f == nil {
+		return -1
+	}
+
+	pv, err := param.Parse(f.Body())
+	if err != nil || pv.Type() != "multipart" || pv.Boundary() == "" {
+		return -1
+	}
+
+	line = bytes.TrimRight(line, " \t")
+	line = bytes.TrimPrefix(line, []byte("--"+pv.Boundary()))
+	if len(line) > 0 && !bytes.Equal(line, []byte("--")) {
+		return -1
+	}
+
+	return ix
+}
+
+// maxPeekLineLength is the most that peekLineLength will look ahead to find
+// the end of the first line, which is enough for the longest permitted line.
+const maxPeekLineLength = 1000
+
+// peekLineLength returns the length of the first line of r, without its line
+// break, along with an io.Reader that will still read all of r. It returns 0
+// for the length if r is empty or the first line is too long to find.
+func peekLineLength(r io.Reader) (int, io.Reader) {
+	if r == nil {
+		return 0, r
+	}
+
+	br := bufio.NewReaderSize(r, maxPeekLineLength)
+	data, err := br.Peek(maxPeekLineLength)
+	if ix := bytes.IndexByte(data, '\n'); ix >= 0 {
+		return len(bytes.TrimRight(data[:ix], "\r")), br
+	}
+
+	// the whole body is a single line
+	if err != nil {
+		return len(data), br
+	}
+
+	return 0, br
+}
+
+// canonicalizeFieldNames rewrites the name of each field in the header into
+// canonical form while keeping the original bytes of each body.
+func canonicalizeFieldNames(h *header.Header) {
+	for _, f := range h.ListFields() {
+		name := strings.TrimSpace(f.Name())
+		canon := f.CanonicalName()
+		if canon == name {
+			continue
+		}
+
+		var raw []byte
+		if f.Raw != nil {
+			rawName := f.Raw.Name()
+			raw = append(
+				[]byte(strings.Replace(rawName, name, canon, 1)),
+				f.Raw.Bytes()[len(rawName):]...)
+		}
+
+		f.SetName(canon)
+		if raw != nil {
+			f.SetRaw(raw)
+		}
+	}
 }
 
 // Parse will consume input from the given reader and return a Generic message
@@ -305,6 +748,13 @@ func (pr *parser) parseToOpaque(r io.Reader, subpart bool) (*Opaque, error) {
 // The last part of the final chunk read and the remainder of the io.Reader will
 // then make up the body content of an *Opaque message.
 //
+// Some broken mail generators put the first boundary of a multipart message
+// directly after the header, with no blank line between them. When the header
+// of a multipart message contains a line matching its own boundary, the header
+// is ended just before that line and the rest is treated as the body. Such a
+// message will not round-trip byte-for-byte, as a blank line will be written
+// after the header.
+//
 // If accumulated header chunks total larger than the WithMaxHeaderLength()
 // option (or the default, DefaultMaxHeaderLength) while searching for the
 // double line break, the Parse will fail with an error and return
@@ -345,7 +795,13 @@ func (pr *parser) parseToOpaque(r io.Reader, subpart bool) (*Opaque, error) {
 //
 // Errors at any point in the process may lead to a completely failed parse,
 // especially those involving ErrLargeHeader or ErrLargePart. However, whenever
-// possible, the partially parsed message object will be returned.
+// possible, the partially parsed message object will be returned. Those two
+// errors are returned wrapped in a *ParseError, which reports the part, offset,
+// and boundary where the error occurred.
+//
+// With the WithBoundaryCheck() option, a fully parsed message is returned with a
+// *BoundaryInContentError if the content of any part contains an enclosing
+// boundary.
 //
 // The original io.Reader provided may or may not be completely read upon
 // return. This is true whether an error has occurred or not. If you either read
@@ -357,37 +813,117 @@ func Parse(r io.Reader, opts ...ParseOption) (Generic, error) {
 		opt(pr)
 	}
 
-	msg, err := pr.parseToOpaque(r, false)
-	if err != nil {
+	msg, hdrLen, err := pr.parseToOpaque(r, false)
+	if errors.Is(err, ErrLargeHeader) {
+		return msg, &ParseError{Err: err, Path: []int{}}
+	} else if err != nil {
 		return msg, err
 	}
 
-	return pr.parse(msg, 0)
+	gmsg, err := pr.parse(msg, 0, partLocation{path: []int{}, bodyOffset: int64(hdrLen)})
+	if err == nil && pr.overBudget {
+		err = ErrParseBudgetExceeded
+	}
+
+	if err == nil && len(pr.violations) > 0 {
+		err = &BoundaryInContentError{pr.violations}
+	}
+
+	return gmsg, err
+}
+
+// ParseHeaderOnly parses only the header of the message read from r and returns
+// it along with the offset of the first byte of the body. The offset is
+// absolute, so it is the position in r where the header started plus the length
+// of the header, including the blank line that ends it. Afterward, r is left
+// positioned at the start of the body, which is never read. This is useful for
+// building an index into large messages stored on disk.
+//
+// The options are the same as those given to Parse, though only those that
+// affect the header, such as WithMaxHeaderLength(), have any effect. If the
+// message has no body, the offset is the end of the input.
+//
+// It returns ErrLargeHeader wrapped in a *ParseError if the header is too long.
+// If the header has a recoverable parse problem, the header and offset are
+// returned with a *field.BadStartError. It returns an error if r cannot be
+// read or cannot seek.
+func ParseHeaderOnly(r io.ReadSeeker, opts ...ParseOption) (*header.Header, int64, error) {
+	pr := defaultParser.clone()
+	for _, opt := range opts {
+		opt(pr)
+	}
+
+	start, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	msg, hdrLen, err := pr.parseToOpaque(r, false)
+	if errors.Is(err, ErrLargeHeader) {
+		return nil, 0, &ParseError{Err: err, Path: []int{}}
+	} else if msg == nil {
+		return nil, 0, err
+	}
+
+	offset := start + int64(hdrLen)
+	if _, serr := r.Seek(offset, io.SeekStart); serr != nil {
+		return nil, 0, serr
+	}
+
+	return &msg.Header, offset, err
 }
 
-// parse implements the Parse methods.
-func (pr *parser) parse(msg *Opaque, depth int) (Generic, error) {
+// parse implements the Parse methods. The location of the message in the input
+// is used to report errors.
+func (pr *parser) parse(msg *Opaque, depth int, loc partLocation) (Generic, error) {
+	// we've done all the work we're allowed: stop here and return the original
+	pr.work++
+	if pr.maxWork > 0 && pr.work > pr.maxWork {
+		pr.stopped = true
+		pr.overBudget = true
+		return msg, nil
+	}
+
 	// we're too deep: stop here and just return the original
 	if pr.maxDepth >= 0 && depth >= pr.maxDepth {
 		return msg, nil
 	}
 
+	// the header is incomplete, so we cannot trust it to tell us what this is
+	if msg.truncated {
+		return msg, nil
+	}
+
+	// we found the part we were looking for: stop here and return the original
+	if pr.stopAt != nil && pr.stopAt(&msg.Header) {
+		pr.stopped = true
+		return msg, nil
+	}
+
 	// lookup the Content-type header
 	pv, err := msg.GetParamValue(header.ContentType)
 	if err != nil {
 		return msg, nil
 	}
 
-	// if this is not a multipart, don't parse it
+	// if this is not a multipart, don't parse it; a malformed media type with
+	// no subtype (e.g., just "multipart") is not treated as multipart either
 	if pv.Type() != "multipart" && pv.Type() != "message" {
 		return msg, nil
 	}
 
+	if pv.Subtype() == "" {
+		return msg, nil
+	}
+
 	// if the boundary is missing, don't parse it and return an error
 	if pv.Boundary() == "" {
 		return msg, nil
 	}
 
+	// the parts found are enclosed by this boundary as well
+	loc.boundaries = append(loc.boundaries[:len(loc.boundaries):len(loc.boundaries)], pv.Boundary())
+
 	// The initial boundaries are like --boundary and final boundary is like
 	// --boundary-- and these must be on their own line. This means that every
 	// boundary but the very first must begin with a newline, but the first
@@ -402,10 +938,25 @@ func (pr *parser) parse(msg *Opaque, depth int) (Generic, error) {
 	// suffix. The newlines before and after the middle boundaries belong to the
 	// boundary and are not included with the part (because they have to be
 	// there or message parsing does not work).
+	//
+	// When the tolerant option is set, any of the line breaks may be used
+	// around the boundary, so we build every variation and search for the
+	// earliest match among them.
+	brs := [][]byte{msg.Break().Bytes()}
+	if pr.tolerant {
+		brs = tolerantBreaks(msg.Break().Bytes())
+	}
+
 	sb := []byte(fmt.Sprintf("--%s%s", pv.Boundary(), msg.Break()))
 	mb := []byte(fmt.Sprintf("%s--%s%s", msg.Break(), pv.Boundary(), msg.Break()))
 	eb := []byte(fmt.Sprintf("%s--%s--%s", msg.Break(), pv.Boundary(), msg.Break()))
-	fb := []byte(fmt.Sprintf("%s--%s--", msg.Break(), pv.Boundary()))
+
+	delim := []byte("--" + pv.Boundary())
+	final := []byte("--" + pv.Boundary() + "--")
+	sbs := &boundaryMatcher{nil, delim, brs, pr.tolerant}
+	mbs := &boundaryMatcher{brs, delim, brs, pr.tolerant}
+	ebs := &boundaryMatcher{brs, final, brs, pr.tolerant}
+	fbs := &boundaryMatcher{brs, final, nil, pr.tolerant}
 
 	const (
 		modeStart = iota
@@ -421,19 +972,36 @@ func (pr *parser) parse(msg *Opaque, depth int) (Generic, error) {
 	var prefix, suffix []byte
 	mode := modeStart
 	awaitingPrefix := true
+
+	// consumed counts the bytes of the body consumed by the scanner so far and
+	// tokenOffset is where the most recent part begins in the body
+	var consumed, tokenOffset int64
 	sc.Split(
 		scanner.MakeSplitFuncExitByAdvance( // bufio.SplitFunc sucks
 			func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+				defer func() {
+					if token != nil {
+						tokenOffset = consumed
+					}
+					consumed += int64(advance)
+				}()
+
+				// if parsing has been stopped, the rest of the data is
+				// returned unparsed
+				if pr.stopped {
+					return len(data), data, bufio.ErrFinalToken
+				}
+
 				switch mode {
 				case modeStart:
 					// looking for an empty prefix
-					if atEOF || len(data) >= len(sb) {
-						if bytes.Equal(data[:len(sb)], sb) {
-							// initial string is the boundary, so we have an empty
-							// prefix
+					if atEOF || hasLineBreak(data) {
+						if ix, m := sbs.index(data); ix == 0 {
+							// initial string is the boundary, so we have an
+							// empty prefix
 							prefix = []byte{}
 							awaitingPrefix = false
-							advance = len(sb)
+							advance = len(m)
 						}
 						// else, no zero-length prefix
 
@@ -441,22 +1009,22 @@ func (pr *parser) parse(msg *Opaque, depth int) (Generic, error) {
 						mode = modeMiddle
 						err = scanner.ErrContinue
 					}
-					// else, we don't have enough data to know if we've got a
-					// zero-length prefix yet or not.
+					// else, we don't have the whole first line to know if
+					// we've got a zero-length prefix yet or not.
 
 				case modeMiddle:
 					// we are now looking for parts or possibly the prefix if it is
 					// not a zero byte prefix
-					if ix := bytes.Index(data, mb); ix >= 0 {
+					if ix, m := mbs.index(data); ix >= 0 {
 						// we found a \n--boundary\n string:
 						// |-> advance past the boundary for the next token
 						// |-> if awaitingPrefix, capture prefix
 						// |-> if not awaitingPrefix, return token
-						advance = ix + len(mb)
+						advance = ix + len(m)
 						if awaitingPrefix {
 							// this is the first boundary, so the input so far is
 							// the prefix
-							ps := data[:ix+len(msg.Break())]
+							ps := data[:ix+bytes.Index(m, []byte("--"))]
 							prefix = make([]byte, len(ps))
 							copy(prefix, ps)
 							awaitingPrefix = false
@@ -484,18 +1052,18 @@ func (pr *parser) parse(msg *Opaque, depth int) (Generic, error) {
 					}
 
 					// if we are here, we know that atEOF is true
-					if ix := bytes.Index(data, eb); ix >= 0 {
+					if ix, m := ebs.index(data); ix >= 0 {
 						// we found the end \n--boundary--\n string:
 						// |-> capture the suffix, which is everything after the
 						// |   boundary (including the line ending, which is why
-						// |   we use len(fb) here and not len(eb), that is
-						// |   deliberate.
+						// |   we trim the trailing line ending from the match,
+						// |   that is deliberate.
 						// |-> capture the token to return as the final part
 						token = data[:ix]
-						ss := data[ix+len(fb):]
+						ss := data[ix+len(bytes.TrimRight(m, "\r\n")):]
 						suffix = make([]byte, len(ss))
 						copy(suffix, ss)
-					} else if ix := bytes.Index(data, fb); ix == len(data)-len(fb) {
+					} else if ix, _ := fbs.index(data); ix >= 0 {
 						// we found the final \n--boundary-- string at the actual
 						// end of input (no final line break)
 						// |-> there's no suffix, not even a newline
@@ -533,7 +1101,8 @@ func (pr *parser) parse(msg *Opaque, depth int) (Generic, error) {
 
 		if err := sc.Err(); err != nil {
 			if errors.Is(err, bufio.ErrTooLong) {
-				return nil, ErrLargePart
+				return nil, loc.parseError(ErrLargePart, len(parts),
+					loc.bodyOffset+consumed, pv.Boundary())
 			} else {
 				// TODO Can this ever happen? If so, how should we handle it?
 				return nil, err
@@ -557,31 +1126,78 @@ func (pr *parser) parse(msg *Opaque, depth int) (Generic, error) {
 		}, nil
 	}
 
-	// All returned tokens are parts
+	// All returned tokens are parts. The original bytes of a signed message
+	// are kept as they are needed to verify the signature.
 	msgParts := make([]Generic, 0, 10)
+	keepRaw := strings.EqualFold(pv.MediaType(), "multipart/signed")
+	var rawParts [][]byte
+	var rest io.Reader
 	for sc.Scan() {
+		// parsing was stopped, so this token is the unparsed remainder
+		if pr.stopped {
+			rb := make([]byte, len(sc.Bytes()))
+			copy(rb, sc.Bytes())
+			rest = io.MultiReader(bytes.NewReader(rb), msg.Reader)
+			break
+		}
+
 		part := sc.Bytes()
 		parts = append(parts, part)
 
+		if keepRaw {
+			rb := make([]byte, len(part))
+			copy(rb, part)
+			rawParts = append(rawParts, rb)
+		}
+
+		// defer parsing the part until it is used
+		i, partOffset := len(parts)-1, loc.bodyOffset+tokenOffset
+		if pr.lazy {
+			if pr.checkBounds {
+				body := part
+				if pos, _ := searchForSplit(part, true); pos >= 0 {
+					body = part[pos:]
+				}
+				pr.checkContent(loc.child(i, 0), body)
+			}
+
+			msgParts = append(msgParts,
+				newLazyPart(part, pr, depth-1, loc, i, partOffset, pv.Boundary()))
+			continue
+		}
+
 		// parse each part as a simple message first
-		opMsg, err := pr.parseToOpaque(bytes.NewReader(part), true)
+		opMsg, hdrLen, err := pr.parseToOpaque(bytes.NewReader(part), true)
 		if err != nil {
 			orig, _ := originalMessage()
-			return orig, err
+			return orig, loc.parseError(err, i, partOffset, pv.Boundary())
 		}
 
-		msg, err := pr.parse(opMsg, depth-1)
+		msg, err := pr.parse(opMsg, depth-1, loc.child(i, partOffset+int64(hdrLen)))
 		if err != nil {
 			orig, _ := originalMessage()
 			return orig, err
 		}
 
+		// a part that cannot be decoded is checked as far as it decodes
+		if op, isOpaque := msg.(*Opaque); isOpaque && pr.checkBounds {
+			content, _ := io.ReadAll(
+				transfer.ApplyTransferDecoding(&op.Header, bytes.NewReader(part[hdrLen:])))
+			pr.checkContent(loc.child(i, 0), content)
+		}
+
+		if op, isOpaque := msg.(*Opaque); isOpaque && pr.keepRaw {
+			op.raw = make([]byte, len(part))
+			copy(op.raw, part)
+		}
+
 		msgParts = append(msgParts, msg)
 	}
 
 	if err := sc.Err(); err != nil {
 		if errors.Is(err, bufio.ErrTooLong) {
-			return nil, ErrLargePart
+			return nil, loc.parseError(ErrLargePart, len(parts),
+				loc.bodyOffset+consumed, pv.Boundary())
 		} else {
 			// TODO Can this ever happen and, if so, how should we handle it?
 			orig, _ := originalMessage()
@@ -594,5 +1210,152 @@ func (pr *parser) parse(msg *Opaque, depth int) (Generic, error) {
 		prefix: prefix,
 		suffix: suffix,
 		parts:  msgParts,
+		rest:   rest,
+		raw:    rawParts,
 	}, nil
 }
+
+// checkContent records a violation for each boundary enclosing the part at loc
+// that is found in the given content.
+func (pr *parser) checkContent(loc partLocation, content []byte) {
+	for _, b := range loc.boundaries {
+		if bytes.Contains(content, []byte("--"+b)) {
+			pr.violations = append(pr.violations,
+				fmt.Sprintf("%s: content contains the boundary %q", loc.name(), b))
+		}
+	}
+}
+
+// hasLineBreak returns true if data holds a complete line break of any kind. A
+// CR at the very end may be the start of a CRLF, so it does not count until
+// the byte after it is seen.
+func hasLineBreak(data []byte) bool {
+	if bytes.IndexByte(data, '\n') >= 0 {
+		return true
+	}
+
+	ix := bytes.IndexByte(data, '\r')
+	return ix >= 0 && ix < len(data)-1
+}
+
+// tolerantBreaks returns the line breaks to accept around boundaries when
+// WithTolerantBoundaries() is in effect. The message's own break is always
+// included.
+func tolerantBreaks(br []byte) [][]byte {
+	brs := [][]byte{[]byte("\x0d\x0a"), []byte("\x0a")}
+	for _, b := range brs {
+		if bytes.Equal(b, br) {
+			return brs
+		}
+	}
+	return append(brs, br)
+}
+
+// boundaryMatcher finds a boundary line in the data of a multipart body.
+type boundaryMatcher struct {
+	// before lists the line breaks that may come before the boundary. If nil,
+	// the boundary must start the data.
+	before [][]byte
+
+	// delim is the boundary itself, e.g., "--boundary" or "--boundary--".
+	delim []byte
+
+	// after lists the line breaks that may come after the boundary. If nil,
+	// the boundary must end the data.
+	after [][]byte
+
+	// loose permits whitespace around the boundary and a boundary without a
+	// line break before it.
+	loose bool
+}
+
+// index searches data for the earliest boundary line and returns its index
+// and the bytes matched, including the line breaks around it. It returns -1 and
+// nil if no boundary is found.
+func (bm *boundaryMatcher) index(data []byte) (int, []byte) {
+	for off := 0; off < len(data); {
+		i := bytes.Index(data[off:], bm.delim)
+		if i < 0 {
+			break
+		}
+
+		p := off + i
+		off = p + 1
+
+		start, ok := bm.matchBefore(data, p)
+		if !ok {
+			continue
+		}
+
+		end, ok := bm.matchAfter(data, p+len(bm.delim))
+		if !ok {
+			continue
+		}
+
+		return start, data[start:end]
+	}
+
+	return -1, nil
+}
+
+// matchBefore checks what comes before the boundary found at p and returns the
+// index where the match starts.
+func (bm *boundaryMatcher) matchBefore(data []byte, p int) (int, bool) {
+	q := p
+	if bm.loose {
+		for q > 0 && (data[q-1] == ' ' || data[q-1] == '\t') {
+			q--
+		}
+	}
+
+	if bm.before == nil {
+		return q, q == 0
+	}
+
+	if br := longestSuffix(data[:q], bm.before); br != nil {
+		return q - len(br), true
+	}
+
+	// the boundary is stuck to the end of the preceding content
+	if bm.loose {
+		return p, true
+	}
+
+	return 0, false
+}
+
+// matchAfter checks what comes after the boundary ending at p and returns the
+// index where the match ends.
+func (bm *boundaryMatcher) matchAfter(data []byte, p int) (int, bool) {
+	// RFC 2046 permits transport padding after the boundary, but it would be
+	// lost when writing the message, so it is only accepted when loose
+	if bm.loose {
+		for p < len(data) && (data[p] == ' ' || data[p] == '\t') {
+			p++
+		}
+	}
+
+	if bm.after == nil {
+		return p, p == len(data)
+	}
+
+	for _, br := range bm.after {
+		if bytes.HasPrefix(data[p:], br) {
+			return p + len(br), true
+		}
+	}
+
+	return 0, false
+}
+
+// longestSuffix returns the longest of the given byte slices that data ends
+// with or nil if data ends with none of them.
+func longestSuffix(data []byte, bs [][]byte) []byte {
+	var found []byte
+	for _, b := range bs {
+		if len(b) > len(found) && bytes.HasSuffix(data, b) {
+			found = b
+		}
+	}
+	return found
+}
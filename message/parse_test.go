@@ -2,8 +2,12 @@ package message_test
 
 import (
 	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
 	"io"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -266,3 +270,695 @@ func TestParse_WithMail1_RoundTrip(t *testing.T) {
 
 	assert.Equal(t, srcBytes, buf.Bytes())
 }
+
+func TestParse_WithTolerantBoundaries(t *testing.T) {
+	t.Parallel()
+
+	const mixed = "Content-type: multipart/mixed; boundary=abc\r\n\r\n" +
+		"--abc\n" +
+		"Content-type: text/plain\n\none\n" +
+		"--abc\n" +
+		"Content-type: text/plain\n\ntwo\n" +
+		"--abc--\n"
+
+	// without the option, the boundaries are not found
+	m, _ := message.Parse(bytes.NewReader([]byte(mixed)))
+	require.NotNil(t, m)
+	assert.False(t, m.IsMultipart())
+
+	m, err := message.Parse(bytes.NewReader([]byte(mixed)), message.WithTolerantBoundaries())
+	require.NoError(t, err)
+	require.True(t, m.IsMultipart())
+
+	parts := m.GetParts()
+	require.Len(t, parts, 2)
+
+	for i, expect := range []string{"one", "two"} {
+		body, err := io.ReadAll(parts[i].GetReader())
+		assert.NoError(t, err)
+		assert.Equal(t, expect, string(body))
+	}
+}
+
+func TestParse_MalformedBoundaries(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		src      string
+		expect   []string
+		tolerant bool
+	}{
+		{
+			name: "trailing whitespace",
+			src: "Content-type: multipart/mixed; boundary=b\n\n" +
+				"--b  \n\none\n--b \t\n\ntwo\n--b--  \n",
+			expect:   []string{"one", "two"},
+			tolerant: true,
+		},
+		{
+			name: "leading whitespace",
+			src: "Content-type: multipart/mixed; boundary=b\n\n" +
+				"  --b\n\none\n\t--b\n\ntwo\n  --b--\n",
+			expect:   []string{"one", "two"},
+			tolerant: true,
+		},
+		{
+			name: "boundary after content",
+			src: "Content-type: multipart/mixed; boundary=b\n\n" +
+				"--b\n\none--b\n\ntwo--b--\n",
+			expect:   []string{"one", "two"},
+			tolerant: true,
+		},
+		{
+			name: "no blank line after header",
+			src: "Subject: x\nContent-type: multipart/mixed; boundary=b\n" +
+				"--b\nContent-type: text/plain\n\none\n--b\n\ntwo\n--b--\n",
+			expect: []string{"one", "two"},
+		},
+		{
+			name: "no blank line after header or part header",
+			src: "Subject: x\nContent-type: multipart/mixed; boundary=b\n" +
+				"--b\n\none\n--b--\n",
+			expect: []string{"one"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var opts []message.ParseOption
+			if test.tolerant {
+				// without the option, the parts are not split correctly
+				m, _ := message.Parse(strings.NewReader(test.src))
+				require.NotNil(t, m)
+				assert.False(t, m.IsMultipart() && len(m.GetParts()) == len(test.expect))
+
+				// but the message round-trips byte-for-byte
+				buf := &bytes.Buffer{}
+				_, err := m.WriteTo(buf)
+				assert.NoError(t, err)
+				assert.Equal(t, test.src, buf.String())
+
+				opts = append(opts, message.WithTolerantBoundaries())
+			}
+
+			m, err := message.Parse(strings.NewReader(test.src), opts...)
+			require.NoError(t, err)
+			require.True(t, m.IsMultipart())
+
+			parts := m.GetParts()
+			require.Len(t, parts, len(test.expect))
+			for i, expect := range test.expect {
+				body, err := io.ReadAll(parts[i].GetReader())
+				assert.NoError(t, err)
+				assert.Equal(t, expect, string(body))
+			}
+		})
+	}
+}
+
+func TestParse_CROnlyBreaks(t *testing.T) {
+	t.Parallel()
+
+	// a message using bare CR line breaks with many small parts must still be
+	// split part by part, not buffered whole
+	src := &strings.Builder{}
+	src.WriteString("Subject: cr only\rContent-type: multipart/mixed; boundary=abc\r\r")
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(src, "--abc\rContent-type: text/plain\r\rPart %d %s\r",
+			i, strings.Repeat("x", 180))
+	}
+	src.WriteString("--abc--\r")
+
+	m, err := message.Parse(strings.NewReader(src.String()),
+		message.WithMaxPartLength(20_000))
+	require.NoError(t, err)
+	require.True(t, m.IsMultipart())
+	assert.Len(t, m.GetParts(), 500)
+
+	buf := &bytes.Buffer{}
+	_, err = m.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, src.String(), buf.String())
+}
+
+func TestParse_WithStopAt(t *testing.T) {
+	t.Parallel()
+
+	const src = `Subject: stop at
+Content-type: multipart/mixed; boundary=outer
+
+--outer
+Content-type: multipart/alternative; boundary=inner
+
+--inner
+Content-type: text/plain
+
+Plain text.
+--inner
+Content-type: text/html
+
+<p>HTML text.</p>
+--inner--
+--outer
+Content-type: application/octet-stream
+
+Attachment.
+--outer--
+`
+
+	m, err := message.Parse(bytes.NewReader([]byte(src)),
+		message.WithStopAt(func(h *header.Header) bool {
+			mt, _ := h.GetMediaType()
+			return mt == "text/plain"
+		}))
+	require.NoError(t, err)
+	require.True(t, m.IsMultipart())
+
+	parts := m.GetParts()
+	require.Len(t, parts, 1)
+	require.True(t, parts[0].IsMultipart())
+
+	subParts := parts[0].GetParts()
+	require.Len(t, subParts, 1)
+
+	mt, err := subParts[0].GetHeader().GetMediaType()
+	assert.NoError(t, err)
+	assert.Equal(t, "text/plain", mt)
+
+	buf := &bytes.Buffer{}
+	n, err := m.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(src)), n)
+	assert.Equal(t, src, buf.String())
+}
+
+func TestParse_DecodeTransferEncoding_Base64LineLength(t *testing.T) {
+	t.Parallel()
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), 20)
+	enc := base64.StdEncoding.EncodeToString(content)
+
+	var lines []string
+	for len(enc) > 64 {
+		lines = append(lines, enc[:64])
+		enc = enc[64:]
+	}
+	lines = append(lines, enc)
+
+	src := "Subject: wrapped\n" +
+		"Content-type: multipart/mixed; boundary=b\n" +
+		"\n" +
+		"--b\n" +
+		"Content-type: application/octet-stream\n" +
+		"Content-transfer-encoding: base64\n" +
+		"\n" +
+		strings.Join(lines, "\n") + "\n" +
+		"--b--\n"
+
+	m, err := message.Parse(strings.NewReader(src), message.DecodeTransferEncoding())
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	_, err = m.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, src, buf.String())
+}
+
+func TestParse_WithMaxTotalDepthWork(t *testing.T) {
+	t.Parallel()
+
+	const src = `Subject: budget
+Content-type: multipart/mixed; boundary=outer
+
+--outer
+Content-type: multipart/alternative; boundary=inner
+
+--inner
+Content-type: text/plain
+
+Plain text.
+--inner
+Content-type: multipart/related; boundary=deepest
+
+--deepest
+Content-type: text/html
+
+<p>HTML text.</p>
+--deepest--
+--inner--
+--outer
+Content-type: application/octet-stream
+
+Attachment.
+--outer--
+`
+
+	m, err := message.Parse(bytes.NewReader([]byte(src)),
+		message.WithMaxTotalDepthWork(3))
+	assert.ErrorIs(t, err, message.ErrParseBudgetExceeded)
+	require.NotNil(t, m)
+	require.True(t, m.IsMultipart())
+
+	parts := m.GetParts()
+	require.Len(t, parts, 1)
+	require.True(t, parts[0].IsMultipart())
+
+	subParts := parts[0].GetParts()
+	require.Len(t, subParts, 2)
+	assert.False(t, subParts[0].IsMultipart())
+
+	// the part that exceeded the budget is left unparsed
+	assert.False(t, subParts[1].IsMultipart())
+	mt, err := subParts[1].GetHeader().GetMediaType()
+	assert.NoError(t, err)
+	assert.Equal(t, "multipart/related", mt)
+
+	buf := &bytes.Buffer{}
+	n, err := m.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(src)), n)
+	assert.Equal(t, src, buf.String())
+
+	m, err = message.Parse(bytes.NewReader([]byte(src)),
+		message.WithMaxTotalDepthWork(6))
+	assert.NoError(t, err)
+	require.Len(t, m.GetParts(), 2)
+}
+
+func TestParse_WithBoundaryCheck(t *testing.T) {
+	t.Parallel()
+
+	const src = `Subject: boundaries
+Content-type: multipart/mixed; boundary=outer
+
+--outer
+Content-type: text/plain
+
+Plain text --outer.
+--outer
+Content-type: text/plain
+
+Fine.
+--outer--
+`
+
+	m, err := message.Parse(strings.NewReader(src), message.WithBoundaryCheck())
+	var bErr *message.BoundaryInContentError
+	require.ErrorAs(t, err, &bErr)
+	assert.Equal(t,
+		[]string{`message part 1: content contains the boundary "outer"`},
+		bErr.Violations)
+
+	// the message is still parsed in full
+	require.NotNil(t, m)
+	require.Len(t, m.GetParts(), 2)
+
+	buf := &bytes.Buffer{}
+	_, err = m.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, src, buf.String())
+
+	// lazy parts are checked as they are split off, without parsing them
+	m, err = message.Parse(strings.NewReader(src),
+		message.WithBoundaryCheck(), message.WithLazyParts())
+	require.ErrorAs(t, err, &bErr)
+	assert.Equal(t,
+		[]string{`message part 1: content contains the boundary "outer"`},
+		bErr.Violations)
+
+	require.Len(t, m.GetParts(), 2)
+	lp, isLazy := m.GetParts()[0].(*message.LazyPart)
+	require.True(t, isLazy)
+	assert.False(t, lp.IsResolved())
+
+	// without the option, nothing is checked
+	_, err = message.Parse(strings.NewReader(src))
+	assert.NoError(t, err)
+}
+
+func TestParse_WithTruncateLongHeader(t *testing.T) {
+	t.Parallel()
+
+	const src = "Subject: short\n" +
+		"X-Long: " + "this header goes on and on and on and on and on and on\n" +
+		"X-Never: parsed\n" +
+		"\n" +
+		"Body.\n"
+
+	_, err := message.Parse(bytes.NewReader([]byte(src)),
+		message.WithMaxHeaderLength(40),
+		message.WithChunkSize(16))
+	assert.ErrorIs(t, err, message.ErrLargeHeader)
+
+	m, err := message.Parse(bytes.NewReader([]byte(src)),
+		message.WithMaxHeaderLength(40),
+		message.WithChunkSize(16),
+		message.WithTruncateLongHeader())
+	require.NoError(t, err)
+
+	om, isOpaque := m.(*message.Opaque)
+	require.True(t, isOpaque)
+	assert.True(t, om.IsTruncated())
+
+	subj, err := om.GetSubject()
+	assert.NoError(t, err)
+	assert.Equal(t, "short", subj)
+
+	_, err = om.Get("X-Never")
+	assert.ErrorIs(t, err, header.ErrNoSuchField)
+
+	body, err := io.ReadAll(om.GetReader())
+	assert.NoError(t, err)
+	assert.Equal(t, src[len("Subject: short\n"):], string(body))
+
+	// a header within the limit is not truncated
+	m, err = message.Parse(bytes.NewReader([]byte(src)),
+		message.WithTruncateLongHeader())
+	require.NoError(t, err)
+	assert.False(t, m.(*message.Opaque).IsTruncated())
+}
+
+func TestParse_WithContentEncodingDecoding(t *testing.T) {
+	t.Parallel()
+
+	zbuf := &bytes.Buffer{}
+	zw := gzip.NewWriter(zbuf)
+	_, err := zw.Write([]byte("Compressed content."))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	src := "Content-type: multipart/mixed; boundary=outer\n" +
+		"\n" +
+		"--outer\n" +
+		"Content-type: text/plain\n" +
+		"Content-encoding: gzip\n" +
+		"Content-transfer-encoding: base64\n" +
+		"\n" +
+		base64.StdEncoding.EncodeToString(zbuf.Bytes()) + "\n" +
+		"--outer\n" +
+		"Content-type: text/plain\n" +
+		"Content-encoding: br\n" +
+		"\n" +
+		"Not really brotli.\n" +
+		"--outer--\n"
+
+	m, err := message.Parse(bytes.NewReader([]byte(src)),
+		message.WithContentEncodingDecoding())
+	require.NoError(t, err)
+
+	parts := m.GetParts()
+	require.Len(t, parts, 2)
+
+	_, err = parts[0].GetHeader().Get(header.ContentEncoding)
+	assert.ErrorIs(t, err, header.ErrNoSuchField)
+
+	body, err := io.ReadAll(parts[0].GetReader())
+	assert.NoError(t, err)
+	assert.Equal(t, "Compressed content.", string(body))
+
+	ce, err := parts[1].GetHeader().Get(header.ContentEncoding)
+	assert.NoError(t, err)
+	assert.Equal(t, "br", ce)
+
+	body, err = io.ReadAll(parts[1].GetReader())
+	assert.NoError(t, err)
+	assert.Equal(t, "Not really brotli.", string(body))
+}
+
+func TestParse_MediaTypeWithoutSubtype(t *testing.T) {
+	t.Parallel()
+
+	srcs := []string{
+		"Content-type: multipart\n\n--abc\n\nNot a part.\n--abc--\n",
+		"Content-type: multipart; boundary=abc\n\n--abc\n\nNot a part.\n--abc--\n",
+		"Content-type: application\n\nJust bytes.\n",
+	}
+
+	for _, src := range srcs {
+		m, err := message.Parse(bytes.NewReader([]byte(src)))
+		require.NoError(t, err)
+		assert.False(t, m.IsMultipart())
+
+		buf := &bytes.Buffer{}
+		_, err = m.WriteTo(buf)
+		assert.NoError(t, err)
+		assert.Equal(t, src, buf.String())
+	}
+}
+
+func TestParse_WithCanonicalFieldNames(t *testing.T) {
+	t.Parallel()
+
+	const src = "content-TYPE: text/plain;\n" +
+		"  charset=utf-8\n" +
+		"x-spam-SCORE: 5.2\n" +
+		"Subject: unchanged\n" +
+		"\n" +
+		"Hello."
+
+	m, err := message.Parse(bytes.NewReader([]byte(src)),
+		message.WithCanonicalFieldNames())
+	require.NoError(t, err)
+
+	names := []string{}
+	for _, f := range m.GetHeader().ListFields() {
+		names = append(names, f.Name())
+	}
+	assert.Equal(t, []string{"Content-Type", "X-Spam-Score", "Subject"}, names)
+
+	const expect = "Content-Type: text/plain;\n" +
+		"  charset=utf-8\n" +
+		"X-Spam-Score: 5.2\n" +
+		"Subject: unchanged\n" +
+		"\n" +
+		"Hello."
+
+	buf := &bytes.Buffer{}
+	_, err = m.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, expect, buf.String())
+}
+
+func TestParse_WithRawParts(t *testing.T) {
+	t.Parallel()
+
+	const attachment = "Content-type: application/octet-stream\n" +
+		"Content-transfer-encoding: base64\n" +
+		"\n" +
+		"AAEC\n" +
+		"/w=="
+
+	src := "Subject: raw\n" +
+		"Content-type: multipart/mixed; boundary=b\n" +
+		"\n" +
+		"--b\n" +
+		"Content-type: text/plain\n" +
+		"\n" +
+		"Text.\n" +
+		"--b\n" +
+		attachment + "\n" +
+		"--b--\n"
+
+	m, err := message.Parse(strings.NewReader(src),
+		message.WithRawParts(), message.DecodeTransferEncoding())
+	require.NoError(t, err)
+
+	parts := m.GetParts()
+	require.Len(t, parts, 2)
+
+	att, isOpaque := parts[1].(*message.Opaque)
+	require.True(t, isOpaque)
+	assert.Equal(t, attachment, string(att.RawBytes()))
+
+	text, isOpaque := parts[0].(*message.Opaque)
+	require.True(t, isOpaque)
+	assert.Equal(t, "Content-type: text/plain\n\nText.", string(text.RawBytes()))
+
+	m, err = message.Parse(strings.NewReader(src))
+	require.NoError(t, err)
+	assert.Nil(t, m.GetParts()[1].(*message.Opaque).RawBytes())
+}
+
+func TestParse_ParseError(t *testing.T) {
+	t.Parallel()
+
+	big := strings.Repeat("x", 200)
+	src := "Subject: errors\n" +
+		"Content-type: multipart/mixed; boundary=outer\n" +
+		"\n" +
+		"--outer\n" +
+		"Content-type: text/plain\n" +
+		"\n" +
+		"First.\n" +
+		"--outer\n" +
+		"Content-type: multipart/alternative; boundary=inner\n" +
+		"\n" +
+		"--inner\n" +
+		"Content-type: text/plain\n" +
+		"\n" +
+		"Small.\n" +
+		"--inner\n" +
+		"Content-type: text/html\n" +
+		"X-Big: " + big + "\n" +
+		"\n" +
+		"<p>Big header.</p>\n" +
+		"--inner--\n" +
+		"--outer--\n"
+
+	_, err := message.Parse(strings.NewReader(src),
+		message.WithMaxHeaderLength(100),
+		message.WithChunkSize(16))
+	assert.ErrorIs(t, err, message.ErrLargeHeader)
+
+	var pe *message.ParseError
+	require.ErrorAs(t, err, &pe)
+	assert.Equal(t, []int{1, 1}, pe.Path)
+	assert.Equal(t, "inner", pe.Boundary)
+	assert.Equal(t, int64(strings.Index(src, "Content-type: text/html")), pe.Offset)
+	assert.Contains(t, err.Error(), `at part [1][1] at offset`)
+
+	_, err = message.Parse(strings.NewReader(src),
+		message.WithMaxHeaderLength(20))
+	assert.ErrorIs(t, err, message.ErrLargeHeader)
+	require.ErrorAs(t, err, &pe)
+	assert.Empty(t, pe.Path)
+	assert.Equal(t, int64(0), pe.Offset)
+	assert.Contains(t, err.Error(), "in the top-level message")
+}
+
+func TestParse_ParseError_LargePart(t *testing.T) {
+	t.Parallel()
+
+	src := "Subject: errors\n" +
+		"Content-type: multipart/mixed; boundary=outer\n" +
+		"\n" +
+		"--outer\n" +
+		"Content-type: text/plain\n" +
+		"\n" +
+		"First.\n" +
+		"--outer\n" +
+		"Content-type: text/plain\n" +
+		"\n" +
+		strings.Repeat("too long ", 100) + "\n" +
+		"--outer--\n"
+
+	_, err := message.Parse(strings.NewReader(src),
+		message.WithMaxPartLength(200),
+		message.WithChunkSize(64))
+	assert.ErrorIs(t, err, message.ErrLargePart)
+
+	var pe *message.ParseError
+	require.ErrorAs(t, err, &pe)
+	assert.Equal(t, []int{1}, pe.Path)
+	assert.Equal(t, "outer", pe.Boundary)
+	assert.Equal(t, int64(strings.LastIndex(src, "Content-type: text/plain")), pe.Offset)
+}
+
+func TestParse_BinaryTransferEncoding(t *testing.T) {
+	t.Parallel()
+
+	const body = "\x00\x01\r\n\r\nnot a header\n\n\r\r\x00--b\x00\xff\xfe\x00"
+
+	srcs := []string{
+		"Content-type: application/octet-stream\n" +
+			"Content-transfer-encoding: binary\n" +
+			"\n" + body,
+
+		"Content-type: multipart/mixed; boundary=b\r\n" +
+			"\r\n" +
+			"--b\r\n" +
+			"Content-type: application/octet-stream\r\n" +
+			"Content-transfer-encoding: binary\r\n" +
+			"\r\n" + body + "\r\n" +
+			"--b--\r\n",
+	}
+
+	for _, src := range srcs {
+		for _, opts := range [][]message.ParseOption{
+			nil,
+			{message.DecodeTransferEncoding()},
+		} {
+			m, err := message.Parse(strings.NewReader(src), opts...)
+			require.NoError(t, err)
+
+			part := m
+			if m.IsMultipart() {
+				require.Len(t, m.GetParts(), 1)
+				part = m.GetParts()[0].(message.Generic)
+			}
+
+			cte, err := part.GetHeader().GetTransferEncoding()
+			assert.NoError(t, err)
+			assert.Equal(t, transfer.Binary, cte)
+
+			content, err := io.ReadAll(part.GetReader())
+			assert.NoError(t, err)
+			assert.Equal(t, body, string(content))
+
+			if op, isOpaque := part.(*message.Opaque); isOpaque {
+				op.Reader = bytes.NewReader(content)
+			}
+
+			buf := &bytes.Buffer{}
+			_, err = m.WriteTo(buf)
+			assert.NoError(t, err)
+			assert.Equal(t, src, buf.String())
+		}
+	}
+}
+
+func TestParseHeaderOnly(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		src    string
+		start  int64
+		offset int64
+		body   string
+	}{
+		{"LF", "Subject: one\nX-Foo: bar\n\nbody\n", 0, 25, "body\n"},
+		{"CRLF", "Subject: two\r\n\r\nbody\r\n", 0, 16, "body\r\n"},
+		{"header only", "Subject: three\n", 0, 15, ""},
+		{"start", "junk\nSubject: four\n\nbody", 5, 20, "body"},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := strings.NewReader(test.src)
+			_, err := r.Seek(test.start, io.SeekStart)
+			require.NoError(t, err)
+
+			h, offset, err := message.ParseHeaderOnly(r)
+			require.NoError(t, err)
+			assert.Equal(t, test.offset, offset)
+
+			subj, err := h.GetSubject()
+			assert.NoError(t, err)
+			assert.NotEmpty(t, subj)
+
+			body, err := io.ReadAll(r)
+			assert.NoError(t, err)
+			assert.Equal(t, test.body, string(body))
+		})
+	}
+}
+
+func TestParseHeaderOnly_LargeHeader(t *testing.T) {
+	t.Parallel()
+
+	src := "Subject: " + strings.Repeat("x", 100) + "\n\nbody"
+	_, _, err := message.ParseHeaderOnly(strings.NewReader(src),
+		message.WithMaxHeaderLength(50), message.WithChunkSize(16))
+
+	var perr *message.ParseError
+	require.ErrorAs(t, err, &perr)
+	assert.ErrorIs(t, err, message.ErrLargeHeader)
+}
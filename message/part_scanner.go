@@ -0,0 +1,215 @@
+package message
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/zostay/go-email/v2/message/header"
+)
+
+// ErrNoBoundary is returned by PartReader when the given boundary is empty.
+var ErrNoBoundary = errors.New("multipart boundary is empty")
+
+// PartScanner reads the parts of a multipart body one at a time as they
+// arrive. It is created with PartReader.
+type PartScanner struct {
+	r        *bufio.Reader
+	boundary []byte
+	started  bool
+	done     bool
+	current  *partBody
+}
+
+// PartReader returns a PartScanner for pulling the parts out of the body of a
+// multipart message read from r, which is separated using the given boundary
+// (i.e., the boundary parameter of the Content-type header). This is a
+// lower-memory alternative to Parse for processing each part once and then
+// discarding it. Only the header of the current part is held in memory. The
+// parts are not parsed recursively, so a nested multipart part will have to be
+// read with another PartScanner or with Parse.
+//
+// It returns ErrNoBoundary if the boundary is empty.
+func PartReader(r io.Reader, boundary string) (*PartScanner, error) {
+	if boundary == "" {
+		return nil, ErrNoBoundary
+	}
+
+	return &PartScanner{
+		r:        bufio.NewReader(r),
+		boundary: []byte("--" + boundary),
+	}, nil
+}
+
+// Next returns the header and body of the next part. The body must be read to
+// the end before calling Next again. If it is not, Next will discard the rest
+// of it.
+//
+// It returns io.EOF after the closing boundary has been read. It returns
+// io.ErrUnexpectedEOF if the input ends before the closing boundary is found.
+// It returns ErrLargeHeader if a part header is longer than
+// DefaultMaxHeaderLength. If the header has a recoverable parse problem, the
+// header and body are returned with a *field.BadStartError.
+func (ps *PartScanner) Next() (*header.Header, io.Reader, error) {
+	if !ps.started {
+		ps.started = true
+		if err := ps.skipPreamble(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if ps.current != nil {
+		if _, err := io.Copy(io.Discard, ps.current); err != nil {
+			return nil, nil, err
+		}
+		ps.done = ps.current.final
+		ps.current = nil
+	}
+
+	if ps.done {
+		return nil, nil, io.EOF
+	}
+
+	h, err := ps.readHeader()
+	if h == nil {
+		return nil, nil, err
+	}
+
+	ps.current = &partBody{ps: ps}
+	return h, ps.current, err
+}
+
+// readLine reads the next line, including its line break. It returns
+// io.ErrUnexpectedEOF if the input ends before any more bytes are read.
+func (ps *PartScanner) readLine() ([]byte, error) {
+	line, err := ps.r.ReadBytes('\n')
+	if errors.Is(err, io.EOF) {
+		if len(line) == 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return line, nil
+	}
+	return line, err
+}
+
+// isBoundary checks whether the line (without its line break) is a boundary
+// line. The first return value is true for any boundary and the second is true
+// for the closing boundary.
+func (ps *PartScanner) isBoundary(line []byte) (bool, bool) {
+	if !bytes.HasPrefix(line, ps.boundary) {
+		return false, false
+	}
+
+	rest := bytes.TrimRight(line[len(ps.boundary):], " \t")
+	switch string(rest) {
+	case "":
+		return true, false
+	case "--":
+		return true, true
+	}
+
+	return false, false
+}
+
+// skipPreamble discards everything up to and including the first boundary.
+func (ps *PartScanner) skipPreamble() error {
+	for {
+		line, err := ps.readLine()
+		if err != nil {
+			return err
+		}
+
+		content, _ := splitBreak(line)
+		if isB, isFinal := ps.isBoundary(content); isB {
+			ps.done = isFinal
+			return nil
+		}
+	}
+}
+
+// readHeader reads the lines of a part header up to the blank line separating
+// it from the body and parses them.
+func (ps *PartScanner) readHeader() (*header.Header, error) {
+	var (
+		buf bytes.Buffer
+		lb  []byte
+	)
+
+	for {
+		line, err := ps.readLine()
+		if err != nil {
+			return nil, err
+		}
+
+		content, br := splitBreak(line)
+		if lb == nil {
+			lb = br
+		}
+
+		if len(content) == 0 {
+			break
+		}
+
+		buf.Write(line)
+		if buf.Len() > DefaultMaxHeaderLength {
+			return nil, ErrLargeHeader
+		}
+	}
+
+	if len(lb) == 0 {
+		lb = header.LF.Bytes()
+	}
+
+	return header.Parse(bytes.TrimSuffix(buf.Bytes(), lb), header.Break(lb))
+}
+
+// splitBreak splits the line break off the end of a line.
+func splitBreak(line []byte) ([]byte, []byte) {
+	content := bytes.TrimRight(line, "\r\n")
+	return content, line[len(content):]
+}
+
+// partBody is the io.Reader for the body of a single part returned by
+// PartScanner.Next. The line break before a boundary belongs to the boundary,
+// so it is held back until the next line is known not to be a boundary.
+type partBody struct {
+	ps      *PartScanner
+	buf     []byte
+	pending []byte
+	eof     bool
+	final   bool
+}
+
+// Read reads the body of the part up to the next boundary.
+func (pb *partBody) Read(p []byte) (int, error) {
+	for len(pb.buf) == 0 {
+		if pb.eof {
+			return 0, io.EOF
+		}
+
+		line, err := pb.ps.readLine()
+		if err != nil {
+			return 0, err
+		}
+
+		content, br := splitBreak(line)
+		if isB, isFinal := pb.ps.isBoundary(content); isB {
+			pb.eof = true
+			pb.final = isFinal
+			break
+		}
+
+		pb.buf = append(pb.buf[:0], pb.pending...)
+		pb.buf = append(pb.buf, content...)
+		pb.pending = append(pb.pending[:0], br...)
+	}
+
+	if len(pb.buf) == 0 {
+		return 0, io.EOF
+	}
+
+	n := copy(p, pb.buf)
+	pb.buf = pb.buf[n:]
+	return n, nil
+}
@@ -0,0 +1,103 @@
+package message_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/go-email/v2/message"
+)
+
+func TestPartReader(t *testing.T) {
+	t.Parallel()
+
+	const body = "This is a preamble.\r\n" +
+		"--abc\r\n" +
+		"Content-type: text/plain\r\n" +
+		"\r\n" +
+		"First part.\r\n" +
+		"Second line.\r\n" +
+		"--abc\r\n" +
+		"Content-type: text/html\r\n" +
+		"\r\n" +
+		"<p>Second part.</p>\r\n" +
+		"--abc--\r\n" +
+		"This is an epilogue.\r\n"
+
+	ps, err := message.PartReader(strings.NewReader(body), "abc")
+	require.NoError(t, err)
+
+	h, r, err := ps.Next()
+	require.NoError(t, err)
+
+	mt, err := h.GetMediaType()
+	assert.NoError(t, err)
+	assert.Equal(t, "text/plain", mt)
+
+	content, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "First part.\r\nSecond line.", string(content))
+
+	h, r, err = ps.Next()
+	require.NoError(t, err)
+
+	mt, err = h.GetMediaType()
+	assert.NoError(t, err)
+	assert.Equal(t, "text/html", mt)
+
+	content, err = io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "<p>Second part.</p>", string(content))
+
+	_, _, err = ps.Next()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestPartReader_SkipUnread(t *testing.T) {
+	t.Parallel()
+
+	const body = "--abc\n" +
+		"Content-type: text/plain\n" +
+		"\n" +
+		"Skipped.\n" +
+		"--abc\n" +
+		"\n" +
+		"No header.\n" +
+		"--abc--\n"
+
+	ps, err := message.PartReader(strings.NewReader(body), "abc")
+	require.NoError(t, err)
+
+	_, _, err = ps.Next()
+	require.NoError(t, err)
+
+	h, r, err := ps.Next()
+	require.NoError(t, err)
+	assert.Equal(t, 0, h.Len())
+
+	content, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "No header.", string(content))
+
+	_, _, err = ps.Next()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestPartReader_Errors(t *testing.T) {
+	t.Parallel()
+
+	_, err := message.PartReader(strings.NewReader(""), "")
+	assert.ErrorIs(t, err, message.ErrNoBoundary)
+
+	ps, err := message.PartReader(strings.NewReader("--abc\n\nTruncated.\n"), "abc")
+	require.NoError(t, err)
+
+	_, r, err := ps.Next()
+	require.NoError(t, err)
+
+	_, err = io.ReadAll(r)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,119 @@
+package message
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/zostay/go-email/v2/message/header"
+	"github.com/zostay/go-email/v2/message/transfer"
+)
+
+// Errors returned by ReEncode.
+var (
+	// ErrReEncodeMultipart is returned by ReEncode when given a multipart
+	// part, which may not have a transfer encoding of its own.
+	ErrReEncodeMultipart = errors.New("cannot re-encode a multipart part")
+
+	// ErrUnknownTransferEncoding is returned by ReEncode when the target
+	// transfer encoding is not found in transfer.Transcodings.
+	ErrUnknownTransferEncoding = errors.New("unknown transfer encoding")
+)
+
+// ReEncode returns a copy of the part with its content decoded from its current
+// Content-transfer-encoding and encoded again using the target encoding, which
+// should be one of the encodings named in the transfer package, such as
+// transfer.Base64 or transfer.QuotedPrintable. The Content-transfer-encoding
+// header of the copy is set to the target or removed if the target is
+// transfer.None. This is useful with Transform for normalizing the encoding of
+// every part of a message:
+//
+//	msg, err := message.Transform(msg, message.Rule{
+//		Match: message.MatchMediaType("text/*"),
+//		Apply: func(part message.Generic) (message.Generic, error) {
+//			return message.ReEncode(part, transfer.QuotedPrintable)
+//		},
+//	})
+//
+// If the part was encoded (i.e., IsEncoded() returns true), the copy holds the
+// newly encoded bytes, using the line break of the header. Otherwise, the copy
+// holds the decoded bytes and the target encoding is applied when the copy is
+// written.
+//
+// The decoded content must be suitable for the target. If the target is
+// transfer.Bit7 or transfer.Bit8, the content is checked as for
+// ValidateTransferEncoding and a *TransferEncodingError describing every
+// problem is returned if it breaks the rules of the target.
+//
+// It returns ErrReEncodeMultipart if the part is a multipart and
+// ErrUnknownTransferEncoding if the target is not a known encoding. In order
+// to re-encode an *Opaque part, its io.Reader must be read completely. The
+// Reader will be replaced with an in-memory copy of the same bytes so the part
+// may still be used afterwards.
+func ReEncode(part Generic, target string) (Generic, error) {
+	if part.IsMultipart() {
+		return nil, ErrReEncodeMultipart
+	}
+
+	target = strings.ToLower(strings.TrimSpace(target))
+	tc, known := transfer.Transcodings[target]
+	if !known {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownTransferEncoding, target)
+	}
+
+	content, err := readDecodedContent(part)
+	if err != nil {
+		return nil, err
+	}
+
+	switch target {
+	case transfer.None, transfer.Bit7, transfer.Bit8:
+		cte := target
+		if cte == transfer.None {
+			cte = transfer.Bit7
+		}
+
+		violations := []string{}
+		checkLines(content, cte, func(f string, args ...any) {
+			violations = append(violations,
+				fmt.Sprintf("cannot re-encode as %s: %s", cte, fmt.Sprintf(f, args...)))
+		})
+
+		if len(violations) > 0 {
+			return nil, &TransferEncodingError{violations}
+		}
+	}
+
+	h := part.GetHeader().Clone()
+	if target == transfer.None {
+		h.Unset(header.ContentTransferEncoding)
+	} else {
+		h.SetTransferEncoding(target)
+	}
+
+	if !part.IsEncoded() {
+		return NewOpaque(h, bytes.NewReader(content), false), nil
+	}
+
+	buf := &bytes.Buffer{}
+	w := tc.Encoder(buf)
+	_, _ = w.Write(content)
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	encoded := buf.Bytes()
+	switch target {
+	case transfer.Base64, transfer.QuotedPrintable:
+		// the encoders pick their own line breaks, so use the one of the header
+		lbr := h.Break().Bytes()
+		encoded = normalizeBreaks(encoded, lbr)
+		if target == transfer.Base64 && len(encoded) > 0 &&
+			!bytes.HasSuffix(encoded, lbr) {
+			encoded = append(encoded, lbr...)
+		}
+	}
+
+	return NewOpaque(h, bytes.NewReader(encoded), true), nil
+}
@@ -0,0 +1,99 @@
+package message_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/go-email/v2/message"
+	"github.com/zostay/go-email/v2/message/transfer"
+)
+
+func TestReEncode(t *testing.T) {
+	t.Parallel()
+
+	const src = "Content-type: text/plain; charset=utf-8\n" +
+		"Content-transfer-encoding: quoted-printable\n" +
+		"\n" +
+		"Caf=C3=A9 menu.\n"
+
+	m, err := message.Parse(strings.NewReader(src))
+	require.NoError(t, err)
+
+	b64, err := message.ReEncode(m, transfer.Base64)
+	require.NoError(t, err)
+	assert.True(t, b64.IsEncoded())
+
+	// and back again
+	qp, err := message.ReEncode(b64, transfer.QuotedPrintable)
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	_, err = b64.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "Content-type: text/plain; charset=utf-8\n"+
+		"Content-transfer-encoding: base64\n"+
+		"\n"+
+		"Q2Fmw6kgbWVudS4K\n", buf.String())
+
+	buf.Reset()
+	_, err = qp.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, src, buf.String())
+
+	eight, err := message.ReEncode(m, transfer.Bit8)
+	require.NoError(t, err)
+	body, err := io.ReadAll(eight.GetReader())
+	assert.NoError(t, err)
+	assert.Equal(t, "Café menu.\n", string(body))
+
+	_, err = message.ReEncode(m, transfer.Bit7)
+	var teErr *message.TransferEncodingError
+	require.ErrorAs(t, err, &teErr)
+	assert.Equal(t, []string{"cannot re-encode as 7bit: line 1 contains non-ASCII bytes"}, teErr.Violations)
+
+	// the original may still be read
+	body, err = io.ReadAll(m.GetReader())
+	assert.NoError(t, err)
+	assert.Equal(t, "Caf=C3=A9 menu.\n", string(body))
+}
+
+func TestReEncode_Decoded(t *testing.T) {
+	t.Parallel()
+
+	const src = "Content-transfer-encoding: base64\n" +
+		"\n" +
+		"AAEC/w==\n"
+
+	m, err := message.Parse(strings.NewReader(src), message.DecodeTransferEncoding())
+	require.NoError(t, err)
+
+	qp, err := message.ReEncode(m, transfer.QuotedPrintable)
+	require.NoError(t, err)
+	assert.False(t, qp.IsEncoded())
+
+	body, err := io.ReadAll(qp.GetReader())
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0, 1, 2, 0xff}, body)
+
+	_, err = message.ReEncode(m, transfer.Bit8)
+	var teErr *message.TransferEncodingError
+	assert.ErrorAs(t, err, &teErr)
+}
+
+func TestReEncode_Errors(t *testing.T) {
+	t.Parallel()
+
+	m, err := message.Parse(strings.NewReader(transformSrc))
+	require.NoError(t, err)
+
+	_, err = message.ReEncode(m, transfer.Base64)
+	assert.ErrorIs(t, err, message.ErrReEncodeMultipart)
+
+	_, err = message.ReEncode(m.GetParts()[0], "x-uuencode")
+	assert.ErrorIs(t, err, message.ErrUnknownTransferEncoding)
+}
@@ -0,0 +1,213 @@
+package message
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// cidAttrPattern matches an HTML attribute whose value is a cid: URL. The
+// submatches hold the attribute name and equals sign, followed by the ID when
+// it is double quoted, single quoted, or unquoted, respectively.
+var cidAttrPattern = regexp.MustCompile(
+	`(?i)(\b(?:src|href|background)\s*=\s*)(?:"cid:([^"]*)"|'cid:([^']*)'|cid:([^\s"'>]+))`)
+
+// DuplicateContentIDError is returned by InlineImagesByCID when more than one
+// part has the same Content-id. This is a warning: the map is still returned
+// and each duplicated ID maps to the first part found with it.
+type DuplicateContentIDError struct {
+	IDs []string
+}
+
+// Error lists the duplicated IDs.
+func (err *DuplicateContentIDError) Error() string {
+	return "duplicate Content-id: " + strings.Join(err.IDs, ", ")
+}
+
+// ErrNoRelatedRoot is returned by RelatedRoot when the multipart/related
+// names a root part that cannot be found.
+var ErrNoRelatedRoot = errors.New("multipart/related root part not found")
+
+// RelatedRoot returns the root part of a multipart/related message, as
+// described in RFC 2387. This is the part to display, with the other parts
+// holding the resources it refers to, e.g., the HTML of an MHTML bundle.
+//
+// If the Content-type of the multipart has a start parameter, the root is the
+// part whose Content-id matches it. The IDs are compared without the angle
+// brackets around them. If no part has a matching Content-id, the first part
+// with the media type named by the type parameter of the Content-type is
+// returned instead. If there is no such part either, it returns nil and
+// ErrNoRelatedRoot.
+//
+// If there is no start parameter, the root is the first part. If the multipart
+// has no parts, it returns nil and ErrNoParts.
+func RelatedRoot(mp *Multipart) (Generic, error) {
+	parts := mp.GetParts()
+	if len(parts) == 0 {
+		return nil, ErrNoParts
+	}
+
+	ct, err := mp.GetHeader().GetContentType()
+	if err != nil || ct.Parameter("start") == "" {
+		return parts[0], nil
+	}
+
+	start := trimAngleBrackets(ct.Parameter("start"))
+	for _, p := range parts {
+		id, err := p.GetHeader().GetContentID()
+		if err == nil && trimAngleBrackets(id) == start {
+			return p, nil
+		}
+	}
+
+	if rt := ct.Parameter("type"); rt != "" {
+		for _, p := range parts {
+			mt, err := p.GetHeader().GetMediaType()
+			if err == nil && strings.EqualFold(mt, rt) {
+				return p, nil
+			}
+		}
+	}
+
+	return nil, ErrNoRelatedRoot
+}
+
+// InlineImagesByCID walks the message and returns a map of every part with a
+// Content-id header, keyed by the ID without the angle brackets around it. This
+// is intended for rendering HTML, where an image is referred to as
+// src="cid:ID", such as when inlining the images into a standalone HTML
+// document. Multipart parts are also included if they have a Content-id.
+//
+// If the same ID is found on more than one part, the first part in the message
+// is kept and the map is returned with a *DuplicateContentIDError naming the
+// IDs that were repeated. It never returns any other error.
+func InlineImagesByCID(msg Generic) (map[string]Generic, error) {
+	var (
+		cids = map[string]Generic{}
+		dups []string
+	)
+
+	var walk func(Part)
+	walk = func(part Part) {
+		id, err := part.GetHeader().GetContentID()
+		if id = trimAngleBrackets(id); err == nil && id != "" {
+			if _, seen := cids[id]; !seen {
+				cids[id] = part
+			} else {
+				dups = append(dups, id)
+			}
+		}
+
+		if part.IsMultipart() {
+			for _, p := range part.GetParts() {
+				walk(p)
+			}
+		}
+	}
+	walk(msg)
+
+	if len(dups) > 0 {
+		return cids, &DuplicateContentIDError{dups}
+	}
+
+	return cids, nil
+}
+
+// InlineCIDReferences returns the content of the given HTML part with each
+// reference to a cid: URL replaced with a data: URL holding the part it refers
+// to, which turns a multipart/related HTML message into a standalone HTML
+// document. The images are looked up by ID in the given map, which is usually
+// the one returned by InlineImagesByCID.
+//
+// References are found in the src, href, and background attributes of the
+// HTML. The ID in a cid: URL is URL unescaped before it is looked up, as
+// described in RFC 2392. The data: URL is built from the content of the
+// matching part, with its Content-transfer-encoding decoded, encoded in
+// base64. The media type of the data: URL is the media type from the
+// Content-type of the matching part or application/octet-stream if it has
+// none. A reference with no matching part in the map is left as-is.
+//
+// The Content-transfer-encoding of the HTML part is decoded, but the charset is
+// not changed, so the returned bytes are in the same charset as the original
+// HTML.
+//
+// In order to read an *Opaque part, its io.Reader must be read completely. The
+// Reader will be replaced with an in-memory copy of the same bytes so the part
+// may still be used afterwards. It returns an error if there is a problem
+// reading any part.
+func InlineCIDReferences(htmlPart Generic, images map[string]Generic) ([]byte, error) {
+	content, err := readDecodedContent(htmlPart)
+	if err != nil {
+		return nil, err
+	}
+
+	dataURLs := map[string]string{}
+	dataURL := func(id string) (string, bool, error) {
+		if u, done := dataURLs[id]; done {
+			return u, true, nil
+		}
+
+		img, found := images[id]
+		if !found {
+			return "", false, nil
+		}
+
+		data, err := readDecodedContent(img)
+		if err != nil {
+			return "", false, err
+		}
+
+		mt, err := img.GetHeader().GetMediaType()
+		if err != nil || mt == "" {
+			mt = "application/octet-stream"
+		}
+
+		u := "data:" + strings.ToLower(mt) + ";base64," +
+			base64.StdEncoding.EncodeToString(data)
+		dataURLs[id] = u
+		return u, true, nil
+	}
+
+	var readErr error
+	html := cidAttrPattern.ReplaceAllFunc(content, func(m []byte) []byte {
+		sm := cidAttrPattern.FindSubmatch(m)
+		id, quote := sm[2], `"`
+		switch {
+		case sm[3] != nil:
+			id, quote = sm[3], "'"
+		case sm[4] != nil:
+			id = sm[4]
+		}
+
+		cid, err := url.PathUnescape(string(id))
+		if err != nil {
+			cid = string(id)
+		}
+
+		u, found, err := dataURL(trimAngleBrackets(cid))
+		if err != nil {
+			readErr = err
+		}
+		if !found {
+			return m
+		}
+
+		return []byte(string(sm[1]) + quote + u + quote)
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	return html, nil
+}
+
+// trimAngleBrackets removes the whitespace and angle brackets surrounding a
+// message or content ID.
+func trimAngleBrackets(id string) string {
+	id = strings.TrimSpace(id)
+	id = strings.TrimPrefix(id, "<")
+	return strings.TrimSuffix(id, ">")
+}
@@ -0,0 +1,195 @@
+package message_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/go-email/v2/message"
+)
+
+func TestRelatedRoot(t *testing.T) {
+	t.Parallel()
+
+	const parts = `
+--rel
+Content-type: image/png
+Content-id: <logo@example.com>
+
+PNG
+--rel
+Content-type: text/html
+Content-id: <root@example.com>
+
+<img src="cid:logo@example.com">
+--rel--
+`
+
+	tests := []struct {
+		name   string
+		ct     string
+		expect string
+		err    error
+	}{
+		{
+			name:   "start",
+			ct:     `multipart/related; boundary=rel; type="text/html"; start="<root@example.com>"`,
+			expect: `<img src="cid:logo@example.com">`,
+		},
+		{
+			name:   "start without brackets",
+			ct:     `multipart/related; boundary=rel; start="logo@example.com"`,
+			expect: "PNG",
+		},
+		{
+			name:   "no start",
+			ct:     `multipart/related; boundary=rel; type="text/html"`,
+			expect: "PNG",
+		},
+		{
+			name:   "missing start falls back to type",
+			ct:     `multipart/related; boundary=rel; type="text/html"; start="<gone@example.com>"`,
+			expect: `<img src="cid:logo@example.com">`,
+		},
+		{
+			name: "missing start",
+			ct:   `multipart/related; boundary=rel; start="<gone@example.com>"`,
+			err:  message.ErrNoRelatedRoot,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			m, err := message.Parse(strings.NewReader("Content-type: " + test.ct + "\n" + parts))
+			require.NoError(t, err)
+			mp, isMultipart := m.(*message.Multipart)
+			require.True(t, isMultipart)
+
+			p, err := message.RelatedRoot(mp)
+			if test.err != nil {
+				assert.ErrorIs(t, err, test.err)
+				assert.Nil(t, p)
+				return
+			}
+
+			require.NoError(t, err)
+			body, err := io.ReadAll(p.GetReader())
+			assert.NoError(t, err)
+			assert.Equal(t, test.expect, string(body))
+		})
+	}
+}
+
+func TestRelatedRoot_NoParts(t *testing.T) {
+	t.Parallel()
+
+	mp := &message.Multipart{}
+	p, err := message.RelatedRoot(mp)
+	assert.ErrorIs(t, err, message.ErrNoParts)
+	assert.Nil(t, p)
+}
+
+func TestInlineImagesByCID(t *testing.T) {
+	t.Parallel()
+
+	const src = `Content-type: multipart/related; boundary=rel
+
+--rel
+Content-type: text/html
+
+<img src="cid:logo@example.com"><img src="cid:photo@example.com">
+--rel
+Content-type: image/png
+Content-id: <logo@example.com>
+
+LOGO
+--rel
+Content-type: image/jpeg
+Content-id: photo@example.com
+
+PHOTO
+--rel
+Content-type: image/png
+Content-id: <logo@example.com>
+
+OTHER
+--rel--
+`
+
+	m, err := message.Parse(strings.NewReader(src), message.WithUnlimitedRecursion())
+	require.NoError(t, err)
+
+	cids, err := message.InlineImagesByCID(m)
+	var dupErr *message.DuplicateContentIDError
+	require.ErrorAs(t, err, &dupErr)
+	assert.Equal(t, []string{"logo@example.com"}, dupErr.IDs)
+
+	require.Len(t, cids, 2)
+	for id, expect := range map[string]string{
+		"logo@example.com":  "LOGO",
+		"photo@example.com": "PHOTO",
+	} {
+		require.Contains(t, cids, id)
+		content, err := io.ReadAll(cids[id].GetReader())
+		require.NoError(t, err)
+		assert.Equal(t, expect, string(content))
+	}
+
+	one, err := message.InlineImagesByCID(cids["photo@example.com"])
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]message.Generic{
+		"photo@example.com": cids["photo@example.com"],
+	}, one)
+}
+
+func TestInlineCIDReferences(t *testing.T) {
+	t.Parallel()
+
+	const src = `Content-type: multipart/related; boundary=rel
+
+--rel
+Content-type: text/html
+Content-transfer-encoding: quoted-printable
+
+<img src=3D"cid:logo@example.com"><img src=3D'cid:photo%40example.com'>=
+<img src=3Dcid:missing@example.com><a href=3D"http://example.com/">x</a>
+--rel
+Content-type: image/PNG
+Content-id: <logo@example.com>
+Content-transfer-encoding: base64
+
+AAEC/w==
+--rel
+Content-id: <photo@example.com>
+
+PHOTO
+--rel--
+`
+
+	m, err := message.Parse(strings.NewReader(src), message.WithUnlimitedRecursion())
+	require.NoError(t, err)
+
+	cids, err := message.InlineImagesByCID(m)
+	require.NoError(t, err)
+
+	root, err := message.RelatedRoot(m.(*message.Multipart))
+	require.NoError(t, err)
+
+	html, err := message.InlineCIDReferences(root, cids)
+	require.NoError(t, err)
+	assert.Equal(t,
+		`<img src="data:image/png;base64,AAEC/w=="><img src='data:application/octet-stream;base64,UEhPVE8='>`+
+			`<img src=cid:missing@example.com><a href="http://example.com/">x</a>`,
+		string(html))
+
+	// the parts may still be read afterwards
+	again, err := message.InlineCIDReferences(root, cids)
+	require.NoError(t, err)
+	assert.Equal(t, html, again)
+}
@@ -1,6 +1,30 @@
 package message
 
-import "io"
+import (
+	"errors"
+	"io"
+)
+
+// errRewindOnly is returned when seeking a remainder anywhere other than the
+// start.
+var errRewindOnly = errors.New("remainder may only seek to the start")
+
+// newRemainder returns a remainder for the given prefix and io.Reader. If the
+// io.Reader is also an io.Seeker, the remainder returned can be rewound to the
+// start.
+func newRemainder(prefix []byte, r io.Reader) io.Reader {
+	if s, isSeeker := r.(io.ReadSeeker); isSeeker {
+		if pos, err := s.Seek(0, io.SeekCurrent); err == nil {
+			return &seekingRemainder{
+				remainder: remainder{prefix, r},
+				prefix:    prefix,
+				start:     pos,
+			}
+		}
+	}
+
+	return &remainder{prefix, r}
+}
 
 // remainder takes the bytes already read from an io.Reader and make a new
 // reader that returns those bytes first and then passes the reads from the
@@ -38,3 +62,27 @@ func (r *remainder) Close() error {
 	}
 	return nil
 }
+
+// seekingRemainder is a remainder over an io.Seeker that may be rewound to the
+// start, which allows the body of a message parsed from in-memory bytes or a
+// file to be read more than once.
+type seekingRemainder struct {
+	remainder
+	prefix []byte
+	start  int64
+}
+
+// Seek implements io.Seeker, but only to rewind to the start, i.e.,
+// Seek(0, io.SeekStart). Any other seek fails with an error.
+func (r *seekingRemainder) Seek(offset int64, whence int) (int64, error) {
+	if offset != 0 || whence != io.SeekStart {
+		return 0, errRewindOnly
+	}
+
+	if _, err := r.r.(io.Seeker).Seek(r.start, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	r.remainder.prefix = r.prefix
+	return 0, nil
+}
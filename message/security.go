@@ -0,0 +1,74 @@
+package message
+
+import (
+	"strings"
+
+	"github.com/zostay/go-email/v2/message/header"
+	"github.com/zostay/go-email/v2/message/header/param"
+)
+
+// Security identifies the kind of cryptographic protection applied to a
+// message, as determined by SecurityKind.
+type Security int
+
+const (
+	// SecurityNone indicates that the message is neither signed nor
+	// encrypted.
+	SecurityNone Security = iota
+
+	// SecurityPGPSigned indicates a multipart/signed message with a protocol
+	// of application/pgp-signature.
+	SecurityPGPSigned
+
+	// SecurityPGPEncrypted indicates a multipart/encrypted message.
+	SecurityPGPEncrypted
+
+	// SecuritySMIMESigned indicates a multipart/signed message with a protocol
+	// of application/pkcs7-signature or an application/pkcs7-mime message with
+	// an smime-type of signed-data.
+	SecuritySMIMESigned
+
+	// SecuritySMIMEEncrypted indicates an application/pkcs7-mime message with
+	// an smime-type of enveloped-data or authEnveloped-data.
+	SecuritySMIMEEncrypted
+)
+
+// SecurityKind classifies the cryptographic protection applied to the message
+// by inspecting the media type of the top-level Content-type header and its
+// protocol and smime-type parameters. The parameters are compared without
+// regard to case. The x-pkcs7-* media types used by older software are treated
+// the same as their pkcs7-* counterparts. An application/pkcs7-mime part
+// without an smime-type parameter is treated as encrypted, as that is the most
+// common use of it.
+//
+// This only classifies the message. It does not verify signatures or decrypt
+// anything. It returns SecurityNone if the Content-type header is missing or
+// cannot be parsed or the message is not protected in a way it recognizes.
+func SecurityKind(msg Generic) Security {
+	pv, err := msg.GetHeader().GetParamValue(header.ContentType)
+	if err != nil {
+		return SecurityNone
+	}
+
+	protocol := strings.ToLower(pv.Parameter(param.Protocol))
+	switch strings.ToLower(pv.MediaType()) {
+	case "multipart/signed":
+		switch protocol {
+		case "application/pgp-signature":
+			return SecurityPGPSigned
+		case "application/pkcs7-signature", "application/x-pkcs7-signature":
+			return SecuritySMIMESigned
+		}
+	case "multipart/encrypted":
+		return SecurityPGPEncrypted
+	case "application/pkcs7-mime", "application/x-pkcs7-mime":
+		switch strings.ToLower(pv.Parameter(param.SMIMEType)) {
+		case "signed-data":
+			return SecuritySMIMESigned
+		case "enveloped-data", "authenveloped-data", "":
+			return SecuritySMIMEEncrypted
+		}
+	}
+
+	return SecurityNone
+}
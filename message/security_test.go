@@ -0,0 +1,40 @@
+package message_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zostay/go-email/v2/message"
+)
+
+func TestSecurityKind(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		contentType string
+		expect      message.Security
+	}{
+		{"", message.SecurityNone},
+		{"text/plain", message.SecurityNone},
+		{"multipart/mixed; boundary=x", message.SecurityNone},
+		{`multipart/signed; micalg=pgp-sha256; protocol="application/pgp-signature"; boundary=x`, message.SecurityPGPSigned},
+		{`Multipart/Signed; protocol="Application/PGP-Signature"; boundary=x`, message.SecurityPGPSigned},
+		{`multipart/encrypted; protocol="application/pgp-encrypted"; boundary=x`, message.SecurityPGPEncrypted},
+		{`multipart/signed; protocol="application/pkcs7-signature"; micalg=sha-256; boundary=x`, message.SecuritySMIMESigned},
+		{`multipart/signed; protocol="application/x-pkcs7-signature"; boundary=x`, message.SecuritySMIMESigned},
+		{`multipart/signed; protocol="application/unknown"; boundary=x`, message.SecurityNone},
+		{`application/pkcs7-mime; smime-type=signed-data; name=smime.p7m`, message.SecuritySMIMESigned},
+		{`application/pkcs7-mime; smime-type=enveloped-data; name=smime.p7m`, message.SecuritySMIMEEncrypted},
+		{`application/x-pkcs7-mime; name=smime.p7m`, message.SecuritySMIMEEncrypted},
+		{`application/pkcs7-mime; smime-type=certs-only`, message.SecurityNone},
+	}
+
+	for _, test := range tests {
+		buf := &message.Buffer{}
+		if test.contentType != "" {
+			buf.Set("Content-type", test.contentType)
+		}
+		assert.Equal(t, test.expect, message.SecurityKind(buf), test.contentType)
+	}
+}
@@ -0,0 +1,83 @@
+package message
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrNotSigned is returned by SignedContent when the message is not
+	// multipart/signed.
+	ErrNotSigned = errors.New("message is not multipart/signed")
+
+	// ErrSignedParts is returned by SignedContent when a multipart/signed
+	// message does not have both the protected content and the signature parts.
+	ErrSignedParts = errors.New("multipart/signed message must have two parts")
+)
+
+// SignedContent returns the protected content and the detached signature of a
+// multipart/signed message (RFC 1847), such as is used for PGP/MIME and S/MIME
+// signatures, so that the signature can be verified.
+//
+// The content is the first part of the message, including its header. When the
+// message was parsed, these are the original bytes of that part as they were
+// read, not a re-serialized form, because signature verification depends on
+// every byte. As required for verification, every line break in the content is
+// canonicalized to CRLF. The line break that precedes the boundary after the
+// content belongs to the boundary and is not included. If the message was not
+// parsed or the first part has since been replaced (e.g., by Transform()), the
+// first part is serialized with WriteTo() instead.
+//
+// The signature is the body of the second part with any
+// Content-transfer-encoding decoded, e.g., the ASCII-armored PGP signature or
+// the DER-encoded S/MIME signature. In order to read the signature, the
+// io.Reader of the part must be read completely. The Reader will be replaced
+// with an in-memory copy of the same bytes so the part may still be used
+// afterwards.
+//
+// It returns ErrNotSigned if the Content-type of the message is not
+// multipart/signed. It returns ErrSignedParts if the message has fewer than two
+// parts. It returns an error if there is a problem reading either part.
+func SignedContent(mp *Multipart) ([]byte, string, error) {
+	mt, err := mp.GetMediaType()
+	if err != nil || !strings.EqualFold(mt, "multipart/signed") {
+		return nil, "", ErrNotSigned
+	}
+
+	parts := mp.GetParts()
+	if len(parts) < 2 {
+		return nil, "", ErrSignedParts
+	}
+
+	var content []byte
+	if len(mp.raw) > 0 && mp.raw[0] != nil {
+		content = mp.raw[0]
+	} else {
+		buf := &bytes.Buffer{}
+		if _, err := parts[0].WriteTo(buf); err != nil {
+			return nil, "", err
+		}
+		content = buf.Bytes()
+	}
+
+	sig, err := readDecodedContent(parts[1])
+	if err != nil {
+		return nil, "", err
+	}
+
+	return canonicalizeCRLF(content), string(sig), nil
+}
+
+// canonicalizeCRLF returns a copy of the bytes with every bare LF replaced with
+// a CRLF.
+func canonicalizeCRLF(b []byte) []byte {
+	out := make([]byte, 0, len(b)+bytes.Count(b, []byte{'\n'}))
+	for i, c := range b {
+		if c == '\n' && (i == 0 || b[i-1] != '\r') {
+			out = append(out, '\r')
+		}
+		out = append(out, c)
+	}
+	return out
+}
@@ -0,0 +1,144 @@
+package message_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/go-email/v2/message"
+	"github.com/zostay/go-email/v2/message/transfer"
+)
+
+const signedSrc = `Subject: signed
+Content-type: multipart/signed; micalg=pgp-sha256;
+  protocol="application/pgp-signature"; boundary=sig
+
+--sig
+Content-type: text/plain;   charset=us-ascii
+
+Signed text.
+--sig
+Content-type: application/pgp-signature
+
+-----BEGIN PGP SIGNATURE-----
+abc
+-----END PGP SIGNATURE-----
+--sig--
+`
+
+func TestSignedContent(t *testing.T) {
+	t.Parallel()
+
+	m, err := message.Parse(strings.NewReader(signedSrc))
+	require.NoError(t, err)
+
+	mp, isMultipart := m.(*message.Multipart)
+	require.True(t, isMultipart)
+
+	// modifying the part must not change the signed bytes
+	mp.GetParts()[0].GetHeader().SetSubject("tampered")
+
+	content, sig, err := message.SignedContent(mp)
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"Content-type: text/plain;   charset=us-ascii\r\n\r\nSigned text.",
+		string(content))
+	assert.Equal(t,
+		"-----BEGIN PGP SIGNATURE-----\nabc\n-----END PGP SIGNATURE-----",
+		sig)
+}
+
+func TestSignedContent_SMIME(t *testing.T) {
+	t.Parallel()
+
+	const src = "Subject: signed\r\n" +
+		"Content-type: multipart/signed; protocol=\"application/pkcs7-signature\";\r\n" +
+		" micalg=sha-256; boundary=sig\r\n" +
+		"\r\n" +
+		"--sig\r\n" +
+		"Content-type: text/plain\r\n" +
+		"\r\n" +
+		"Signed text.\r\n" +
+		"--sig\r\n" +
+		"Content-type: application/pkcs7-signature; name=smime.p7s\r\n" +
+		"Content-transfer-encoding: base64\r\n" +
+		"\r\n" +
+		"AAEC/w==\r\n" +
+		"--sig--\r\n"
+
+	m, err := message.Parse(strings.NewReader(src))
+	require.NoError(t, err)
+
+	content, sig, err := message.SignedContent(m.(*message.Multipart))
+	assert.NoError(t, err)
+	assert.Equal(t, "Content-type: text/plain\r\n\r\nSigned text.", string(content))
+	assert.Equal(t, "\x00\x01\x02\xff", sig)
+}
+
+func TestSignedContent_NotSigned(t *testing.T) {
+	t.Parallel()
+
+	m, err := message.Parse(strings.NewReader(transformSrc))
+	require.NoError(t, err)
+
+	_, _, err = message.SignedContent(m.(*message.Multipart))
+	assert.ErrorIs(t, err, message.ErrNotSigned)
+
+	buf := &message.Buffer{}
+	buf.SetMediaType("multipart/signed")
+	buf.Add(&message.Buffer{})
+	mp, err := buf.Multipart()
+	require.NoError(t, err)
+
+	_, _, err = message.SignedContent(mp)
+	assert.ErrorIs(t, err, message.ErrSignedParts)
+}
+
+func TestSignedContent_Transform(t *testing.T) {
+	t.Parallel()
+
+	m, err := message.Parse(strings.NewReader(signedSrc))
+	require.NoError(t, err)
+
+	tm, err := message.Transform(m, message.Rule{
+		Match: message.MatchMediaType("application/pgp-signature"),
+		Apply: func(part message.Generic) (message.Generic, error) {
+			return message.ReEncode(part, transfer.Base64)
+		},
+	})
+	require.NoError(t, err)
+
+	mp, isMultipart := tm.(*message.Multipart)
+	require.True(t, isMultipart)
+	require.NotSame(t, m, mp)
+
+	// the signed part is unchanged, so its original bytes are still used
+	mp.GetParts()[0].GetHeader().SetSubject("tampered")
+
+	content, sig, err := message.SignedContent(mp)
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"Content-type: text/plain;   charset=us-ascii\r\n\r\nSigned text.",
+		string(content))
+	assert.Equal(t,
+		"-----BEGIN PGP SIGNATURE-----\nabc\n-----END PGP SIGNATURE-----",
+		sig)
+
+	// once the signed part is replaced, it is serialized instead
+	tm, err = message.Transform(m, message.Rule{
+		Match: message.MatchMediaType("text/plain"),
+		Apply: func(part message.Generic) (message.Generic, error) {
+			buf := &message.Buffer{}
+			buf.SetMediaType("text/plain")
+			_, _ = buf.WriteString("Replaced.")
+			return buf.Opaque(), nil
+		},
+	})
+	require.NoError(t, err)
+
+	content, _, err = message.SignedContent(tm.(*message.Multipart))
+	assert.NoError(t, err)
+	assert.Equal(t, "Content-type: text/plain\r\n\r\nReplaced.", string(content))
+}
@@ -0,0 +1,58 @@
+package message
+
+import (
+	"fmt"
+
+	"github.com/zostay/go-addr/pkg/addr"
+
+	"github.com/zostay/go-email/v2/message/header"
+	"github.com/zostay/go-email/v2/message/transfer"
+)
+
+// Simple builds a minimal, complete message with a text/plain body in one
+// call. This is handy for tests and for sending simple notifications. The
+// from address must be a single mailbox and the to address may be a
+// comma-separated list of addresses. Both are parsed strictly and an error is
+// returned if either is not valid.
+//
+// The header of the returned message will have the From, To, Subject, Date,
+// Message-id, Content-type, and Content-transfer-encoding fields set. The Date
+// is the current time (see SetClock). The Message-id is randomly generated
+// using the domain of the from address. The Content-type is text/plain with a
+// charset of utf-8. The Content-transfer-encoding is 7bit if the body is
+// US-ASCII with no overly long lines or quoted-printable otherwise, which will
+// be applied when the message is written.
+func Simple(from, to, subject, body string) (*Opaque, error) {
+	fromMb, err := addr.ParseEmailMailbox(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid From address %q: %w", from, err)
+	}
+
+	toAl, err := addr.ParseEmailAddressList(to)
+	if err != nil {
+		return nil, fmt.Errorf("invalid To address %q: %w", to, err)
+	}
+
+	buf := &Buffer{}
+	buf.SetAddressList(header.From, fromMb)
+	buf.SetAddressList(header.To, toAl...)
+	buf.SetSubject(subject)
+	buf.SetDateNow()
+	buf.SetMessageID(fmt.Sprintf("<%d.%s@%s>",
+		header.Now().UnixNano(), GenerateBoundary(), fromMb.Domain()))
+
+	buf.SetMediaType("text/plain")
+	if err := buf.SetCharset("utf-8"); err != nil {
+		return nil, err
+	}
+
+	cte := transfer.Bit7
+	checkLines([]byte(body), transfer.Bit7, func(string, ...any) {
+		cte = transfer.QuotedPrintable
+	})
+	buf.SetTransferEncoding(cte)
+
+	_, _ = buf.WriteString(body)
+
+	return buf.Opaque(), nil
+}
@@ -0,0 +1,78 @@
+package message_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/go-email/v2/message"
+)
+
+func TestSimple(t *testing.T) {
+	t.Parallel()
+
+	m, err := message.Simple("Alice <alice@example.com>",
+		"bob@example.com, Carol <carol@example.net>", "Hello", "Hi there.")
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	_, err = m.WriteTo(buf)
+	require.NoError(t, err)
+
+	out := buf.String()
+	pm, err := message.Parse(buf)
+	require.NoError(t, err)
+	h := pm.GetHeader()
+
+	from, err := h.GetFrom()
+	require.NoError(t, err)
+	assert.Equal(t, "Alice <alice@example.com>", from.String())
+
+	to, err := h.GetTo()
+	require.NoError(t, err)
+	assert.Equal(t, "bob@example.com, Carol <carol@example.net>", to.String())
+
+	subj, err := h.GetSubject()
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello", subj)
+
+	_, err = h.GetDate()
+	assert.NoError(t, err)
+
+	id, err := h.GetMessageID()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(id, "<"))
+	assert.True(t, strings.HasSuffix(id, "@example.com>"))
+
+	assert.Equal(t, "text/plain; charset=utf-8", h.GetOr("Content-type", ""))
+	assert.Equal(t, "7bit", h.GetOr("Content-transfer-encoding", ""))
+	assert.True(t, strings.HasSuffix(out, "\n\nHi there."))
+}
+
+func TestSimple_NonASCII(t *testing.T) {
+	t.Parallel()
+
+	m, err := message.Simple("alice@example.com", "bob@example.com", "Café", "Café menu.")
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	_, err = m.WriteTo(buf)
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), "Content-transfer-encoding: quoted-printable\n")
+	assert.True(t, strings.HasSuffix(buf.String(), "\n\nCaf=C3=A9 menu."))
+}
+
+func TestSimple_BadAddress(t *testing.T) {
+	t.Parallel()
+
+	_, err := message.Simple("not an address", "bob@example.com", "Hi", "Hi.")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid From address")
+
+	_, err = message.Simple("alice@example.com", "bob@@example.com", "Hi", "Hi.")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid To address")
+}
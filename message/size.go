@@ -0,0 +1,188 @@
+package message
+
+import (
+	"io"
+	"os"
+
+	"github.com/zostay/go-email/v2/message/transfer"
+)
+
+// readerSize returns the number of bytes that remain to be read from r, if
+// known. The length is known for in-memory readers, like *bytes.Reader,
+// *strings.Reader, and *bytes.Buffer, and for files, like *os.File.
+func readerSize(r io.Reader) (int64, bool) {
+	switch sr := r.(type) {
+	case interface{ Size() int64 }:
+		return sr.Size(), true
+	case interface{ Len() int }:
+		return int64(sr.Len()), true
+	case interface {
+		io.Seeker
+		Stat() (os.FileInfo, error)
+	}:
+		fi, err := sr.Stat()
+		if err != nil || !fi.Mode().IsRegular() {
+			return 0, false
+		}
+
+		off, err := sr.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, false
+		}
+
+		return fi.Size() - off, true
+	}
+
+	return 0, false
+}
+
+// EstimatedSize returns the number of bytes WriteTo would write for the message
+// without writing it or reading any of the message's io.Reader objects. This
+// is intended for checks, like quota enforcement, to be made before committing
+// to a full WriteTo.
+//
+// The size is the sum of the size of each header plus the size of the body of
+// each *Opaque part, along with the boundary lines, preamble, and epilogue of
+// each *Multipart. The second return value is true when the size is exact. It
+// is false when the size is a best-effort estimate, which happens when:
+//
+// * The length of an io.Reader is not known, such as the body of a message
+// streamed from a network connection. Such a body is counted as 0 bytes. The
+// length is known for bodies held in memory, such as those built with a Buffer
+// and the parts of a parsed multipart message, and for bodies read from a file.
+//
+// * The content of an *Opaque will be quoted-printable encoded as it is
+// written. The decoded length is counted in this case.
+//
+// * A *Multipart has no boundary, which means WriteTo would fail.
+//
+// * Some part of the message is not an *Opaque or *Multipart, such as a
+// *Buffer. The size of such a part is roughly estimated from its header and the
+// length of its body, if known.
+//
+// A *LazyPart that has not been parsed yet, or could not be parsed, is sized
+// from its original bytes without parsing it.
+func EstimatedSize(msg Generic) (int64, bool) {
+	switch m := msg.(type) {
+	case *Opaque:
+		return opaqueSize(m)
+	case *Multipart:
+		return multipartSize(m)
+	case *MboxMessage:
+		return EstimatedSize(m.Generic)
+	case *LazyPart:
+		return lazySize(m)
+	}
+
+	return estimatePartSize(msg), false
+}
+
+// lazySize implements EstimatedSize for a *LazyPart. A part that has not been
+// parsed yet or could not be parsed is written from its original bytes, so it
+// is sized without parsing it.
+func lazySize(lp *LazyPart) (int64, bool) {
+	if lp.writesRaw() {
+		return int64(len(lp.raw)), true
+	}
+
+	return EstimatedSize(lp.resolve())
+}
+
+// opaqueSize implements EstimatedSize for an *Opaque.
+func opaqueSize(m *Opaque) (int64, bool) {
+	size, _ := m.Header.WriteTo(io.Discard)
+	if m.Reader == nil {
+		return size, true
+	}
+
+	bn, known := readerSize(m.Reader)
+	if !known {
+		return size, false
+	}
+	if m.encoded {
+		return size + bn, true
+	}
+
+	cte, err := m.GetTransferEncoding()
+	if err != nil {
+		return size + bn, true
+	}
+
+	switch cte {
+	case transfer.Base64:
+		return size + base64Size(bn, m.base64LineLength), true
+	case transfer.QuotedPrintable:
+		return size + bn, false
+	}
+
+	return size + bn, true
+}
+
+// base64Size returns the length of n bytes after base64 encoding, including the
+// line breaks inserted every lineLength characters (or the usual 76 if
+// lineLength is 0).
+func base64Size(n int64, lineLength int) int64 {
+	if lineLength == 0 {
+		lineLength = 76
+	}
+
+	enc := (n + 2) / 3 * 4
+	if enc > 0 && lineLength > 0 {
+		enc += (enc - 1) / int64(lineLength)
+	}
+	return enc
+}
+
+// multipartSize implements EstimatedSize for a *Multipart. It follows the same
+// steps as Multipart.WriteTo.
+func multipartSize(mm *Multipart) (int64, bool) {
+	size, _ := mm.Header.WriteTo(io.Discard)
+
+	boundary, err := mm.GetBoundary()
+	if err != nil {
+		return size, false
+	}
+
+	var (
+		exact = true
+		br    = int64(len(mm.Break()))
+		delim = int64(len(boundary)) + 2 // "--" + boundary
+	)
+
+	size += int64(len(mm.prefix))
+
+	if len(mm.parts) > 0 {
+		hadContent := false
+		for _, part := range mm.parts {
+			if hadContent {
+				size += br
+			}
+
+			size += delim + br
+			hadContent = partHasContent(part)
+
+			pn, pexact := EstimatedSize(part)
+			size += pn
+			exact = exact && pexact
+		}
+
+		if mm.rest != nil {
+			if hadContent {
+				size += br
+			}
+
+			size += delim + br
+			rn, known := readerSize(mm.rest)
+			size += rn
+			exact = exact && known
+		}
+
+		if mm.suffix != nil {
+			size += br + delim + 2
+		}
+	}
+
+	size += int64(len(mm.suffix))
+
+	return size, exact
+}
@@ -0,0 +1,160 @@
+package message_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/go-email/v2/message"
+	"github.com/zostay/go-email/v2/message/header"
+)
+
+func TestEstimatedSize(t *testing.T) {
+	t.Parallel()
+
+	multipart := func() message.Generic {
+		buf := &message.Buffer{}
+		buf.SetSubject("sized")
+		buf.SetMediaType("multipart/mixed")
+		_ = buf.SetBoundary("sized-boundary")
+
+		text := &message.Buffer{}
+		text.SetMediaType("text/plain")
+		_, _ = text.WriteString("Hello.\n")
+		buf.Add(text.Opaque())
+
+		att := &message.Buffer{}
+		att.SetMediaType("application/octet-stream")
+		att.SetTransferEncoding("base64")
+		_, _ = att.Write(bytes.Repeat([]byte{0, 1, 2, 3, 4}, 100))
+		buf.Add(att.Opaque())
+
+		mm, err := buf.Multipart()
+		require.NoError(t, err)
+		return mm
+	}
+
+	parsed := func() message.Generic {
+		const src = "Subject: parsed\r\n" +
+			"Content-type: multipart/mixed; boundary=outer\r\n" +
+			"\r\n" +
+			"preamble\r\n" +
+			"--outer\r\n" +
+			"Content-type: text/plain\r\n" +
+			"\r\n" +
+			"Plain.\r\n" +
+			"--outer\r\n" +
+			"Content-type: multipart/alternative; boundary=inner\r\n" +
+			"\r\n" +
+			"--inner\r\n" +
+			"Content-type: text/html\r\n" +
+			"\r\n" +
+			"<p>HTML.</p>\r\n" +
+			"--inner--\r\n" +
+			"--outer--\r\n" +
+			"epilogue\r\n"
+
+		m, err := message.Parse(strings.NewReader(src), message.WithUnlimitedRecursion())
+		require.NoError(t, err)
+		return m
+	}
+
+	tests := []struct {
+		name  string
+		msg   func() message.Generic
+		exact bool
+	}{
+		{"built multipart", multipart, true},
+		{"parsed multipart", parsed, true},
+		{"attachment file", func() message.Generic {
+			af, err := message.AttachmentFile("../test/data/att-1.gif", "image/gif", "base64")
+			require.NoError(t, err)
+			return af
+		}, true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			n, exact := message.EstimatedSize(test.msg())
+			assert.Equal(t, test.exact, exact)
+
+			out := &bytes.Buffer{}
+			_, err := test.msg().WriteTo(out)
+			require.NoError(t, err)
+			assert.Equal(t, int64(out.Len()), n)
+		})
+	}
+}
+
+func TestEstimatedSize_NotExact(t *testing.T) {
+	t.Parallel()
+
+	h := &header.Header{}
+	h.SetSubject("streamed")
+
+	const body = "streamed body\n"
+	streamed := message.NewOpaque(h, io.MultiReader(strings.NewReader(body)), true)
+	n, exact := message.EstimatedSize(streamed)
+	assert.False(t, exact)
+	assert.Equal(t, int64(len("Subject: streamed\n\n")), n)
+
+	src, err := os.Open("../test/data/mail-1")
+	require.NoError(t, err)
+	defer src.Close()
+
+	m, err := message.Parse(src)
+	require.NoError(t, err)
+	_, exact = message.EstimatedSize(m)
+	assert.False(t, exact)
+
+	qp := &message.Buffer{}
+	qp.SetTransferEncoding("quoted-printable")
+	_, _ = qp.WriteString("café\n")
+	n, exact = message.EstimatedSize(qp.Opaque())
+	assert.False(t, exact)
+	assert.Equal(t, int64(len("Content-transfer-encoding: quoted-printable\n\ncafé\n")), n)
+}
+
+func TestEstimatedSize_LazyParts(t *testing.T) {
+	t.Parallel()
+
+	src := "Content-type: multipart/mixed; boundary=x\n\n--x\n" +
+		"Content-type: text/plain\n\nbody\n--x\n" +
+		"Subject: " + strings.Repeat("long header, ", 10) + "\n\nbody\n--x--\n"
+
+	m, err := message.Parse(strings.NewReader(src),
+		message.WithLazyParts(), message.WithMaxHeaderLength(60), message.WithChunkSize(16))
+	require.NoError(t, err)
+
+	// the parts are sized without parsing them
+	n, exact := message.EstimatedSize(m)
+	assert.True(t, exact)
+	assert.Equal(t, int64(len(src)), n)
+
+	parts := m.GetParts()
+	require.Len(t, parts, 2)
+	for _, p := range parts {
+		assert.False(t, p.(*message.LazyPart).IsResolved())
+	}
+
+	// a part that fails to parse is still written from its original bytes
+	_, err = parts[1].(*message.LazyPart).Resolve()
+	require.Error(t, err)
+
+	n, exact = message.EstimatedSize(m)
+	assert.True(t, exact)
+	assert.Equal(t, int64(len(src)), n)
+
+	buf := &bytes.Buffer{}
+	_, err = m.WriteTo(buf)
+	require.NoError(t, err)
+	assert.Equal(t, src, buf.String())
+}
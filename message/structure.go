@@ -0,0 +1,50 @@
+package message
+
+import (
+	"strings"
+)
+
+// StructureString returns a textual dump of the MIME structure of the message
+// for debugging. Each part is given on its own line, indented by two spaces per
+// level of nesting, with its media type. A part with a Content-disposition also
+// gives its disposition and filename, if any. For example:
+//
+//	multipart/mixed
+//	  multipart/alternative
+//	    text/plain
+//	    text/html
+//	  application/pdf (attachment; report.pdf)
+//
+// A part without a Content-type is shown as text/plain, which is the default
+// per RFC 2045. The message's io.Reader objects are not read.
+func StructureString(msg Generic) string {
+	lines := make([]string, 0, 10)
+	structureLines(msg, 0, &lines)
+	return strings.Join(lines, "\n")
+}
+
+// structureLines implements the recursive part of StructureString.
+func structureLines(part Part, depth int, lines *[]string) {
+	h := part.GetHeader()
+
+	mt, err := h.GetMediaType()
+	if err != nil || mt == "" {
+		mt = "text/plain"
+	}
+
+	line := strings.Repeat("  ", depth) + mt
+	if pres, err := h.GetPresentation(); err == nil && pres != "" {
+		if fn, err := h.GetFilename(); err == nil && fn != "" {
+			line += " (" + pres + "; " + fn + ")"
+		} else {
+			line += " (" + pres + ")"
+		}
+	}
+	*lines = append(*lines, line)
+
+	if part.IsMultipart() {
+		for _, p := range part.GetParts() {
+			structureLines(p, depth+1, lines)
+		}
+	}
+}
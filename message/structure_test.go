@@ -0,0 +1,52 @@
+package message_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/go-email/v2/message"
+)
+
+func TestStructureString(t *testing.T) {
+	t.Parallel()
+
+	const src = `Subject: structure
+Content-type: multipart/mixed; boundary=outer
+
+--outer
+Content-type: multipart/alternative; boundary=inner
+
+--inner
+Content-type: text/plain
+
+Plain.
+--inner
+Content-type: text/html
+
+<p>HTML.</p>
+--inner--
+--outer
+Content-type: application/pdf
+Content-disposition: attachment; filename=report.pdf
+
+PDF
+--outer
+Content-disposition: inline
+
+No type.
+--outer--
+`
+
+	m, err := message.Parse(strings.NewReader(src), message.WithUnlimitedRecursion())
+	require.NoError(t, err)
+
+	assert.Equal(t, "multipart/mixed\n"+
+		"  multipart/alternative\n"+
+		"    text/plain\n"+
+		"    text/html\n"+
+		"  application/pdf (attachment; report.pdf)\n"+
+		"  text/plain (inline)", message.StructureString(m))
+}
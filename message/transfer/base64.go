@@ -17,44 +17,64 @@ type newlineWriter struct {
 }
 
 func (nw *newlineWriter) Write(b []byte) (int, error) {
-	ix, n := 0, 0
-	for len(b[ix:])+nw.acc > nw.every {
-		n := 0
-		ln, err := nw.w.Write(b[ix : ix+(nw.every-nw.acc)])
-		n += ln
-		if err != nil {
-			return n, err
+	n := 0
+	for len(b) > 0 {
+		// the line is full, so break before writing any more
+		if nw.acc == nw.every {
+			if _, err := nw.w.Write(nw.lbr); err != nil {
+				return n, err
+			}
+			nw.acc = 0
+		}
+
+		chunk := nw.every - nw.acc
+		if chunk > len(b) {
+			chunk = len(b)
 		}
 
-		_, err = nw.w.Write(nw.lbr)
+		ln, err := nw.w.Write(b[:chunk])
+		n += ln
+		nw.acc += ln
 		if err != nil {
 			return n, err
 		}
 
-		ix += nw.every - nw.acc
-		nw.acc = 0
-	}
-
-	ln, err := nw.w.Write(b[ix:])
-	n += ln
-	if err != nil {
-		return n, err
+		b = b[chunk:]
 	}
 
-	nw.acc = len(b[ix:]) % nw.every
-
 	return n, nil
 }
 
 // NewBase64Encoder will translate all bytes written to the returned
 // io.WriteCloser into base64 encoding and write those to the give io.Writer.
 func NewBase64Encoder(w io.Writer) io.WriteCloser {
-	nw := &newlineWriter{
-		every: defaultBase64LineLength,
-		lbr:   defaultBase64LineBreak,
-		w:     w,
+	return newBase64Encoder(w, defaultBase64LineLength)
+}
+
+// WithBase64LineLength returns a function that works like NewBase64Encoder,
+// except that the base64 output is wrapped every n characters rather than the
+// usual 76. If n is 0 or less, the output is not wrapped at all. The returned
+// function is suitable for use as the Encoder of a Transcoding.
+//
+// This is useful for reproducing the original wrapping of base64 content that
+// was decoded. Some software wraps base64 at 64 characters or at the line
+// length limit of 998 characters.
+func WithBase64LineLength(n int) func(io.Writer) io.WriteCloser {
+	return func(w io.Writer) io.WriteCloser {
+		return newBase64Encoder(w, n)
+	}
+}
+
+// newBase64Encoder implements NewBase64Encoder and WithBase64LineLength.
+func newBase64Encoder(w io.Writer, every int) io.WriteCloser {
+	if every > 0 {
+		w = &newlineWriter{
+			every: every,
+			lbr:   defaultBase64LineBreak,
+			w:     w,
+		}
 	}
-	bw := base64.NewEncoder(base64.StdEncoding, nw)
+	bw := base64.NewEncoder(base64.StdEncoding, w)
 	return &writer{bw, bw}
 }
 
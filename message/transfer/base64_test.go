@@ -63,3 +63,39 @@ func TestNewBase64Encoder(t *testing.T) {
 
 	assert.Equal(t, []byte(attGifBase64), w.Bytes())
 }
+
+func TestWithBase64LineLength(t *testing.T) {
+	t.Parallel()
+
+	binInput, err := os.ReadFile("../../test/data/att-1.gif")
+	assert.NoError(t, err)
+
+	expect := strings.ReplaceAll(attGifBase64, "\n", "")
+
+	for _, n := range []int{64, 998, 0} {
+		w := &bytes.Buffer{}
+		dw := transfer.WithBase64LineLength(n)(w)
+
+		// write in small pieces to make sure the line length is tracked
+		for i := 0; i < len(binInput); i += 7 {
+			end := i + 7
+			if end > len(binInput) {
+				end = len(binInput)
+			}
+			_, err = dw.Write(binInput[i:end])
+			assert.NoError(t, err)
+		}
+		assert.NoError(t, dw.Close())
+
+		lines := strings.Split(w.String(), "\n")
+		for i, line := range lines {
+			if n > 0 && i < len(lines)-1 {
+				assert.Len(t, line, n)
+			}
+		}
+		if n <= 0 {
+			assert.Len(t, lines, 1)
+		}
+		assert.Equal(t, expect, strings.Join(lines, ""))
+	}
+}
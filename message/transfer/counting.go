@@ -0,0 +1,66 @@
+package transfer
+
+import (
+	"errors"
+	"io"
+)
+
+// CountingReader is an io.Reader that counts the bytes read through it and
+// reports the count as it goes. It is created with NewCountingReader.
+type CountingReader struct {
+	r          io.Reader
+	interval   int64
+	onProgress func(n int64)
+	n          int64
+	reported   int64
+	done       bool
+}
+
+// NewCountingReader returns a CountingReader that reads from r and calls
+// onProgress with the total number of bytes read so far each time at least
+// interval more bytes have been read since the previous call. It is also called
+// with the final total when r returns io.EOF. If interval is 0 or less,
+// onProgress is called after every read that returns bytes. The onProgress
+// function may be nil, in which case the bytes are only counted.
+//
+// This is intended for reporting the progress of reading a large part, such as
+// when saving a decoded attachment to disk:
+//
+//	r := transfer.ApplyTransferDecoding(part.GetHeader(), part.GetReader())
+//	r = transfer.NewCountingReader(r, 1<<20, func(n int64) {
+//		fmt.Printf("%d bytes saved\n", n)
+//	})
+func NewCountingReader(r io.Reader, interval int64, onProgress func(n int64)) *CountingReader {
+	return &CountingReader{
+		r:          r,
+		interval:   interval,
+		onProgress: onProgress,
+	}
+}
+
+// Read reads from the wrapped io.Reader, counting the bytes read.
+func (cr *CountingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+
+	if cr.onProgress != nil {
+		switch {
+		case errors.Is(err, io.EOF):
+			if !cr.done && (cr.n > cr.reported || cr.n == 0) {
+				cr.onProgress(cr.n)
+				cr.reported = cr.n
+			}
+			cr.done = true
+		case n > 0 && cr.n-cr.reported >= cr.interval:
+			cr.onProgress(cr.n)
+			cr.reported = cr.n
+		}
+	}
+
+	return n, err
+}
+
+// Count returns the total number of bytes read so far.
+func (cr *CountingReader) Count() int64 {
+	return cr.n
+}
@@ -0,0 +1,56 @@
+package transfer_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zostay/go-email/v2/message/header"
+	"github.com/zostay/go-email/v2/message/transfer"
+)
+
+func TestNewCountingReader(t *testing.T) {
+	t.Parallel()
+
+	h := &header.Header{}
+	h.SetTransferEncoding(transfer.Base64)
+
+	var progress []int64
+	r := transfer.ApplyTransferDecoding(h, iotest.OneByteReader(strings.NewReader(enc)))
+	cr := transfer.NewCountingReader(r, 50, func(n int64) {
+		progress = append(progress, n)
+	})
+
+	b, err := io.ReadAll(cr)
+	assert.NoError(t, err)
+	assert.Equal(t, dec, string(b))
+	assert.Equal(t, int64(len(dec)), cr.Count())
+
+	if assert.NotEmpty(t, progress) {
+		for i := 1; i < len(progress)-1; i++ {
+			assert.GreaterOrEqual(t, progress[i]-progress[i-1], int64(50))
+		}
+		assert.Equal(t, int64(len(dec)), progress[len(progress)-1])
+	}
+
+	// every read is reported with an interval of 0
+	progress = nil
+	cr = transfer.NewCountingReader(iotest.OneByteReader(strings.NewReader("abc")), 0, func(n int64) {
+		progress = append(progress, n)
+	})
+	_, err = io.ReadAll(cr)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3}, progress)
+
+	// an empty reader still reports that it is done
+	progress = nil
+	cr = transfer.NewCountingReader(strings.NewReader(""), 10, func(n int64) {
+		progress = append(progress, n)
+	})
+	_, err = io.ReadAll(cr)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{0}, progress)
+}
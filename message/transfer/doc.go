@@ -5,6 +5,10 @@
 // changes to the document being encoded or decoded. Other settings such as
 // binary, 7bit, or 8bit will result in the bytes being left as-is.
 //
+// Content with a transfer encoding of binary may contain any bytes, including
+// NUL, and is not made of lines, so no line-based processing is performed on
+// it, either here or when a message is parsed and written.
+//
 // For the sake of this module, the term "decoded" means that the content has
 // been transformed from the named Content-transfer-encoding to the charset
 // encoded form. Meanwhile, "encoded" means that the content has been
@@ -0,0 +1,126 @@
+package message
+
+import (
+	"strings"
+)
+
+// Matcher is a predicate used to select the parts a Rule applies to.
+type Matcher func(part Part) bool
+
+// MatchMediaType returns a Matcher that matches any part whose media type is
+// one of the given media types. The media types are compared without regard to
+// case. A media type ending in "/*", such as "image/*", matches every subtype
+// of that type.
+func MatchMediaType(mediaTypes ...string) Matcher {
+	return func(part Part) bool {
+		mt, err := part.GetHeader().GetMediaType()
+		if err != nil {
+			return false
+		}
+
+		for _, want := range mediaTypes {
+			if strings.HasSuffix(want, "/*") {
+				if len(mt) > len(want)-1 && strings.EqualFold(mt[:len(want)-1], want[:len(want)-1]) {
+					return true
+				}
+				continue
+			}
+
+			if strings.EqualFold(mt, want) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// MatchPresentation returns a Matcher that matches any part whose
+// Content-disposition presentation (e.g., "inline" or "attachment") is one of
+// the given values. The values are compared without regard to case.
+func MatchPresentation(presentations ...string) Matcher {
+	return func(part Part) bool {
+		pres, err := part.GetHeader().GetPresentation()
+		if err != nil {
+			return false
+		}
+
+		for _, want := range presentations {
+			if strings.EqualFold(pres, want) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// Rule pairs a Matcher with the transformation to apply to every part it
+// matches. If Apply returns a nil part with no error, the part is removed from
+// the message.
+type Rule struct {
+	Match Matcher
+	Apply func(part Generic) (Generic, error)
+}
+
+// Transform applies the given rules to the leaf parts of the message (i.e.,
+// every part that is not multipart). For each leaf, the rules are checked in
+// order and every rule that matches is applied, with each rule seeing the
+// output of the one before it. If a single-part message is passed, it is
+// treated as a leaf.
+//
+// The message tree is rebuilt around the transformed parts. Any part that no
+// rule matches is kept as-is, so it will be written back out byte-for-byte as
+// it was. A multipart part is only rebuilt if one of its sub-parts changed.
+//
+// If any rule returns an error, Transform stops and returns that error.
+func Transform(msg Generic, rules ...Rule) (Generic, error) {
+	if !msg.IsMultipart() {
+		return transformLeaf(msg, rules)
+	}
+
+	parts := msg.GetParts()
+	newParts := make([]Part, 0, len(parts))
+	changed := false
+	for _, part := range parts {
+		newPart, err := Transform(part, rules...)
+		if err != nil {
+			return nil, err
+		}
+
+		if newPart != part {
+			changed = true
+		}
+
+		if newPart != nil {
+			newParts = append(newParts, newPart)
+		}
+	}
+
+	if !changed {
+		return msg, nil
+	}
+
+	return rebuildMultipart(msg, newParts), nil
+}
+
+// transformLeaf applies each matching rule to the given leaf part.
+func transformLeaf(part Generic, rules []Rule) (Generic, error) {
+	for _, rule := range rules {
+		if !rule.Match(part) {
+			continue
+		}
+
+		var err error
+		part, err = rule.Apply(part)
+		if err != nil {
+			return nil, err
+		}
+
+		if part == nil {
+			return nil, nil
+		}
+	}
+
+	return part, nil
+}
@@ -0,0 +1,190 @@
+package message_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/go-email/v2/message"
+)
+
+const transformSrc = `Subject: transform me
+Content-type: multipart/mixed; boundary=outer
+
+--outer
+Content-type: text/plain
+
+Keep this  text.
+--outer
+Content-type: image/png
+Content-disposition: attachment; filename=pic.png
+
+PNGDATA
+--outer--
+`
+
+func TestTransform(t *testing.T) {
+	t.Parallel()
+
+	m, err := message.Parse(strings.NewReader(transformSrc))
+	require.NoError(t, err)
+
+	tm, err := message.Transform(m,
+		message.Rule{
+			Match: message.MatchPresentation("attachment"),
+			Apply: func(part message.Generic) (message.Generic, error) {
+				buf := &message.Buffer{}
+				buf.SetMediaType("text/plain")
+				_, _ = buf.Write([]byte("[attachment removed]"))
+				return buf.Opaque(), nil
+			},
+		},
+	)
+	require.NoError(t, err)
+	require.True(t, tm.IsMultipart())
+	assert.Len(t, tm.GetParts(), 2)
+
+	const expect = `Subject: transform me
+Content-type: multipart/mixed; boundary=outer
+
+--outer
+Content-type: text/plain
+
+Keep this  text.
+--outer
+Content-type: text/plain
+
+[attachment removed]
+--outer--
+`
+
+	buf := &bytes.Buffer{}
+	_, err = tm.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, expect, buf.String())
+}
+
+func TestTransform_Unmatched(t *testing.T) {
+	t.Parallel()
+
+	m, err := message.Parse(strings.NewReader(transformSrc))
+	require.NoError(t, err)
+
+	tm, err := message.Transform(m,
+		message.Rule{
+			Match: message.MatchMediaType("video/*"),
+			Apply: func(part message.Generic) (message.Generic, error) {
+				return nil, nil
+			},
+		},
+	)
+	require.NoError(t, err)
+	assert.Same(t, m, tm)
+
+	buf := &bytes.Buffer{}
+	_, err = tm.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, transformSrc, buf.String())
+}
+
+func TestTransform_Remove(t *testing.T) {
+	t.Parallel()
+
+	m, err := message.Parse(strings.NewReader(transformSrc))
+	require.NoError(t, err)
+
+	tm, err := message.Transform(m,
+		message.Rule{
+			Match: message.MatchMediaType("image/*"),
+			Apply: func(part message.Generic) (message.Generic, error) {
+				return nil, nil
+			},
+		},
+	)
+	require.NoError(t, err)
+	require.Len(t, tm.GetParts(), 1)
+
+	mt, err := tm.GetParts()[0].GetHeader().GetMediaType()
+	assert.NoError(t, err)
+	assert.Equal(t, "text/plain", mt)
+}
+
+func TestTransform_Error(t *testing.T) {
+	t.Parallel()
+
+	m, err := message.Parse(strings.NewReader(transformSrc))
+	require.NoError(t, err)
+
+	errFail := errors.New("fail")
+	tm, err := message.Transform(m,
+		message.Rule{
+			Match: message.MatchMediaType("TEXT/PLAIN"),
+			Apply: func(part message.Generic) (message.Generic, error) {
+				return nil, errFail
+			},
+		},
+	)
+	assert.ErrorIs(t, err, errFail)
+	assert.Nil(t, tm)
+}
+
+func TestTransform_LazyParts(t *testing.T) {
+	t.Parallel()
+
+	const src = `Content-type: multipart/mixed; boundary=outer
+
+--outer
+Content-type: multipart/mixed; boundary=inner
+
+Inner preamble.
+--inner
+Content-type: text/plain
+
+Keep this text.
+--inner
+Content-type: image/png
+Content-disposition: attachment; filename=pic.png
+
+PNGDATA
+--inner--
+Inner epilogue.
+--outer--
+`
+
+	m, err := message.Parse(strings.NewReader(src), message.WithLazyParts())
+	require.NoError(t, err)
+
+	tm, err := message.Transform(m,
+		message.Rule{
+			Match: message.MatchPresentation("attachment"),
+			Apply: func(part message.Generic) (message.Generic, error) {
+				return nil, nil
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	const expect = `Content-type: multipart/mixed; boundary=outer
+
+--outer
+Content-type: multipart/mixed; boundary=inner
+
+Inner preamble.
+--inner
+Content-type: text/plain
+
+Keep this text.
+--inner--
+Inner epilogue.
+--outer--
+`
+
+	buf := &bytes.Buffer{}
+	_, err = tm.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, expect, buf.String())
+}
@@ -0,0 +1,109 @@
+package message
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	// textURLPattern matches URLs written out in text.
+	textURLPattern = regexp.MustCompile(`(?i)\b(?:https?://|mailto:)[^\s<>"'` + "`" + `]+`)
+
+	// htmlAttrPattern matches the href and src attributes of HTML tags.
+	htmlAttrPattern = regexp.MustCompile(`(?i)\b(?:href|src)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+
+	// htmlTagPattern matches an HTML tag.
+	htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+)
+
+// ExtractURLs returns every http, https, and mailto URL found in the
+// text/plain and text/html parts of the message. The Content-transfer-encoding
+// and charset of each part are decoded before the part is scanned. URLs are
+// found in the text of text/plain parts. In text/html parts, the URLs in href
+// and src attributes are found along with any written in the text outside of
+// tags. Each URL is returned once, in the order it was first found.
+//
+// In order to scan an *Opaque part, its io.Reader must be read completely. The
+// Reader will be replaced with an in-memory copy of the same bytes so the part
+// may still be used afterwards. It returns an error if there is a problem
+// reading any part.
+func ExtractURLs(msg Generic) ([]*url.URL, error) {
+	var (
+		urls = []*url.URL{}
+		seen = map[string]bool{}
+	)
+
+	add := func(raw string) {
+		raw = strings.TrimRight(strings.TrimSpace(raw), ".,;:!?)]}")
+		u, err := url.Parse(raw)
+		if err != nil {
+			return
+		}
+
+		switch strings.ToLower(u.Scheme) {
+		case "http", "https", "mailto":
+		default:
+			return
+		}
+
+		if key := u.String(); !seen[key] {
+			seen[key] = true
+			urls = append(urls, u)
+		}
+	}
+
+	err := extractURLs(msg, add)
+	if err != nil {
+		return nil, err
+	}
+
+	return urls, nil
+}
+
+// extractURLs implements the recursive part of ExtractURLs.
+func extractURLs(part Part, add func(string)) error {
+	if part.IsMultipart() {
+		for _, p := range part.GetParts() {
+			if err := extractURLs(p, add); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	h := part.GetHeader()
+	mt, err := h.GetMediaType()
+	if err != nil {
+		mt = "text/plain"
+	}
+	mt = strings.ToLower(mt)
+
+	if mt != "text/plain" && mt != "text/html" {
+		return nil
+	}
+
+	content, err := readDecodedContent(part)
+	if err != nil {
+		return err
+	}
+
+	text, ok := decodeText(h, content)
+	if !ok {
+		return nil
+	}
+
+	if mt == "text/html" {
+		for _, m := range htmlAttrPattern.FindAllStringSubmatch(text, -1) {
+			add(html.UnescapeString(m[1] + m[2] + m[3]))
+		}
+		text = html.UnescapeString(htmlTagPattern.ReplaceAllString(text, " "))
+	}
+
+	for _, m := range textURLPattern.FindAllString(text, -1) {
+		add(m)
+	}
+
+	return nil
+}
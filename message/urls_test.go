@@ -0,0 +1,58 @@
+package message_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/go-email/v2/message"
+)
+
+func TestExtractURLs(t *testing.T) {
+	t.Parallel()
+
+	const src = `Content-type: multipart/mixed; boundary=outer
+
+--outer
+Content-type: multipart/alternative; boundary=inner
+
+--inner
+Content-type: text/plain
+
+Visit https://example.com/a?x=1. Or write to mailto:bob@example.com!
+--inner
+Content-type: text/html; charset=utf-8
+Content-transfer-encoding: quoted-printable
+
+<p><a href=3D"https://example.com/b?x=3D1&amp;y=3D2">link</a>
+<img src=3D'http://example.com/logo.png'>
+<a href=3D"javascript:alert(1)">bad</a>
+Also https://example.com/a?x=3D1</p>
+--inner--
+--outer
+Content-type: application/octet-stream
+
+https://example.com/hidden
+--outer--
+`
+
+	m, err := message.Parse(strings.NewReader(src))
+	require.NoError(t, err)
+
+	urls, err := message.ExtractURLs(m)
+	require.NoError(t, err)
+
+	got := make([]string, len(urls))
+	for i, u := range urls {
+		got[i] = u.String()
+	}
+
+	assert.Equal(t, []string{
+		"https://example.com/a?x=1",
+		"mailto:bob@example.com",
+		"https://example.com/b?x=1&y=2",
+		"http://example.com/logo.png",
+	}, got)
+}
@@ -0,0 +1,340 @@
+package message
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/zostay/go-email/v2/message/header"
+	"github.com/zostay/go-email/v2/message/transfer"
+)
+
+// MaxLineLength is the maximum length of a line permitted by RFC 5322 and RFC
+// 2045, not including the line break.
+const MaxLineLength = 998
+
+// TransferEncodingError is returned by ValidateTransferEncoding() when the
+// content of one or more parts does not agree with the declared
+// Content-transfer-encoding. Each violation found is described in Violations.
+type TransferEncodingError struct {
+	Violations []string
+}
+
+// Error returns all the violations as a single message.
+func (err *TransferEncodingError) Error() string {
+	return "transfer encoding validation failed: " + strings.Join(err.Violations, "; ")
+}
+
+// ValidateTransferEncoding checks that the Content-transfer-encoding declared
+// in the header of each part of the message is consistent with the actual
+// bytes of the part. The rules checked are as follows:
+//
+// * 7bit (or no Content-transfer-encoding at all) must contain only ASCII
+// characters with no NUL bytes and no lines longer than MaxLineLength.
+//
+// * 8bit may contain bytes with the high bit set, but must not contain NUL
+// bytes or lines longer than MaxLineLength.
+//
+// * binary may contain anything.
+//
+// * base64 and quoted-printable must decode without error.
+//
+// * A multipart part may only declare 7bit, 8bit, or binary.
+//
+// If any violation is found, a *TransferEncodingError will be returned
+// describing every violation. Otherwise, it returns nil. An error reading a
+// part is returned as-is.
+//
+// If a part has already had its transfer encoding decoded (i.e., IsEncoded()
+// returns false), only the 7bit and 8bit rules are checked as the other
+// encodings will be freshly applied when the part is written.
+//
+// In order to check an *Opaque part, its io.Reader must be read completely. The
+// Reader will be replaced with an in-memory copy of the same bytes so the part
+// may still be used afterwards.
+func ValidateTransferEncoding(part Generic) error {
+	violations := make([]string, 0, 10)
+	err := validateTransferEncoding(part, "message", &violations)
+	if err != nil {
+		return err
+	}
+
+	if len(violations) > 0 {
+		return &TransferEncodingError{violations}
+	}
+
+	return nil
+}
+
+// validateTransferEncoding implements the recursive part of
+// ValidateTransferEncoding.
+func validateTransferEncoding(part Part, name string, violations *[]string) error {
+	cte, err := part.GetHeader().GetTransferEncoding()
+	if errors.Is(err, header.ErrNoSuchField) {
+		cte = transfer.Bit7
+	} else if err != nil {
+		return err
+	}
+	cte = strings.ToLower(strings.TrimSpace(cte))
+
+	addViolation := func(f string, args ...any) {
+		*violations = append(*violations,
+			fmt.Sprintf("%s: declared %s, but %s", name, cte, fmt.Sprintf(f, args...)))
+	}
+
+	if part.IsMultipart() {
+		switch cte {
+		case transfer.Bit7, transfer.Bit8, transfer.Binary:
+		default:
+			addViolation("multipart parts may only be 7bit, 8bit, or binary")
+		}
+
+		for i, p := range part.GetParts() {
+			err := validateTransferEncoding(p, fmt.Sprintf("%s part %d", name, i+1), violations)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	content, err := readPartContent(part)
+	if err != nil {
+		return err
+	}
+
+	switch cte {
+	case transfer.Bit7, transfer.Bit8:
+		checkLines(content, cte, addViolation)
+	case transfer.Binary:
+		// anything goes
+	case transfer.Base64, transfer.QuotedPrintable:
+		if !part.IsEncoded() {
+			break
+		}
+
+		dec := transfer.Transcodings[cte].Decoder(bytes.NewReader(content))
+		if _, err := io.Copy(io.Discard, dec); err != nil {
+			addViolation("the content does not decode: %v", err)
+		}
+	default:
+		addViolation("the transfer encoding is not recognized")
+	}
+
+	return nil
+}
+
+// StructureError is returned by ValidateStructure() when the MIME structure of
+// the message is malformed. Each problem found is described in Violations.
+type StructureError struct {
+	Violations []string
+}
+
+// Error returns all the violations as a single message.
+func (err *StructureError) Error() string {
+	return "structure validation failed: " + strings.Join(err.Violations, "; ")
+}
+
+// ValidateStructure checks the MIME structure of the message for problems
+// that commonly cause other software to fail to parse it. The rules checked
+// are as follows:
+//
+// * A multipart/* or message/* part must not declare a
+// Content-transfer-encoding of base64 or quoted-printable, as RFC 2045 only
+// permits 7bit, 8bit, or binary for these types.
+//
+// * A multipart/* part must have a boundary parameter on its Content-type.
+//
+// The rules are checked against the Content-type of each part, so a part
+// declared as multipart that could not be parsed as one (e.g., because it has
+// no boundary) is checked, too. If any violation is found, a *StructureError
+// will be returned describing every violation. Otherwise, it returns nil. The
+// message's io.Reader objects are not read.
+func ValidateStructure(msg Generic) error {
+	violations := make([]string, 0, 10)
+	validateStructure(msg, "message", &violations)
+
+	if len(violations) > 0 {
+		return &StructureError{violations}
+	}
+
+	return nil
+}
+
+// validateStructure implements the recursive part of ValidateStructure.
+func validateStructure(part Part, name string, violations *[]string) {
+	h := part.GetHeader()
+
+	mt, _ := h.GetMediaType()
+	mt = strings.ToLower(mt)
+	isMultipart := strings.HasPrefix(mt, "multipart/")
+
+	if isMultipart || strings.HasPrefix(mt, "message/") {
+		cte, _ := h.GetTransferEncoding()
+		switch cte = strings.ToLower(strings.TrimSpace(cte)); cte {
+		case transfer.Base64, transfer.QuotedPrintable:
+			*violations = append(*violations,
+				fmt.Sprintf("%s: %s declares %s, but may only be 7bit, 8bit, or binary", name, mt, cte))
+		}
+	}
+
+	if isMultipart {
+		if b, err := h.GetBoundary(); err != nil || b == "" {
+			*violations = append(*violations,
+				fmt.Sprintf("%s: %s has no boundary", name, mt))
+		}
+	}
+
+	if part.IsMultipart() {
+		for i, p := range part.GetParts() {
+			validateStructure(p, fmt.Sprintf("%s part %d", name, i+1), violations)
+		}
+	}
+}
+
+// BoundaryInContentError is returned by ValidateBoundaries() or by Parse() with
+// WithBoundaryCheck() when the content of one or more parts contains the
+// boundary of a multipart part enclosing it. Each occurrence found is described
+// in Violations.
+type BoundaryInContentError struct {
+	Violations []string
+}
+
+// Error returns all the violations as a single message.
+func (err *BoundaryInContentError) Error() string {
+	return "boundary validation failed: " + strings.Join(err.Violations, "; ")
+}
+
+// ValidateBoundaries checks that the decoded content of each part of the
+// message does not contain the boundary delimiter (i.e., "--" followed by the
+// boundary parameter) of any multipart part enclosing it. A boundary cannot
+// appear at the start of a line of the raw content, or the part would have been
+// split there, but it can appear elsewhere in the line or once the
+// Content-transfer-encoding is decoded. Other parsers may split such a message
+// differently, so this is a sign of a malformed or deliberately crafted
+// message, which a gateway may want to reject or quarantine.
+//
+// If any violation is found, a *BoundaryInContentError will be returned
+// describing every violation. Otherwise, it returns nil. An error reading a
+// part is returned as-is.
+//
+// In order to check an *Opaque part, its io.Reader must be read completely. The
+// Reader will be replaced with an in-memory copy of the same bytes so the part
+// may still be used afterwards. To avoid this, use WithBoundaryCheck() to make
+// the same check while the message is parsed.
+func ValidateBoundaries(msg Generic) error {
+	violations := make([]string, 0, 10)
+	err := validateBoundaries(msg, "message", nil, &violations)
+	if err != nil {
+		return err
+	}
+
+	if len(violations) > 0 {
+		return &BoundaryInContentError{violations}
+	}
+
+	return nil
+}
+
+// validateBoundaries implements the recursive part of ValidateBoundaries. The
+// boundaries of the enclosing multipart parts are given in boundaries.
+func validateBoundaries(
+	part Part,
+	name string,
+	boundaries []string,
+	violations *[]string,
+) error {
+	if part.IsMultipart() {
+		if b, err := part.GetHeader().GetBoundary(); err == nil && b != "" {
+			boundaries = append(boundaries[:len(boundaries):len(boundaries)], b)
+		}
+
+		for i, p := range part.GetParts() {
+			err := validateBoundaries(p, fmt.Sprintf("%s part %d", name, i+1), boundaries, violations)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(boundaries) == 0 {
+		return nil
+	}
+
+	content, err := readDecodedContent(part)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range boundaries {
+		if bytes.Contains(content, []byte("--"+b)) {
+			*violations = append(*violations,
+				fmt.Sprintf("%s: content contains the boundary %q", name, b))
+		}
+	}
+
+	return nil
+}
+
+// checkLines checks that the content follows the rules for the 7bit or 8bit
+// transfer encoding named by cte and calls addViolation for each rule broken.
+func checkLines(content []byte, cte string, addViolation func(string, ...any)) {
+	for ln, line := range bytes.Split(content, []byte("\n")) {
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if len(line) > MaxLineLength {
+			addViolation("line %d is %d bytes long", ln+1, len(line))
+		}
+		if bytes.IndexByte(line, 0) >= 0 {
+			addViolation("line %d contains a NUL byte", ln+1)
+		}
+		if cte == transfer.Bit7 {
+			if ix := bytes.IndexFunc(line, func(c rune) bool { return c > 0x7f }); ix >= 0 {
+				addViolation("line %d contains non-ASCII bytes", ln+1)
+			}
+		}
+	}
+}
+
+// readPartContent reads all the bytes from the reader of the given part. If the
+// part is an *Opaque, the reader is replaced so that the content may be read
+// again.
+func readPartContent(part Part) ([]byte, error) {
+	part = unwrapLazy(part)
+
+	r := part.GetReader()
+	if r == nil {
+		return []byte{}, nil
+	}
+
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if op, isOpaque := part.(*Opaque); isOpaque {
+		op.Reader = bytes.NewReader(content)
+	}
+
+	return content, nil
+}
+
+// readDecodedContent reads all the bytes from the reader of the given part, as
+// readPartContent does, and decodes the Content-transfer-encoding if the part
+// is still encoded.
+func readDecodedContent(part Part) ([]byte, error) {
+	content, err := readPartContent(part)
+	if err != nil {
+		return nil, err
+	}
+
+	if part.IsEncoded() {
+		return io.ReadAll(
+			transfer.ApplyTransferDecoding(part.GetHeader(), bytes.NewReader(content)))
+	}
+
+	return content, nil
+}
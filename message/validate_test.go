@@ -0,0 +1,185 @@
+package message_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/go-email/v2/message"
+)
+
+func TestValidateTransferEncoding(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		src   string
+		valid bool
+	}{
+		{"7bit ok", "Content-transfer-encoding: 7bit\n\nHello.\n", true},
+		{"missing ok", "Subject: test\n\nHello.\n", true},
+		{"7bit high", "Content-transfer-encoding: 7bit\n\nI ❤ email.\n", false},
+		{"7bit long", "Content-transfer-encoding: 7bit\n\n" + strings.Repeat("x", 1000) + "\n", false},
+		{"8bit high", "Content-transfer-encoding: 8bit\n\nI ❤ email.\n", true},
+		{"8bit nul", "Content-transfer-encoding: 8bit\n\nnul\x00\n", false},
+		{"binary", "Content-transfer-encoding: binary\n\nnul\x00\n", true},
+		{"base64 ok", "Content-transfer-encoding: base64\n\naGVsbG8=\n", true},
+		{"base64 bad", "Content-transfer-encoding: base64\n\n!!!!\n", false},
+		{"qp ok", "Content-transfer-encoding: quoted-printable\n\n=3D\n", true},
+		{"qp bad", "Content-transfer-encoding: quoted-printable\n\nctl\x01\n", false},
+		{"unknown", "Content-transfer-encoding: x-weird\n\nHello.\n", false},
+		{"multipart", "Content-type: multipart/mixed; boundary=x\nContent-transfer-encoding: base64\n\n--x\n\nhi\n--x--\n", false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			m, err := message.Parse(strings.NewReader(test.src))
+			require.NoError(t, err)
+
+			err = message.ValidateTransferEncoding(m)
+			if test.valid {
+				assert.NoError(t, err)
+			} else {
+				var teErr *message.TransferEncodingError
+				assert.ErrorAs(t, err, &teErr)
+			}
+		})
+	}
+}
+
+func TestValidateTransferEncoding_Rereadable(t *testing.T) {
+	t.Parallel()
+
+	const src = "Content-transfer-encoding: 7bit\n\nHello.\n"
+
+	m, err := message.Parse(strings.NewReader(src))
+	require.NoError(t, err)
+
+	err = message.ValidateTransferEncoding(m)
+	assert.NoError(t, err)
+
+	body, err := io.ReadAll(m.GetReader())
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello.\n", string(body))
+}
+
+func TestValidateStructure(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		src        string
+		violations []string
+	}{
+		{"simple", "Content-type: text/plain\nContent-transfer-encoding: base64\n\naGVsbG8=\n", nil},
+		{"multipart ok", "Content-type: multipart/mixed; boundary=x\nContent-transfer-encoding: 7bit\n\n--x\n\nhi\n--x--\n", nil},
+		{
+			"multipart base64",
+			"Content-type: multipart/mixed; boundary=x\nContent-transfer-encoding: base64\n\n--x\n\nhi\n--x--\n",
+			[]string{"message: multipart/mixed declares base64, but may only be 7bit, 8bit, or binary"},
+		},
+		{
+			"no boundary",
+			"Content-type: multipart/mixed\n\nhi\n",
+			[]string{"message: multipart/mixed has no boundary"},
+		},
+		{
+			"nested message",
+			"Content-type: multipart/mixed; boundary=x\n\n--x\n" +
+				"Content-type: message/rfc822\nContent-transfer-encoding: Quoted-Printable\n\nSubject: hi\n\nhi\n" +
+				"--x\nContent-type: multipart/alternative\n\nhi\n--x--\n",
+			[]string{
+				"message part 1: message/rfc822 declares quoted-printable, but may only be 7bit, 8bit, or binary",
+				"message part 2: multipart/alternative has no boundary",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			m, err := message.Parse(strings.NewReader(test.src), message.WithUnlimitedRecursion())
+			require.NoError(t, err)
+
+			err = message.ValidateStructure(m)
+			if test.violations == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			var sErr *message.StructureError
+			require.ErrorAs(t, err, &sErr)
+			assert.Equal(t, test.violations, sErr.Violations)
+		})
+	}
+}
+
+func TestValidateBoundaries(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		src        string
+		violations []string
+	}{
+		{"simple", "Content-type: text/plain\n\n--x\n", nil},
+		{"multipart ok", "Content-type: multipart/mixed; boundary=x\n\n--x\n\nhi\n--x--\n", nil},
+		{
+			"in line",
+			"Content-type: multipart/mixed; boundary=x\n\n--x\n\nsee --x here\n--x--\n",
+			[]string{`message part 1: content contains the boundary "x"`},
+		},
+		{
+			"encoded",
+			"Content-type: multipart/mixed; boundary=x\n\n--x\n" +
+				"Content-transfer-encoding: base64\n\nLS14DQo=\n--x--\n",
+			[]string{`message part 1: content contains the boundary "x"`},
+		},
+		{
+			"nested",
+			"Content-type: multipart/mixed; boundary=outer\n\n--outer\n" +
+				"Content-type: multipart/alternative; boundary=inner\n\n--inner\n\n" +
+				"a =--outer\n--inner\n\nb --inner\n--inner--\n--outer--\n",
+			[]string{
+				`message part 1 part 1: content contains the boundary "outer"`,
+				`message part 1 part 2: content contains the boundary "inner"`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			m, err := message.Parse(strings.NewReader(test.src), message.WithUnlimitedRecursion())
+			require.NoError(t, err)
+
+			// the same violations are found while parsing
+			_, pErr := message.Parse(strings.NewReader(test.src),
+				message.WithUnlimitedRecursion(), message.WithBoundaryCheck())
+
+			err = message.ValidateBoundaries(m)
+			if test.violations == nil {
+				assert.NoError(t, err)
+				assert.NoError(t, pErr)
+				return
+			}
+
+			var bErr *message.BoundaryInContentError
+			require.ErrorAs(t, err, &bErr)
+			assert.Equal(t, test.violations, bErr.Violations)
+
+			require.ErrorAs(t, pErr, &bErr)
+			assert.Equal(t, test.violations, bErr.Violations)
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package message
+
+import (
+	"bytes"
+	"io"
+)
+
+// WriteToNormalized writes the message to w, just like calling WriteTo on the
+// message. If ensureTrailingBreak is true and the output does not already end
+// with the line break of the message header (see header.Header.Break), that
+// line break is written after the message. This is useful when concatenating
+// messages into an mbox-like file, where each message must end with a line
+// break. It returns the total number of bytes written.
+//
+// This is meant for output only. Unlike WriteTo, the output may not round-trip
+// to the original bytes of a parsed message.
+func WriteToNormalized(msg Generic, w io.Writer, ensureTrailingBreak bool) (int64, error) {
+	if !ensureTrailingBreak {
+		return msg.WriteTo(w)
+	}
+
+	br := msg.GetHeader().Break().Bytes()
+	tw := &tailWriter{w: w, size: len(br)}
+	n, err := msg.WriteTo(tw)
+	if err != nil {
+		return n, err
+	}
+
+	if bytes.HasSuffix(tw.tail, br) {
+		return n, nil
+	}
+
+	bn, err := w.Write(br)
+	return n + int64(bn), err
+}
+
+// tailWriter is an io.Writer that passes writes through while remembering the
+// last size bytes written.
+type tailWriter struct {
+	w    io.Writer
+	size int
+	tail []byte
+}
+
+// Write writes p to the wrapped io.Writer and keeps the end of it.
+func (tw *tailWriter) Write(p []byte) (int, error) {
+	n, err := tw.w.Write(p)
+
+	tw.tail = append(tw.tail, p[:n]...)
+	if over := len(tw.tail) - tw.size; over > 0 {
+		tw.tail = append(tw.tail[:0], tw.tail[over:]...)
+	}
+
+	return n, err
+}
+
+// countingWriter is an io.Writer that passes writes through while counting the
+// bytes written.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write writes p to the wrapped io.Writer and counts the bytes written.
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,47 @@
+package message_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/go-email/v2/message"
+)
+
+func TestWriteToNormalized(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		src    string
+		ensure bool
+		expect string
+	}{
+		{"missing", "Subject: a\r\n\r\nno break", true, "Subject: a\r\n\r\nno break\r\n"},
+		{"present", "Subject: a\r\n\r\nbreak\r\n", true, "Subject: a\r\n\r\nbreak\r\n"},
+		{"bare LF", "Subject: a\r\n\r\nbreak\n", true, "Subject: a\r\n\r\nbreak\n\r\n"},
+		{"empty body", "Subject: a\n\n", true, "Subject: a\n\n"},
+		{"not ensured", "Subject: a\n\nno break", false, "Subject: a\n\nno break"},
+		{"multipart", "Content-type: multipart/mixed; boundary=b\n\n--b\n\none\n--b--", true,
+			"Content-type: multipart/mixed; boundary=b\n\n--b\n\none\n--b--\n"},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			m, err := message.Parse(strings.NewReader(test.src))
+			require.NoError(t, err)
+
+			buf := &bytes.Buffer{}
+			n, err := message.WriteToNormalized(m, buf, test.ensure)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expect, buf.String())
+			assert.Equal(t, int64(buf.Len()), n)
+		})
+	}
+}